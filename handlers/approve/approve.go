@@ -2,48 +2,161 @@ package approve
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
 
-	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
 	"github.com/huawei-cloudnative/ci-bot/handlers/util"
 )
 
+const (
+	// maxPaths limits the paths in the responses
+	maxPaths = 5
+	// maxSuggestions limits the suggested approvers in the responses
+	maxSuggestions = 5
+)
+
 var (
-	// approve label name
-	LabelNameApproved = util.LabelNameApproved
 	// regular expression to add approve
 	RegAddApprove = regexp.MustCompile(`(?mi)^/approve\s*$`)
 	// regular expression to cancel approve
 	RegCancelApprove = regexp.MustCompile(`(?mi)^/approve cancel\s*$`)
 )
 
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "approve",
+		Help: "/approve and /approve cancel add or remove the approved label. Only the approvers in the OWNERS files can approve. " +
+			"An approving review of an approver counts as /approve.",
+		Commands: []*regexp.Regexp{RegAddApprove, RegCancelApprove},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return Handle(agent, event)
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePullRequest(agent, event)
+		},
+		ReviewHandler: func(agent plugins.Agent, event github.PullRequestReviewEvent) error {
+			return HandleReview(agent, event)
+		},
+		// the approval notifier is updated when the pr is changed
+		PullRequestActions: []string{plugins.ActionOpened, plugins.ActionReopened, plugins.ActionSynchronize},
+	})
+}
+
 // Handle event with approve
-func Handle(client *github.Client, r repository.Interface, event github.IssueCommentEvent) error {
+func Handle(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// only handle pr which is open
 	if event.Issue.IsPullRequest() && *event.Issue.State == "open" {
 		// get basic params
 		comment := *event.Comment.Body
 		glog.Infof("Receive event with approve. Comment: %s", comment)
 
-		// add approved label
+		var err error
 		if RegAddApprove.MatchString(comment) {
-			return Add(client, r, event)
+			// add approved label
+			err = Add(agent, event)
+		} else if RegCancelApprove.MatchString(comment) {
+			// remove approved label
+			err = Cancel(agent, event)
+		} else {
+			return nil
+		}
+
+		// update the approval notifier even if the command is refused
+		notifierErr := UpdateNotifier(agent, *event.Repo.Owner.Login, *event.Repo.Name, *event.Issue.Number, event.Comment)
+		if err == nil {
+			err = notifierErr
 		}
-		// remove approved label
-		if RegCancelApprove.MatchString(comment) {
-			return Cancel(client, r, event)
+		return err
+	}
+	return nil
+}
+
+// HandleReview approves the pr if the approving reviewer is an approver of the changed files.
+// The approving reviews of the others are handled by lgtm.
+func HandleReview(agent plugins.Agent, event github.PullRequestReviewEvent) error {
+	pr := event.GetPullRequest()
+	if pr.GetState() != "open" || !plugins.IsReviewState(event.GetReview(), plugins.ReviewStateApproved) {
+		return nil
+	}
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	reviewer := event.GetReview().GetUser().GetLogin()
+
+	files, err := util.ListFileNames(agent.GithubClient, owner, repo, pr.GetNumber())
+	if err != nil {
+		return err
+	}
+	owners, err := agent.Repository.LoadOwners(pr.GetBase().GetRef())
+	if err != nil {
+		glog.Errorf("Unable to load owners. err: %v", err)
+		return err
+	}
+	for _, f := range files {
+		if _, ok := owners.GetAllApprovers(f)[reviewer]; ok {
+			glog.Infof("Approving review of the approver %s on pr #%d", reviewer, pr.GetNumber())
+			return Handle(agent, plugins.ReviewCommentEvent(event, "/approve"))
 		}
 	}
+	glog.Infof("%s is not an approver of pr #%d, skip the approving review", reviewer, pr.GetNumber())
+	return nil
+}
+
+// HandlePullRequest updates the approval notifier of the pr
+func HandlePullRequest(agent plugins.Agent, event github.PullRequestEvent) error {
+	if event.GetPullRequest().GetState() != "open" {
+		return nil
+	}
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.GetNumber()
+	if event.GetAction() == plugins.ActionOpened {
+		err := RequestApprovers(agent, owner, repo, event.GetPullRequest())
+		if err != nil {
+			glog.Errorf("Unable to request the suggested approvers of pr #%d. err: %v", number, err)
+		}
+	}
+	return UpdateNotifier(agent, owner, repo, number, nil)
+}
+
+// RequestApprovers requests the reviews of the suggested approvers who cover all the changed files
+func RequestApprovers(agent plugins.Agent, owner string, repo string, pr *github.PullRequest) error {
+	client := agent.GithubClient
+	r := agent.Repository
+	number := pr.GetNumber()
+
+	files, err := util.ListFileNames(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	owners, err := r.LoadOwners(pr.GetBase().GetRef())
+	if err != nil {
+		glog.Errorf("Unable to load owners. err: %v", err)
+		return err
+	}
+	suggested := SuggestApprovers(owners, files, pr.GetUser().GetLogin(), int64(number))
+	if len(suggested) == 0 {
+		glog.Infof("No approver to request for pr #%d", number)
+		return nil
+	}
+	_, _, err = client.PullRequests.RequestReviewers(context.Background(), owner, repo, number, github.ReviewersRequest{Reviewers: suggested})
+	if err != nil {
+		return err
+	}
+	glog.Infof("Request approvers %v for pr #%d", suggested, number)
 	return nil
 }
 
 // Add approved label
-func Add(client *github.Client, r repository.Interface, event github.IssueCommentEvent) error {
+func Add(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// get basic params
 	ctx := context.Background()
+	client := agent.GithubClient
+	r := agent.Repository
+	labelNameApproved := agent.Config.Labels.Approved
 	comment := *event.Comment.Body
 	commentAuthor := *event.Comment.User.Login
 	owner := *event.Repo.Owner.Login
@@ -51,37 +164,26 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 	number := *event.Issue.Number
 	glog.Infof("Add approve started. Comment: %s commentAuthor: %s owner: %s repo: %s number: %d",
 		comment, commentAuthor, owner, repo, number)
+	// the approvers who are recorded as the givers of approve
+	approvedBy := []string{commentAuthor}
 
 	// check if current author is collaborator
 	IsCollaborator, _, err := client.Repositories.IsCollaborator(ctx, owner, repo, commentAuthor)
 	if err != nil {
-		glog.Fatalf("Unable to check if current author is collaborator. err: %v", err)
+		glog.Errorf("Unable to check if current author is collaborator. err: %v", err)
 		return err
 	}
 	// not collaborator
 	if !IsCollaborator {
-		issuelistCommentsOptions := &github.IssueListCommentsOptions{Sort: "created", Direction: "asc"}
-		issueComments, _, err := client.Issues.ListComments(ctx, owner, repo, number, issuelistCommentsOptions)
+		issueComments, err := util.ListComments(client, owner, repo, number)
 		if err != nil {
-			glog.Fatalf("Unable to list issue comments. err: %v", issueComments)
 			return err
 		}
 
-		// init current approvers map
-		mapOfApprovers := map[string]string{}
-		for _, ic := range issueComments {
-			// add approvers
-			if RegAddApprove.MatchString(*ic.Body) {
-				// add approver in map
-				mapOfApprovers[*ic.User.Login] = *ic.User.Login
-			}
-			// cancel approvers
-			if RegCancelApprove.MatchString(*ic.Body) {
-				// delete approver in map
-				if _, ok := mapOfApprovers[*ic.User.Login]; ok {
-					delete(mapOfApprovers, *ic.User.Login)
-				}
-			}
+		// current approvers of the comments and the reviews
+		mapOfApprovers, err := approvals(client, owner, repo, number, issueComments)
+		if err != nil {
+			return err
 		}
 		// the last comment event does not including in the result of list issue comments
 		// so it can be added here
@@ -89,15 +191,10 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 		glog.Infof("Current map of approvers: %v", mapOfApprovers)
 
 		// list file names in current pr e.g. test/hello.go
-		prChangedFiles, _, err := client.PullRequests.ListFiles(ctx, owner, repo, number, nil)
+		listOfFileNames, err := util.ListFileNames(client, owner, repo, number)
 		if err != nil {
-			glog.Fatalf("Unable to list pr changed files. err: %v", err)
 			return err
 		}
-		listOfFileNames := make([]string, 0)
-		for _, f := range prChangedFiles {
-			listOfFileNames = append(listOfFileNames, *f.Filename)
-		}
 		glog.Infof("List of pr file names: %v", listOfFileNames)
 
 		// e.g. master
@@ -105,18 +202,20 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 		glog.Infof("Pr base ref: %v", *pr.Base.Ref)
 
 		// load owners
-		err = r.LoadOwners(*pr.Base.Ref)
+		owners, err := r.LoadOwners(*pr.Base.Ref)
 		if err != nil {
-			glog.Fatalf("Unable to load owners. err: %v", err)
+			glog.Errorf("Unable to load owners. err: %v", err)
 			return err
 		}
 
 		// init approved path map
 		mapOfApprovedPath := make(map[string]map[string]string)
 		listOfUnapprovedPath := make([]string, 0)
+		// the comment author is an approver of some changed files
+		isApprover := false
 		for _, path := range listOfFileNames {
 			// get all approvers by path
-			allApprovers := r.GetAllApprovers(path)
+			allApprovers := owners.GetAllApprovers(path)
 			glog.Infof("Path: %s AllApprovers: %v", path, allApprovers)
 
 			// init map by path
@@ -124,6 +223,9 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 				mapOfApprovedPath[path] = make(map[string]string)
 			}
 
+			if _, ok := allApprovers[commentAuthor]; ok {
+				isApprover = true
+			}
 			// the current approvers are in the approvers of path
 			for k, v := range mapOfApprovers {
 				if _, ok := allApprovers[k]; ok {
@@ -137,11 +239,27 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 			}
 		}
 		glog.Infof("Map of approved path: %v", mapOfApprovedPath)
+		approvedBy = approvedBy[:0]
+		for _, m := range mapOfApprovedPath {
+			for k := range m {
+				approvedBy = append(approvedBy, k)
+			}
+		}
 
 		// unapproved path is existing
 		if len(listOfUnapprovedPath) > 0 {
 			glog.Infof("Unapproved path is existing: %v", listOfUnapprovedPath)
-			return nil
+			// suggest the approvers who cover the unapproved path
+			suggestedApprovers := util.Users(SuggestApprovers(owners, listOfUnapprovedPath, pr.GetUser().GetLogin(), int64(number)))
+			message := fmt.Sprintf("you are not an approver for %s", util.Paths(listOfUnapprovedPath, maxPaths))
+			if isApprover {
+				message = fmt.Sprintf("your approval is recorded, and %s still need approval", util.Paths(listOfUnapprovedPath, maxPaths))
+			}
+			// no approver may be left to suggest, e.g. the author is the only one
+			if len(suggestedApprovers) > 0 {
+				message += "; suggested approvers: " + util.Mentions(suggestedApprovers, maxSuggestions)
+			}
+			return agent.Respond(event, message)
 		}
 	} else {
 		glog.Infof("Current author %s is collaborator", commentAuthor)
@@ -150,7 +268,7 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 	// list labels in current issue
 	listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, owner, repo, number, nil)
 	if err != nil {
-		glog.Fatalf("Unable to list issue labels. err: %v", err)
+		glog.Errorf("Unable to list issue labels. err: %v", err)
 		return err
 	}
 	glog.Infof("List of issue labels: %v", listofIssueLabels)
@@ -158,7 +276,7 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 	// check if it is approved
 	hasApproved := false
 	for _, l := range listofIssueLabels {
-		if *l.Name == LabelNameApproved {
+		if *l.Name == labelNameApproved {
 			hasApproved = true
 			break
 		}
@@ -167,31 +285,40 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 	// not approved
 	if !hasApproved {
 		// add label approved
-		listOfAddLabels := []string{LabelNameApproved}
+		listOfAddLabels := []string{labelNameApproved}
 		_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, listOfAddLabels)
 		if err != nil {
-			glog.Fatalf("Unable to add label: %v err: %v", listOfAddLabels, err)
+			glog.Errorf("Unable to add label: %v err: %v", listOfAddLabels, err)
 			return err
 		} else {
 			glog.Infof("Add label successfully: %v", listOfAddLabels)
 		}
 	} else {
-		glog.Infof("No label to add: %v", LabelNameApproved)
+		glog.Infof("No label to add: %v", labelNameApproved)
 	}
 
-	// try to merge pr
-	err = util.MergePullRequest(client, owner, repo, number)
+	// the givers are recorded for the merge commit message
+	err = util.UpdateGivers(client, owner, repo, number, func(g *util.Givers) {
+		g.Approved = util.AddUsers(g.Approved, approvedBy...)
+	})
 	if err != nil {
-		glog.Errorf("Unable to merge pr: #%d err: %v", number, err)
 		return err
 	}
+
+	// the merge queue merges the pr when it is ready
+	if agent.MergeQueue != nil {
+		agent.MergeQueue.Enqueue(owner, repo, number)
+	}
 	return nil
 }
 
 // Cancel removes approved label
-func Cancel(client *github.Client, r repository.Interface, event github.IssueCommentEvent) error {
+func Cancel(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// get basic params
 	ctx := context.Background()
+	client := agent.GithubClient
+	r := agent.Repository
+	labelNameApproved := agent.Config.Labels.Approved
 	comment := *event.Comment.Body
 	commentAuthor := *event.Comment.User.Login
 	owner := *event.Repo.Owner.Login
@@ -203,21 +330,16 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 	// check if current author is collaborator
 	IsCollaborator, _, err := client.Repositories.IsCollaborator(ctx, owner, repo, commentAuthor)
 	if err != nil {
-		glog.Fatalf("Unable to check if current author is collaborator. err: %v", err)
+		glog.Errorf("Unable to check if current author is collaborator. err: %v", err)
 		return err
 	}
 	// not collaborator
 	if !IsCollaborator {
 		// list file names in current pr e.g. test/hello.go
-		prChangedFiles, _, err := client.PullRequests.ListFiles(ctx, owner, repo, number, nil)
+		listOfFileNames, err := util.ListFileNames(client, owner, repo, number)
 		if err != nil {
-			glog.Fatalf("Unable to list pr changed files. err: %v", err)
 			return err
 		}
-		listOfFileNames := make([]string, 0)
-		for _, f := range prChangedFiles {
-			listOfFileNames = append(listOfFileNames, *f.Filename)
-		}
 		glog.Infof("List of pr file names: %v", listOfFileNames)
 
 		// e.g. master
@@ -225,9 +347,9 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 		glog.Infof("Pr base ref: %v", *pr.Base.Ref)
 
 		// init owners
-		err = r.LoadOwners(*pr.Base.Ref)
+		owners, err := r.LoadOwners(*pr.Base.Ref)
 		if err != nil {
-			glog.Fatalf("Unable to load owners. err: %v", err)
+			glog.Errorf("Unable to load owners. err: %v", err)
 			return err
 		}
 
@@ -235,7 +357,7 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 		IsApprover := false
 		for _, path := range listOfFileNames {
 			// get all approvers by path
-			allApprovers := r.GetAllApprovers(path)
+			allApprovers := owners.GetAllApprovers(path)
 			glog.Infof("Path: %s AllApprovers: %v", path, allApprovers)
 
 			// owner is existing in the approvers of path
@@ -249,7 +371,7 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 		// not approver
 		if !IsApprover {
 			glog.Infof("Owner is not existing in the approvers of path")
-			return nil
+			return agent.Respond(event, "you are not an approver of the changed files, only the approvers can cancel approve.")
 		}
 	} else {
 		glog.Infof("Current author %s is collaborator", commentAuthor)
@@ -258,7 +380,7 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 	// list labels in current issue
 	listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, owner, repo, number, nil)
 	if err != nil {
-		glog.Fatalf("Unable to list issue labels. err: %v", err)
+		glog.Errorf("Unable to list issue labels. err: %v", err)
 		return err
 	}
 	glog.Infof("List of issue labels: %v", listofIssueLabels)
@@ -266,7 +388,7 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 	// check if it is approved
 	hasApproved := false
 	for _, l := range listofIssueLabels {
-		if *l.Name == LabelNameApproved {
+		if *l.Name == labelNameApproved {
 			hasApproved = true
 			break
 		}
@@ -275,15 +397,18 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 	// approved
 	if hasApproved {
 		// remove label approved
-		_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, LabelNameApproved)
+		_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, labelNameApproved)
 		if err != nil {
-			glog.Fatalf("Unable to remove label: %v err: %v", LabelNameApproved, err)
+			glog.Errorf("Unable to remove label: %v err: %v", labelNameApproved, err)
 		} else {
-			glog.Infof("Remove label successfully: %v", LabelNameApproved)
+			glog.Infof("Remove label successfully: %v", labelNameApproved)
 		}
 	} else {
-		glog.Infof("No label to remove: %v", LabelNameApproved)
+		glog.Infof("No label to remove: %v", labelNameApproved)
 	}
 
-	return nil
+	// only the approve of the commenter is cancelled, it is given again after it is cancelled
+	return util.UpdateGivers(client, owner, repo, number, func(g *util.Givers) {
+		g.Approved = util.RemoveUsers(g.Approved, commentAuthor)
+	})
 }
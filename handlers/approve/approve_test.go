@@ -0,0 +1,132 @@
+package approve
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/lgtm"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+// newCommentEvent returns the comment event of the user on the pr #1
+func newCommentEvent(user string, comment string) github.IssueCommentEvent {
+	return github.IssueCommentEvent{
+		Issue: &github.Issue{
+			Number:           github.Int(1),
+			State:            github.String("open"),
+			User:             &github.User{Login: github.String("author")},
+			PullRequestLinks: &github.PullRequestLinks{},
+		},
+		Comment: &github.IssueComment{Body: github.String(comment), User: &github.User{Login: github.String(user)}},
+		Repo:    &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+	}
+}
+
+//TestConcurrentAdd tests that the concurrent approve and lgtm handlers share one givers record, run it with -race
+func TestConcurrentAdd(t *testing.T) {
+	f := &fakeGithub{collaborators: make(map[string]bool)}
+	reviewers := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		reviewer := fmt.Sprintf("reviewer%d", i)
+		reviewers = append(reviewers, reviewer)
+		f.collaborators[reviewer] = true
+	}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	agent := plugins.Agent{
+		GithubClient: client,
+		Repository:   testOwners,
+		Config:       config.RepoConfig{Labels: config.Labels{Approved: "approved", Lgtm: "lgtm"}},
+	}
+
+	errs := make(chan error, 10)
+	var wg sync.WaitGroup
+	for _, approver := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func(approver string) {
+			defer wg.Done()
+			if err := Add(agent, newCommentEvent(approver, "/approve")); err != nil {
+				errs <- err
+			}
+		}(approver)
+	}
+	for _, reviewer := range reviewers {
+		wg.Add(1)
+		go func(reviewer string) {
+			defer wg.Done()
+			if err := lgtm.Add(agent, newCommentEvent(reviewer, "/lgtm")); err != nil {
+				errs <- err
+			}
+		}(reviewer)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	givers, _, err := util.LoadGivers(client, "test", "hello", 1)
+	if err != nil {
+		t.Fatalf("LoadGivers() error = %v", err)
+	}
+	want := util.Givers{Lgtm: reviewers, Approved: []string{"alice", "bob"}}
+	if !reflect.DeepEqual(givers, want) {
+		t.Errorf("givers = %+v, want %+v", givers, want)
+	}
+	if len(f.comments) != 1 {
+		t.Errorf("comments = %d, want one givers record", len(f.comments))
+	}
+	labels := make(map[string]bool)
+	for _, l := range f.labels {
+		labels[l.GetName()] = true
+	}
+	if !labels["approved"] || !labels["lgtm"] {
+		t.Errorf("labels = %v, want approved and lgtm", labels)
+	}
+}
+
+//TestCancel tests that approve cancel and lgtm cancel only remove the commenter from the givers record
+func TestCancel(t *testing.T) {
+	f := &fakeGithub{collaborators: map[string]bool{"carol": true}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	agent := plugins.Agent{
+		GithubClient: client,
+		Repository:   testOwners,
+		Config:       config.RepoConfig{Labels: config.Labels{Approved: "approved", Lgtm: "lgtm"}},
+	}
+	err := util.UpdateGivers(client, "test", "hello", 1, func(g *util.Givers) {
+		g.Lgtm = []string{"carol", "dave"}
+		g.Approved = []string{"alice", "bob"}
+	})
+	if err != nil {
+		t.Fatalf("UpdateGivers() error = %v", err)
+	}
+
+	if err := Cancel(agent, newCommentEvent("alice", "/approve cancel")); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if err := lgtm.Cancel(agent, newCommentEvent("carol", "/lgtm cancel")); err != nil {
+		t.Fatalf("lgtm.Cancel() error = %v", err)
+	}
+	givers, _, err := util.LoadGivers(client, "test", "hello", 1)
+	if err != nil {
+		t.Fatalf("LoadGivers() error = %v", err)
+	}
+	want := util.Givers{Lgtm: []string{"dave"}, Approved: []string{"bob"}}
+	if !reflect.DeepEqual(givers, want) {
+		t.Errorf("givers = %+v, want %+v", givers, want)
+	}
+}
@@ -0,0 +1,280 @@
+package approve
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+// NotifierMarker identifies the approval notifier comment of ci-bot
+const NotifierMarker = "<!-- ci-bot approval notifier -->"
+
+// DirState is the approval state of a changed directory
+type DirState struct {
+	// Dir is the changed directory, it is empty for the root directory
+	Dir string
+	// OwnersFile is the nearest OWNERS file which contains approvers, it is empty if there is no approver
+	OwnersFile string
+	// ApprovedBy are the approvers of the directory who have approved
+	ApprovedBy []string
+	// Pending are the changed files in the directory which are not approved yet
+	Pending []string
+	// Suggested are the approvers who can approve the pending files
+	Suggested []string
+}
+
+// Approved checks if the directory is approved
+func (d DirState) Approved() bool {
+	return len(d.Pending) == 0
+}
+
+// ApprovalState returns the states of the directories of the changed files.
+// Each file is approved by the approvers of its OWNERS files, whose filters may match only some files of a directory.
+// The pending files are covered by a small set of suggested approvers, see SuggestApprovers.
+func ApprovalState(r repository.Owners, files []string, approvers map[string]string, author string, seed int64) []DirState {
+	dirs := make(map[string][]string)
+	for _, f := range files {
+		dirs[dirOf(f)] = append(dirs[dirOf(f)], f)
+	}
+
+	states := make([]DirState, 0, len(dirs))
+	pending := make([]string, 0)
+	for dir, list := range dirs {
+		state := DirState{Dir: dir}
+		approvedBy := make(map[string]string)
+		for _, f := range list {
+			allApprovers := r.GetAllApprovers(f)
+			if state.OwnersFile == "" && len(allApprovers) > 0 {
+				state.OwnersFile = filepath.Join(r.GetApproversFilePath(f), repository.OwnersFileName)
+			}
+			approved := false
+			for k := range approvers {
+				if _, ok := allApprovers[k]; ok {
+					approvedBy[k] = k
+					approved = true
+				}
+			}
+			if !approved {
+				state.Pending = append(state.Pending, f)
+			}
+		}
+		for k := range approvedBy {
+			state.ApprovedBy = append(state.ApprovedBy, k)
+		}
+		sort.Strings(state.ApprovedBy)
+		sort.Strings(state.Pending)
+		pending = append(pending, state.Pending...)
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Dir < states[j].Dir })
+	sort.Strings(pending)
+
+	// the suggested approvers who can approve the pending files of each directory
+	suggested := SuggestApprovers(r, pending, author, seed)
+	for i := range states {
+		c := make(map[string]bool)
+		for _, f := range states[i].Pending {
+			for k := range candidates(r, f, author) {
+				c[k] = true
+			}
+		}
+		for _, k := range suggested {
+			if c[k] {
+				states[i].Suggested = append(states[i].Suggested, k)
+			}
+		}
+	}
+	return states
+}
+
+// Suggested returns the suggested approvers of all the directories
+func Suggested(states []DirState) []string {
+	set := make(map[string]bool)
+	for _, s := range states {
+		for _, k := range s.Suggested {
+			set[k] = true
+		}
+	}
+	list := make([]string, 0, len(set))
+	for k := range set {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// FormatNotification returns the body of the approval notifier comment.
+// The malformed owners files are ignored, and the owners files with warnings are used without their unknown keys.
+func FormatNotification(states []DirState, approved bool, malformed map[string]string, warnings map[string]string) string {
+	var b strings.Builder
+	b.WriteString(NotifierMarker + "\n")
+	if approved {
+		b.WriteString("**APPROVALNOTIFIER** This PR is **APPROVED**\n\n")
+	} else {
+		b.WriteString("**APPROVALNOTIFIER** This PR is **NOT APPROVED**\n\n")
+	}
+	if suggested := Suggested(states); len(suggested) > 0 {
+		fmt.Fprintf(&b, "Suggested approvers: %s\n\n", util.Mentions(util.Users(suggested), 0))
+	}
+	b.WriteString("| Directory | OWNERS | Approved by | Suggested approvers |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, s := range states {
+		dir := "/"
+		if s.Dir != "" {
+			dir = s.Dir
+		}
+		ownersFile := "-"
+		if s.OwnersFile != "" {
+			ownersFile = fmt.Sprintf("`%s`", s.OwnersFile)
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", dir, ownersFile, util.Mentions(util.Users(s.ApprovedBy), 0), util.Mentions(util.Users(s.Suggested), 0))
+	}
+	writeOwnersFiles(&b, "The following OWNERS files are malformed and they are ignored:", malformed)
+	writeOwnersFiles(&b, "The following OWNERS files have unknown keys which are ignored:", warnings)
+	b.WriteString("\nThe approvers of each directory comment `/approve` to approve or `/approve cancel` to cancel the approval.")
+	return b.String()
+}
+
+// writeOwnersFiles lists the owners files and their errors under the title
+func writeOwnersFiles(b *strings.Builder, title string, files map[string]string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n%s\n\n", title)
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(b, "- `%s`: %s\n", path, files[path])
+	}
+}
+
+// UpdateNotifier creates or edits the approval notifier comment of the pr.
+// The latest comment is counted if it is not listed yet.
+func UpdateNotifier(agent plugins.Agent, owner string, repo string, number int, latest *github.IssueComment) error {
+	ctx := context.Background()
+	client := agent.GithubClient
+	r := agent.Repository
+
+	issueComments, err := util.ListComments(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	if latest != nil && !containsComment(issueComments, latest) {
+		issueComments = append(issueComments, latest)
+	}
+	approvers, err := approvals(client, owner, repo, number, issueComments)
+	if err != nil {
+		return err
+	}
+
+	files, err := util.ListFileNames(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return err
+	}
+	owners, err := r.LoadOwners(pr.GetBase().GetRef())
+	if err != nil {
+		glog.Errorf("Unable to load owners. err: %v", err)
+		return err
+	}
+
+	// the pr number rotates the suggested approvers between the prs
+	states := ApprovalState(owners, files, approvers, pr.GetUser().GetLogin(), int64(number))
+	body := FormatNotification(states, util.HasLabel(pr.Labels, agent.Config.Labels.Approved), owners.GetMalformedOwnersFiles(), owners.GetOwnersFileWarnings())
+
+	// edit the notifier of ci-bot in place
+	bot, err := util.BotLogin(client)
+	if err != nil {
+		return err
+	}
+	for _, ic := range issueComments {
+		if !util.IsBotComment(ic, bot, NotifierMarker) {
+			continue
+		}
+		if ic.GetBody() == body {
+			return nil
+		}
+		_, _, err = client.Issues.EditComment(ctx, owner, repo, ic.GetID(), &github.IssueComment{Body: github.String(body)})
+		if err != nil {
+			glog.Errorf("Unable to edit the approval notifier of pr #%d. err: %v", number, err)
+		}
+		return err
+	}
+	_, _, err = client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		glog.Errorf("Unable to create the approval notifier of pr #%d. err: %v", number, err)
+	}
+	return err
+}
+
+// Approvers returns the users who approved and did not cancel in the comments
+func Approvers(issueComments []*github.IssueComment) map[string]string {
+	mapOfApprovers := map[string]string{}
+	for _, ic := range issueComments {
+		login := ic.GetUser().GetLogin()
+		// cancel approvers
+		if RegCancelApprove.MatchString(ic.GetBody()) {
+			delete(mapOfApprovers, login)
+			continue
+		}
+		// add approvers
+		if RegAddApprove.MatchString(ic.GetBody()) {
+			mapOfApprovers[login] = login
+		}
+	}
+	return mapOfApprovers
+}
+
+// approvals returns the approvers of the comments and the approving reviews.
+// An approving review counts as an /approve comment at the time it is submitted.
+func approvals(client *github.Client, owner string, repo string, number int, issueComments []*github.IssueComment) (map[string]string, error) {
+	reviews, err := util.ListReviews(client, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	list := append([]*github.IssueComment{}, issueComments...)
+	for _, r := range reviews {
+		if plugins.IsReviewState(r, plugins.ReviewStateApproved) {
+			list = append(list, &github.IssueComment{User: r.User, Body: github.String("/approve"), CreatedAt: r.SubmittedAt})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].GetCreatedAt().Before(list[j].GetCreatedAt())
+	})
+	return Approvers(list), nil
+}
+
+// dirOf returns the directory of the file, it is empty for the root directory
+func dirOf(file string) string {
+	dir := filepath.Dir(file)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// containsComment checks if the comment is in the list
+func containsComment(list []*github.IssueComment, c *github.IssueComment) bool {
+	for _, ic := range list {
+		if ic.GetID() == c.GetID() {
+			return true
+		}
+	}
+	return false
+}
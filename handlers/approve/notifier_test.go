@@ -0,0 +1,251 @@
+package approve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+)
+
+// fakeOwners serves the approvers of the directories
+type fakeOwners struct {
+	repository.Interface
+	repository.Owners
+	approvers map[string][]string
+	malformed map[string]string
+	warnings  map[string]string
+}
+
+func (f *fakeOwners) LoadOwners(branch string) (repository.Owners, error) {
+	return f, nil
+}
+
+func (f *fakeOwners) GetMalformedOwnersFiles() map[string]string {
+	return f.malformed
+}
+
+func (f *fakeOwners) GetOwnersFileWarnings() map[string]string {
+	return f.warnings
+}
+
+func (f *fakeOwners) GetRequiredReviewers(path string) map[string]string {
+	return map[string]string{}
+}
+
+func (f *fakeOwners) GetApproversFilePath(path string) string {
+	for d := path; ; d = dirOf(d) {
+		if _, ok := f.approvers[d]; ok || d == "" {
+			return d
+		}
+	}
+}
+
+func (f *fakeOwners) GetClosestApprovers(path string) map[string]string {
+	out := make(map[string]string)
+	for _, a := range f.approvers[f.GetApproversFilePath(path)] {
+		out[a] = a
+	}
+	return out
+}
+
+func (f *fakeOwners) GetAllApprovers(path string) map[string]string {
+	out := make(map[string]string)
+	for d := path; ; d = dirOf(d) {
+		for _, a := range f.approvers[d] {
+			out[a] = a
+		}
+		if d == "" {
+			return out
+		}
+	}
+}
+
+var testOwners = &fakeOwners{approvers: map[string][]string{
+	"":        {"root"},
+	"pkg/foo": {"alice", "bob"},
+	"pkg/bar": {"carol", "author"},
+}}
+
+//TestApprovalState tests the approval state of the changed directories
+func TestApprovalState(t *testing.T) {
+	files := []string{"README.md", "pkg/foo/a.go", "pkg/foo/b.go", "pkg/bar/c.go", "pkg/bar/baz/d.go"}
+	states := ApprovalState(testOwners, files, map[string]string{"alice": "alice"}, "author", 1)
+	want := []DirState{
+		{Dir: "", OwnersFile: "OWNERS", Pending: []string{"README.md"}, Suggested: []string{"root"}},
+		{Dir: "pkg/bar", OwnersFile: "pkg/bar/OWNERS", Pending: []string{"pkg/bar/c.go"}, Suggested: []string{"carol"}},
+		{Dir: "pkg/bar/baz", OwnersFile: "pkg/bar/OWNERS", Pending: []string{"pkg/bar/baz/d.go"}, Suggested: []string{"carol"}},
+		{Dir: "pkg/foo", OwnersFile: "pkg/foo/OWNERS", ApprovedBy: []string{"alice"}},
+	}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("ApprovalState() = %+v, want %+v", states, want)
+	}
+
+	// the root approvers approve everything
+	for _, s := range ApprovalState(testOwners, files, map[string]string{"root": "root"}, "author", 1) {
+		if !s.Approved() {
+			t.Errorf("%s is not approved by the root approver", s.Dir)
+		}
+	}
+}
+
+//TestApprovers tests that the cancelled approvals are not counted
+func TestApprovers(t *testing.T) {
+	comment := func(user string, body string) *github.IssueComment {
+		return &github.IssueComment{Body: github.String(body), User: &github.User{Login: github.String(user)}}
+	}
+	approvers := Approvers([]*github.IssueComment{
+		comment("alice", "/approve"),
+		comment("bob", "/approve"),
+		comment("bob", "/approve cancel"),
+		comment("carol", "looks good, will /approve later"),
+	})
+	if want := map[string]string{"alice": "alice"}; !reflect.DeepEqual(approvers, want) {
+		t.Errorf("Approvers() = %v, want %v", approvers, want)
+	}
+}
+
+// fakeGithub serves the pr and records the approval notifier, the givers record and the labels
+type fakeGithub struct {
+	lock          sync.Mutex
+	comments      []*github.IssueComment
+	reviews       []*github.PullRequestReview
+	edits         int
+	collaborators map[string]bool
+	labels        []*github.Label
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	switch path := r.URL.Path; {
+	case path == "/user":
+		w.Write([]byte(`{"login": "ci-bot"}`))
+	case path == "/repos/test/hello/issues/1/comments" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.comments)
+	case path == "/repos/test/hello/issues/1/comments":
+		var c github.IssueComment
+		json.NewDecoder(r.Body).Decode(&c)
+		c.ID = github.Int64(int64(100 + len(f.comments)))
+		c.User = &github.User{Login: github.String("ci-bot")}
+		f.comments = append(f.comments, &c)
+		json.NewEncoder(w).Encode(c)
+	case strings.HasPrefix(path, "/repos/test/hello/issues/comments/"):
+		var c github.IssueComment
+		json.NewDecoder(r.Body).Decode(&c)
+		for _, ic := range f.comments {
+			if strings.HasSuffix(path, fmt.Sprintf("/%d", ic.GetID())) {
+				ic.Body = c.Body
+				f.edits++
+			}
+		}
+		json.NewEncoder(w).Encode(c)
+	case path == "/repos/test/hello/pulls/1/reviews":
+		if f.reviews == nil {
+			f.reviews = []*github.PullRequestReview{}
+		}
+		json.NewEncoder(w).Encode(f.reviews)
+	case strings.HasPrefix(path, "/repos/test/hello/collaborators/"):
+		if f.collaborators[strings.TrimPrefix(path, "/repos/test/hello/collaborators/")] {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.NotFound(w, r)
+	case path == "/repos/test/hello/issues/1/labels" && r.Method == http.MethodPost:
+		var names []string
+		json.NewDecoder(r.Body).Decode(&names)
+		for _, n := range names {
+			f.labels = append(f.labels, &github.Label{Name: github.String(n)})
+		}
+		json.NewEncoder(w).Encode(f.labels)
+	case path == "/repos/test/hello/issues/1/labels":
+		if f.labels == nil {
+			f.labels = []*github.Label{}
+		}
+		json.NewEncoder(w).Encode(f.labels)
+	case path == "/repos/test/hello/pulls/1/files":
+		w.Write([]byte(`[{"filename": "pkg/foo/a.go"}]`))
+	case path == "/repos/test/hello/pulls/1":
+		w.Write([]byte(`{"number": 1, "user": {"login": "author"}, "base": {"ref": "master"}}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+//TestUpdateNotifier tests that the approval notifier is created once and edited in place, and that the copies of other users are not edited
+func TestUpdateNotifier(t *testing.T) {
+	forged := NotifierMarker + "\n**APPROVALNOTIFIER** This PR is **APPROVED**"
+	f := &fakeGithub{comments: []*github.IssueComment{
+		{ID: github.Int64(10), Body: github.String(forged), User: &github.User{Login: github.String("mallory")}},
+	}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	agent := plugins.Agent{GithubClient: client, Repository: testOwners, Config: config.RepoConfig{Labels: config.Labels{Approved: "approved"}}}
+
+	if err := UpdateNotifier(agent, "test", "hello", 1, nil); err != nil {
+		t.Fatalf("UpdateNotifier() error = %v", err)
+	}
+	// one of the approvers of pkg/foo is suggested
+	if len(f.comments) != 2 || !strings.Contains(f.comments[1].GetBody(), "| `pkg/foo` | `pkg/foo/OWNERS` |  | @") ||
+		strings.Contains(f.comments[1].GetBody(), "@alice @bob") {
+		t.Fatalf("comments = %v", f.comments)
+	}
+
+	approve := &github.IssueComment{ID: github.Int64(1), Body: github.String("/approve"), User: &github.User{Login: github.String("alice")}}
+	if err := UpdateNotifier(agent, "test", "hello", 1, approve); err != nil {
+		t.Fatalf("UpdateNotifier() error = %v", err)
+	}
+	if len(f.comments) != 2 || f.edits != 1 || !strings.Contains(f.comments[1].GetBody(), "| `pkg/foo` | `pkg/foo/OWNERS` | @alice |  |") {
+		t.Errorf("comments = %v edits = %d", f.comments, f.edits)
+	}
+	if f.comments[0].GetBody() != forged {
+		t.Errorf("the comment of another user is edited: %s", f.comments[0].GetBody())
+	}
+}
+
+//TestApprovals tests that the approving reviews count as /approve at the time they are submitted
+func TestApprovals(t *testing.T) {
+	at := func(minute int) *time.Time {
+		t := time.Date(2020, 1, 1, 0, minute, 0, 0, time.UTC)
+		return &t
+	}
+	user := func(login string) *github.User {
+		return &github.User{Login: github.String(login)}
+	}
+	f := &fakeGithub{reviews: []*github.PullRequestReview{
+		{User: user("alice"), State: github.String("APPROVED"), SubmittedAt: at(1)},
+		{User: user("bob"), State: github.String("APPROVED"), SubmittedAt: at(3)},
+		{User: user("carol"), State: github.String("CHANGES_REQUESTED"), SubmittedAt: at(4)},
+		{User: user("dave"), State: github.String("DISMISSED"), SubmittedAt: at(5)},
+	}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	comments := []*github.IssueComment{
+		{User: user("alice"), Body: github.String("/approve cancel"), CreatedAt: at(2)},
+		{User: user("bob"), Body: github.String("/approve cancel"), CreatedAt: at(2)},
+	}
+	approvers, err := approvals(client, "test", "hello", 1, comments)
+	if err != nil {
+		t.Fatalf("approvals() error = %v", err)
+	}
+	if want := map[string]string{"bob": "bob"}; !reflect.DeepEqual(approvers, want) {
+		t.Errorf("approvals() = %v, want %v", approvers, want)
+	}
+}
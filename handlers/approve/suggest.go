@@ -0,0 +1,81 @@
+package approve
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+)
+
+// candidates returns the approvers who can approve the path except the pr author.
+// The closest approvers are preferred, the parent approvers are used only if the author is the only closest approver.
+func candidates(r repository.Owners, path string, author string) map[string]bool {
+	out := make(map[string]bool)
+	for k := range r.GetClosestApprovers(path) {
+		if k != author {
+			out[k] = true
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	for k := range r.GetAllApprovers(path) {
+		if k != author {
+			out[k] = true
+		}
+	}
+	return out
+}
+
+// SuggestApprovers returns a small set of approvers who cover all the paths, e.g. the changed files or directories.
+// It picks the approver who covers the most uncovered paths each time, and the ties are
+// broken by an order which is shuffled by the seed, e.g. the pr number, to spread the load.
+func SuggestApprovers(r repository.Owners, paths []string, author string, seed int64) []string {
+	uncovered := make(map[string]map[string]bool)
+	all := make(map[string]bool)
+	for _, path := range paths {
+		c := candidates(r, path, author)
+		// nobody can approve the path
+		if len(c) == 0 {
+			continue
+		}
+		uncovered[path] = c
+		for k := range c {
+			all[k] = true
+		}
+	}
+
+	// the rotation order of the candidates
+	order := make([]string, 0, len(all))
+	for k := range all {
+		order = append(order, k)
+	}
+	sort.Strings(order)
+	rand.New(rand.NewSource(seed)).Shuffle(len(order), func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+
+	suggested := make([]string, 0)
+	for len(uncovered) > 0 {
+		best, bestCount := "", 0
+		for _, k := range order {
+			count := 0
+			for _, c := range uncovered {
+				if c[k] {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = k, count
+			}
+		}
+		suggested = append(suggested, best)
+		for path, c := range uncovered {
+			if c[best] {
+				delete(uncovered, path)
+			}
+		}
+	}
+	sort.Strings(suggested)
+	return suggested
+}
@@ -0,0 +1,60 @@
+package approve
+
+import (
+	"reflect"
+	"testing"
+)
+
+//TestSuggestApprovers tests that the suggested approvers cover all the directories
+func TestSuggestApprovers(t *testing.T) {
+	owners := &fakeOwners{approvers: map[string][]string{
+		"":      {"root"},
+		"pkg/a": {"x", "y"},
+		"pkg/b": {"y", "z"},
+		"pkg/c": {"z"},
+		"pkg/d": {"author"},
+	}}
+	tests := []struct {
+		name string
+		dirs []string
+		want [][]string
+	}{
+		{name: "shared approver", dirs: []string{"pkg/a", "pkg/b"}, want: [][]string{{"y"}}},
+		{name: "minimal set", dirs: []string{"pkg/a", "pkg/b", "pkg/c"}, want: [][]string{{"y", "z"}, {"x", "z"}}},
+		{name: "closest owners", dirs: []string{"pkg/c/e", "README.md"}, want: [][]string{{"root", "z"}}},
+		{name: "author is skipped", dirs: []string{"pkg/d"}, want: [][]string{{"root"}}},
+		{name: "no approver", dirs: nil, want: [][]string{{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(0); seed < 10; seed++ {
+				got := SuggestApprovers(owners, tt.dirs, "author", seed)
+				found := false
+				for _, want := range tt.want {
+					if reflect.DeepEqual(got, want) {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("SuggestApprovers() = %v with seed %d, want one of %v", got, seed, tt.want)
+				}
+			}
+		})
+	}
+}
+
+//TestSuggestApproversRotation tests that the suggested approvers are rotated by the seed
+func TestSuggestApproversRotation(t *testing.T) {
+	owners := &fakeOwners{approvers: map[string][]string{"pkg": {"a", "b", "c", "d", "e"}}}
+	seen := make(map[string]bool)
+	for seed := int64(0); seed < 20; seed++ {
+		got := SuggestApprovers(owners, []string{"pkg"}, "author", seed)
+		if len(got) != 1 {
+			t.Fatalf("SuggestApprovers() = %v, want one approver", got)
+		}
+		seen[got[0]] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("SuggestApprovers() always suggests %v", seen)
+	}
+}
@@ -2,11 +2,15 @@ package assign
 
 import (
 	"context"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
 )
 
 const (
@@ -19,6 +23,35 @@ var (
 	// ccRegexp parses and validates /cc commands, also used by blunderbuss
 	CCRegExp = regexp.MustCompile(`(?mi)^/(un)?cc(( +@?[-/\w]+?)*)\s*$`)
 )
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name:     "assign",
+		Help:     "/assign [@user] and /unassign [@user] add or remove the assignees of an issue or pr.",
+		Commands: []*regexp.Regexp{AssignRegExp},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return HandleComment(agent, event)
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePRAssign(context.Background(), event, agent.GithubClient)
+		},
+		// the commands in the pr body
+		PullRequestActions: []string{plugins.ActionOpened, plugins.ActionEdited, plugins.ActionReopened},
+	})
+	plugins.Register(plugins.Plugin{
+		Name:     "cc",
+		Help:     "/cc [@user] and /uncc [@user] request or remove the reviewers of a pr.",
+		Commands: []*regexp.Regexp{CCRegExp},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return ReviewerReqByComment(agent.GithubClient, event)
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePRReviewer(context.Background(), event, agent.GithubClient)
+		},
+		// the commands in the pr body
+		PullRequestActions: []string{plugins.ActionOpened, plugins.ActionEdited, plugins.ActionReopened},
+	})
+}
 //parseLogins function to parse the login id's
 func parseLogins(text string) []string {
 	var parts []string
@@ -35,7 +68,7 @@ func parseLogins(text string) []string {
 func AddAssignee(ctx context.Context, prEvent github.PullRequestEvent, client *github.Client, listOfAssignees []string) error {
 	_, _, err := client.Issues.AddAssignees(ctx, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name, *prEvent.Number, listOfAssignees)
 	if err != nil {
-		glog.Fatalf("Unable to Add Assignees: %v err: %v", listOfAssignees, err)
+		glog.Errorf("Unable to Add Assignees: %v err: %v", listOfAssignees, err)
 		return err
 	} else {
 		glog.Infof("Assignee added successfully: %v", listOfAssignees)
@@ -49,7 +82,7 @@ func RemoveReviewer(ctx context.Context, login, repoName string, prNum int, clie
 	reviewersList.Reviewers = listOfAssignees
 	  _, err := client.PullRequests.RemoveReviewers(ctx, login, repoName, prNum, reviewersList)
 	if err != nil {
-		glog.Fatalf("Cannot remove Reviewers: %v err: %v", listOfAssignees, err)
+		glog.Errorf("Cannot remove Reviewers: %v err: %v", listOfAssignees, err)
 		return err
 	}
 	glog.Infof("Removed Reviewers: %v", listOfAssignees)
@@ -63,7 +96,7 @@ func AddReviewer(ctx context.Context, login, repoName string, prNum int, client
 
 	ListRepoReviewers, _, err := client.PullRequests.ListReviewers(ctx, login, repoName, prNum, &listOpt)
 	if err != nil {
-		glog.Fatalf("Unable to get the review list : err: %v", err)
+		glog.Errorf("Unable to get the review list : err: %v", err)
 		return err
 	}
 	//check if the requested reviewer is already been assigned as reviewer
@@ -88,7 +121,7 @@ func AddReviewer(ctx context.Context, login, repoName string, prNum int, client
 
 	_, _, err = client.PullRequests.RequestReviewers(ctx, login, repoName, prNum, reviewersList)
 	if err != nil {
-		glog.Fatalf("Unable to Add Reviewers: %v err: %v", listOfAssignees, err)
+		glog.Errorf("Unable to Add Reviewers: %v err: %v", listOfAssignees, err)
 		return err
 	} else {
 		glog.Infof("Reviewers added successfully: %v", listOfAssignees)
@@ -100,7 +133,7 @@ func AddReviewer(ctx context.Context, login, repoName string, prNum int, client
 func RemoveAssignee(ctx context.Context, prEvent github.PullRequestEvent, client *github.Client, listOfAssignees []string) error {
 	_, _, err := client.Issues.RemoveAssignees(ctx, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name, *prEvent.Number, listOfAssignees)
 	if err != nil {
-		glog.Fatalf("Cannot remove Assignees: %v err: %v", listOfAssignees, err)
+		glog.Errorf("Cannot remove Assignees: %v err: %v", listOfAssignees, err)
 		return err
 	}
 	glog.Infof("Removed assignee: %v", listOfAssignees)
@@ -133,14 +166,14 @@ func GetMatchList(login string, matchesList [][]string)([]string, []string){
 //HandlePRAssign function to add assignee to the PR
 func HandlePRAssign(ctx context.Context, prEvent github.PullRequestEvent, client *github.Client) error {
 	//Get all matching assignee list for the PR Body
-	assigneeMatches := AssignRegExp.FindAllStringSubmatch(*prEvent.PullRequest.Body, -1)
+	assigneeMatches := AssignRegExp.FindAllStringSubmatch(prEvent.GetPullRequest().GetBody(), -1)
 	toAdd, toRemove := GetMatchList(*prEvent.PullRequest.User.Login, assigneeMatches)
 
 	if len(toAdd) > 0 {
 		glog.Infof("Going to add assignees %v:", toAdd)
 		err := AddAssignee(ctx, prEvent, client, toAdd)
 		if err != nil {
-			glog.Fatalf("AddAssignee is failed err: %v", err)
+			glog.Errorf("AddAssignee is failed err: %v", err)
 			return err
 		}
 	}
@@ -148,7 +181,7 @@ func HandlePRAssign(ctx context.Context, prEvent github.PullRequestEvent, client
 		glog.Infof("Going to add remove assignees %v:", toRemove)
 		err := RemoveAssignee(ctx, prEvent, client, toRemove)
 		if err != nil {
-			glog.Fatalf("RemoveAssignee is failed err: %v", err)
+			glog.Errorf("RemoveAssignee is failed err: %v", err)
 			return err
 		}
 	}
@@ -157,7 +190,7 @@ func HandlePRAssign(ctx context.Context, prEvent github.PullRequestEvent, client
 //HandlePRReviewer to handle add and remove reviewers to the PR
 func HandlePRReviewer(ctx context.Context, prEvent github.PullRequestEvent, client *github.Client) error {
 	//Get all matching assignee list for the PR Body
-	reviewMatches := CCRegExp.FindAllStringSubmatch(*prEvent.PullRequest.Body, -1)
+	reviewMatches := CCRegExp.FindAllStringSubmatch(prEvent.GetPullRequest().GetBody(), -1)
 	toAdd, toRemove := GetMatchList(*prEvent.PullRequest.User.Login, reviewMatches)
 
 	login := *prEvent.Repo.Owner.Login
@@ -168,7 +201,7 @@ func HandlePRReviewer(ctx context.Context, prEvent github.PullRequestEvent, clie
 		glog.Infof("Going to add assign reviewer %v:", toAdd)
 		err := AddReviewer(ctx, login, repoName, prNum, client, toAdd)
 		if err != nil {
-			glog.Fatalf("Adding reviewer is failed err: %v", err)
+			glog.Errorf("Adding reviewer is failed err: %v", err)
 			return err
 		}
 	}
@@ -176,7 +209,7 @@ func HandlePRReviewer(ctx context.Context, prEvent github.PullRequestEvent, clie
 		glog.Infof("Going to add remove assign reviewer %v:", toRemove)
 		err := RemoveReviewer(ctx, login, repoName, prNum, client, toRemove)
 		if err != nil {
-			glog.Fatalf("Remove reviewer is failed err: %v", err)
+			glog.Errorf("Remove reviewer is failed err: %v", err)
 			return err
 		}
 	}
@@ -196,7 +229,7 @@ func ReviewerReqByComment(client *github.Client, event github.IssueCommentEvent)
 		glog.Infof("Going to add reviewer from comment section%v:", toAdd)
 		err := AddReviewer(ctx, login, repoName, IssueNum, client, toAdd)
 		if err != nil {
-			glog.Fatalf("Adding reviewer is failed err: %v", err)
+			glog.Errorf("Adding reviewer is failed err: %v", err)
 			return err
 		}
 	}
@@ -204,13 +237,44 @@ func ReviewerReqByComment(client *github.Client, event github.IssueCommentEvent)
 		glog.Infof("Going to remove reviewer from comment section %v:", toRemove)
 		err := RemoveReviewer(ctx, login, repoName, IssueNum, client, toRemove)
 		if err != nil {
-			glog.Fatalf("Remove reviewer is failed err: %v", err)
+			glog.Errorf("Remove reviewer is failed err: %v", err)
 			return err
 		}
 	}
 	return nil
 }
 
+// HandleComment handles the assign commands and tells the commenter who can not be assigned
+func HandleComment(agent plugins.Agent, event github.IssueCommentEvent) error {
+	client := agent.GithubClient
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	toAdd, _ := GetMatchList(event.Comment.GetUser().GetLogin(), AssignRegExp.FindAllStringSubmatch(event.Comment.GetBody(), -1))
+
+	// github ignores the users who can not be assigned
+	listOfUnassignable := make([]string, 0)
+	for _, login := range toAdd {
+		isAssignee, _, err := client.Issues.IsAssignee(context.Background(), owner, repo, login)
+		if err != nil {
+			glog.Errorf("Unable to check if %s can be assigned. err: %v", login, err)
+			return err
+		}
+		if !isAssignee {
+			listOfUnassignable = append(listOfUnassignable, "@"+login)
+		}
+	}
+
+	err := Handle(client, event)
+	if err != nil {
+		return err
+	}
+	if len(listOfUnassignable) > 0 {
+		sort.Strings(listOfUnassignable)
+		return agent.Respond(event, fmt.Sprintf("%s can not be assigned, only the org members and the collaborators of %s can be assigned.",
+			strings.Join(listOfUnassignable, " "), repo))
+	}
+	return nil
+}
 
 // Handle event with assign
 func Handle(client *github.Client, event github.IssueCommentEvent) error {
@@ -240,7 +304,7 @@ func Handle(client *github.Client, event github.IssueCommentEvent) error {
 			if operation == Assign {
 				_, _, err := client.Issues.AddAssignees(ctx, *event.Repo.Owner.Login, *event.Repo.Name, *event.Issue.Number, listOfAssignees)
 				if err != nil {
-					glog.Fatalf("Unable to Add Assignees: %v err: %v", listOfAssignees, err)
+					glog.Errorf("Unable to Add Assignees: %v err: %v", listOfAssignees, err)
 					return err
 				} else {
 					glog.Infof("Assignee added successfully: %v", listOfAssignees)
@@ -248,7 +312,7 @@ func Handle(client *github.Client, event github.IssueCommentEvent) error {
 			} else if operation == Unassign {
 				_, _, err := client.Issues.RemoveAssignees(ctx, *event.Repo.Owner.Login, *event.Repo.Name, *event.Issue.Number, listOfAssignees)
 				if err != nil {
-					glog.Fatalf("Cannot remove Assignees: %v err: %v", listOfAssignees, err)
+					glog.Errorf("Cannot remove Assignees: %v err: %v", listOfAssignees, err)
 					return err
 				}
 				glog.Infof("Removed assignee: %v", listOfAssignees)
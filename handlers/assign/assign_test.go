@@ -1,6 +1,7 @@
 package assign
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -142,3 +143,19 @@ func TestHandleUnAssign(t *testing.T) {
 		}
 	})
 }
+
+//TestHandlePREmptyBody tests that a pr without description is handled
+func TestHandlePREmptyBody(t *testing.T) {
+	client := github.NewClient(&http.Client{Transport: &RewriteTransport{Response: &http.Response{}}})
+	event := github.PullRequestEvent{
+		Number:      github.Int(1),
+		PullRequest: &github.PullRequest{User: &github.User{Login: github.String("author")}},
+		Repo:        &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+	}
+	if err := HandlePRAssign(context.Background(), event, client); err != nil {
+		t.Errorf("HandlePRAssign() error = %v", err)
+	}
+	if err := HandlePRReviewer(context.Background(), event, client); err != nil {
+		t.Errorf("HandlePRReviewer() error = %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package blunderbuss
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+var (
+	// RegAutoCC is the regular expression of /auto-cc
+	RegAutoCC = regexp.MustCompile(`(?mi)^/auto-cc\s*$`)
+)
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "blunderbuss",
+		Help: "Requests the reviewers from the OWNERS files of the changed files when a pr is opened. " +
+			"/auto-cc requests them again.",
+		Commands: []*regexp.Regexp{RegAutoCC},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return HandleAutoCC(agent, event)
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePullRequest(agent, event)
+		},
+		PullRequestActions: []string{plugins.ActionOpened},
+	})
+}
+
+// File is a changed file of a pr
+type File struct {
+	Name string
+	// Changes is the number of the changed lines
+	Changes int
+}
+
+// SelectReviewers returns at most count reviewers of the changed files except the pr author.
+// Each reviewer is weighted by the changed lines which are covered by the closest OWNERS files of the reviewer,
+// and the reviewers with the same weight are ordered by the seed, e.g. the pr number, so that the selection
+// can be reproduced.
+func SelectReviewers(r repository.Owners, files []File, author string, count int, seed int64) []string {
+	weights := make(map[string]int)
+	for _, f := range files {
+		// the renamed or binary files count as one line
+		changes := f.Changes
+		if changes < 1 {
+			changes = 1
+		}
+		for k := range r.GetClosestReviewers(f.Name) {
+			if k != author {
+				weights[k] += changes
+			}
+		}
+	}
+
+	candidates := make([]string, 0, len(weights))
+	for k := range weights {
+		candidates = append(candidates, k)
+	}
+	sort.Strings(candidates)
+	rand.New(rand.NewSource(seed)).Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return weights[candidates[i]] > weights[candidates[j]]
+	})
+
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// HandlePullRequest requests the reviewers of a pr which is opened
+func HandlePullRequest(agent plugins.Agent, event github.PullRequestEvent) error {
+	_, err := RequestReviewers(agent, event.Repo.GetOwner().GetLogin(), event.Repo.GetName(), event.GetPullRequest())
+	return err
+}
+
+// HandleAutoCC requests the reviewers again
+func HandleAutoCC(agent plugins.Agent, event github.IssueCommentEvent) error {
+	if !event.Issue.IsPullRequest() || event.Issue.GetState() != "open" || !RegAutoCC.MatchString(event.Comment.GetBody()) {
+		return nil
+	}
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.Issue.GetNumber()
+
+	pr, _, err := agent.GithubClient.PullRequests.Get(context.Background(), owner, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return err
+	}
+	reviewers, err := RequestReviewers(agent, owner, repo, pr)
+	if err != nil {
+		return err
+	}
+	if len(reviewers) == 0 {
+		return agent.Respond(event, "no reviewer is found in the OWNERS files of the changed files.")
+	}
+	return nil
+}
+
+// RequestReviewers selects the reviewers of the pr and requests their reviews
+func RequestReviewers(agent plugins.Agent, owner string, repo string, pr *github.PullRequest) ([]string, error) {
+	ctx := context.Background()
+	client := agent.GithubClient
+	number := pr.GetNumber()
+
+	prChangedFiles, err := util.ListFiles(client, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(prChangedFiles))
+	for _, f := range prChangedFiles {
+		files = append(files, File{Name: f.GetFilename(), Changes: f.GetChanges()})
+	}
+
+	owners, err := agent.Repository.LoadOwners(pr.GetBase().GetRef())
+	if err != nil {
+		glog.Errorf("Unable to load owners. err: %v", err)
+		return nil, err
+	}
+	reviewers := SelectReviewers(owners, files, pr.GetUser().GetLogin(),
+		agent.Config.Blunderbuss.ReviewerCount, int64(number))
+	if len(reviewers) == 0 {
+		glog.Infof("No reviewer to request for pr #%d", number)
+		return nil, nil
+	}
+
+	_, _, err = client.PullRequests.RequestReviewers(ctx, owner, repo, number, github.ReviewersRequest{Reviewers: reviewers})
+	if err != nil {
+		glog.Errorf("Unable to request reviewers: %v err: %v", reviewers, err)
+		return nil, err
+	}
+	glog.Infof("Request reviewers %v for pr #%d", reviewers, number)
+	return reviewers, nil
+}
@@ -0,0 +1,95 @@
+package blunderbuss
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+)
+
+// fakeOwners serves the closest reviewers of the directories
+type fakeOwners struct {
+	repository.Owners
+	reviewers map[string][]string
+}
+
+func (f *fakeOwners) GetClosestReviewers(path string) map[string]string {
+	out := make(map[string]string)
+	for d := path; ; d = filepath.Dir(d) {
+		if d == "." {
+			d = ""
+		}
+		if list, ok := f.reviewers[d]; ok || d == "" {
+			for _, r := range list {
+				out[r] = r
+			}
+			return out
+		}
+	}
+}
+
+var testOwners = &fakeOwners{reviewers: map[string][]string{
+	"":        {"root"},
+	"pkg/foo": {"alice", "bob", "author"},
+	"pkg/bar": {"bob", "carol"},
+	"docs":    {"dave", "erin"},
+}}
+
+//TestSelectReviewers tests that the reviewers are weighted by the changed lines
+func TestSelectReviewers(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []File
+		count int
+		want  []string
+	}{
+		{
+			name:  "weighted",
+			files: []File{{Name: "pkg/foo/a.go", Changes: 10}, {Name: "pkg/bar/b.go", Changes: 20}, {Name: "README.md", Changes: 5}},
+			count: 2,
+			want:  []string{"bob", "carol"},
+		},
+		{
+			name:  "author is excluded",
+			files: []File{{Name: "pkg/foo/a.go", Changes: 10}},
+			count: 3,
+			want:  []string{"alice", "bob"},
+		},
+		{
+			name:  "count",
+			files: []File{{Name: "pkg/foo/a.go", Changes: 100}, {Name: "pkg/bar/b.go", Changes: 1}},
+			count: 1,
+			want:  []string{"bob"},
+		},
+		{
+			name:  "renamed file",
+			files: []File{{Name: "README.md"}},
+			count: 2,
+			want:  []string{"root"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectReviewers(testOwners, tt.files, "author", tt.count, 1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectReviewers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+//TestSelectReviewersSeed tests that the ties are broken by the seed
+func TestSelectReviewersSeed(t *testing.T) {
+	files := []File{{Name: "docs/a.md", Changes: 3}}
+	seen := make(map[string]bool)
+	for seed := int64(0); seed < 20; seed++ {
+		got := SelectReviewers(testOwners, files, "author", 1, seed)
+		if again := SelectReviewers(testOwners, files, "author", 1, seed); !reflect.DeepEqual(got, again) {
+			t.Fatalf("SelectReviewers() = %v and %v with the same seed %d", got, again, seed)
+		}
+		seen[got[0]] = true
+	}
+	if !seen["dave"] || !seen["erin"] {
+		t.Errorf("SelectReviewers() selects %v, want both dave and erin", seen)
+	}
+}
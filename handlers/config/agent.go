@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// LoadFunc loads and validates the configuration
+type LoadFunc func() (*Config, error)
+
+// NewAgent loads the configuration and returns an agent which holds it
+func NewAgent(load LoadFunc) (*Agent, error) {
+	c, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &Agent{
+		load:   load,
+		config: c,
+	}, nil
+}
+
+// Agent holds the current configuration which can be reloaded at runtime
+type Agent struct {
+	load LoadFunc
+
+	lock   sync.RWMutex
+	config *Config
+}
+
+// Config returns the current configuration
+func (a *Agent) Config() *Config {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.config
+}
+
+// Reload loads the configuration again. The current configuration is kept if the new one is invalid.
+func (a *Agent) Reload() error {
+	c, err := a.load()
+	if err != nil {
+		glog.Errorf("Failed to reload config, keep the current one: %v", err)
+		return err
+	}
+	a.lock.Lock()
+	a.config = c
+	a.lock.Unlock()
+	glog.Info("Reload config successfully")
+	return nil
+}
+
+// Watch reloads the configuration when the modification time of the file changes
+func (a *Agent) Watch(path string, period time.Duration, stop <-chan struct{}) {
+	if path == "" {
+		return
+	}
+	modTime := time.Time{}
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				glog.Errorf("Failed to stat config file %s: %v", path, err)
+				continue
+			}
+			if info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			glog.Infof("Config file %s is changed", path)
+			a.Reload()
+		}
+	}
+}
@@ -0,0 +1,624 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/golang/glog"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// DefaultLabelApproved is the default approve label name
+	DefaultLabelApproved = "approved"
+	// DefaultLabelLgtm is the default lgtm label name
+	DefaultLabelLgtm = "lgtm"
+	// DefaultTravisEndpoint is the default Travis-CI endpoint
+	DefaultTravisEndpoint = "https://api.travis-ci.com"
+	// DefaultGitLabEndpoint is the default GitLab endpoint
+	DefaultGitLabEndpoint = "https://gitlab.com"
+	// LabelNeedsOkToTest is added to the pull requests of the untrusted authors
+	LabelNeedsOkToTest = "needs-ok-to-test"
+	// LabelPrefixDoNotMerge is the prefix of the labels which block merging
+	LabelPrefixDoNotMerge = "do-not-merge/"
+	// LabelHold is added by /hold
+	LabelHold = LabelPrefixDoNotMerge + "hold"
+	// LabelWorkInProgress is added to the prs whose title starts with WIP or which are drafts
+	LabelWorkInProgress = LabelPrefixDoNotMerge + "work-in-progress"
+	// LabelStale is added to the issues and prs which are idle for stale_days
+	LabelStale = "lifecycle/stale"
+	// LabelRotten replaces LabelStale when the issues and prs are idle for rotten_days more
+	LabelRotten = "lifecycle/rotten"
+	// LabelFrozen exempts the issues and prs from becoming stale
+	LabelFrozen = "lifecycle/frozen"
+	// LabelPrefixMergeMethod is the prefix of the labels which select the merge method of a pull request.
+	// e.g. tide/merge-method-squash
+	LabelPrefixMergeMethod = "tide/merge-method-"
+	// DefaultCommitTitle is the default template of the merge commit title
+	DefaultCommitTitle = "{{.Title}} (#{{.Number}})"
+	// DefaultCommitBody is the default template of the merge commit message
+	DefaultCommitBody = "{{.Body}}\n\n{{range .ReviewedBy}}Reviewed-by: {{.}}\n{{end}}"
+	// DefaultTideStatusContext is the default status context of the merge queue
+	DefaultTideStatusContext = "tide"
+	// DefaultReviewerCount is the default number of the reviewers which are requested automatically
+	DefaultReviewerCount = 2
+	// DefaultRottenDays is the default number of the idle days before a stale item becomes rotten
+	DefaultRottenDays = 30
+	// DefaultCloseDays is the default number of the idle days before a rotten item is closed
+	DefaultCloseDays = 30
+)
+
+// methods to update a pull request when its base branch moves
+const (
+	UpdateMethodRetest = "retest"
+	// UpdateMethodMerge merges the base branch into the head branch of the pull request
+	UpdateMethodMerge = "merge"
+	// updateMethodRebase is the deprecated name of UpdateMethodMerge
+	updateMethodRebase = "rebase"
+)
+
+// backends which read the OWNERS files
+const (
+	// OwnersBackendGit reads the OWNERS files from a local git mirror
+	OwnersBackendGit = "git"
+	// OwnersBackendAPI reads the OWNERS files with the GitHub Git Trees API, it does not need git
+	OwnersBackendAPI = "api"
+)
+
+// CI providers which run the jobs of the pull requests
+const (
+	CIProviderTravis        = "travis"
+	CIProviderGitHubActions = "github-actions"
+	CIProviderJenkins       = "jenkins"
+	CIProviderGitLab        = "gitlab"
+)
+
+// merge methods which are supported by github
+const (
+	MergeMethodMerge  = "merge"
+	MergeMethodSquash = "squash"
+	MergeMethodRebase = "rebase"
+)
+
+// Config defines the content format of the configuration file. e.g.
+//
+//	plugins: [label, assign, cc, retest, approve, lgtm]
+//	merge_method: merge
+//	repos:
+//	  kubeedge/kubeedge:
+//	    merge_method: squash
+//	    travis:
+//	      repo_name: kubeedge%2Fkubeedge
+//
+// The settings of the top level apply to all the repositories.
+// The settings in orgs and repos override them for an org or a repository.
+type Config struct {
+	RepoConfig `yaml:",inline"`
+
+	// Orgs contains the settings per org. e.g. kubeedge
+	Orgs map[string]RepoConfig `yaml:"orgs,omitempty"`
+	// Repos contains the settings per repository. e.g. kubeedge/kubeedge
+	// They are loaded at startup, the others are loaded on their first webhook event.
+	Repos map[string]RepoConfig `yaml:"repos,omitempty"`
+}
+
+// RepoConfig contains the settings of a repository
+type RepoConfig struct {
+	// Plugins are the names of the enabled plugins, all the plugins are enabled if it is empty
+	Plugins []string `yaml:"plugins,omitempty"`
+	// Labels are the label names used by the plugins
+	Labels Labels `yaml:"labels,omitempty"`
+	// MergeMethod is one of merge, squash and rebase
+	MergeMethod string `yaml:"merge_method,omitempty"`
+	// BranchMergeMethods are the merge methods of the base branches, they override MergeMethod
+	BranchMergeMethods map[string]string `yaml:"branch_merge_methods,omitempty"`
+	// CommitTemplate contains the templates of the merge commit
+	CommitTemplate CommitTemplate `yaml:"commit_template,omitempty"`
+	// CIProvider is one of travis, github-actions, jenkins and gitlab. It runs the /test and /retest commands.
+	CIProvider string `yaml:"ci_provider,omitempty"`
+	// Travis contains the Travis-CI settings
+	Travis Travis `yaml:"travis,omitempty"`
+	// Jenkins contains the Jenkins settings
+	Jenkins Jenkins `yaml:"jenkins,omitempty"`
+	// GitLab contains the GitLab CI settings
+	GitLab GitLab `yaml:"gitlab,omitempty"`
+	// CommandAuthors are the users who are allowed to comment commands, everyone is allowed if it is empty
+	CommandAuthors []string `yaml:"command_authors,omitempty"`
+	// BlockingLabels are the labels which block merging
+	BlockingLabels []string `yaml:"blocking_labels,omitempty"`
+	// Tide contains the merge queue settings
+	Tide Tide `yaml:"tide,omitempty"`
+	// Trigger contains the settings of the trusted authors whose pull requests are tested
+	Trigger Trigger `yaml:"trigger,omitempty"`
+	// Blunderbuss contains the settings of the automatic reviewer requests
+	Blunderbuss Blunderbuss `yaml:"blunderbuss,omitempty"`
+	// Lgtm contains the settings of the lgtm label
+	Lgtm Lgtm `yaml:"lgtm,omitempty"`
+	// Stale contains the settings of the idle issues and pull requests
+	Stale Stale `yaml:"stale,omitempty"`
+	// OwnersBackend is git or api. It reads the OWNERS files of the repository, which is loaded with it on the first use.
+	OwnersBackend string `yaml:"owners_backend,omitempty"`
+}
+
+// Labels defines the label names
+type Labels struct {
+	Approved string `yaml:"approved,omitempty"`
+	Lgtm     string `yaml:"lgtm,omitempty"`
+}
+
+// CommitTemplate defines the text/template templates of the merge commit. The templates are executed with
+// .Title, .Body, .Number and .Author of the pull request, and .LgtmBy, .ApprovedBy and .ReviewedBy,
+// whose lgtm, approve and either of them were accepted.
+type CommitTemplate struct {
+	Title string `yaml:"title,omitempty"`
+	Body  string `yaml:"body,omitempty"`
+}
+
+// Travis defines the Travis-CI settings
+type Travis struct {
+	Endpoint string `yaml:"endpoint,omitempty"`
+	Token    string `yaml:"token,omitempty"`
+	// RepoName of the CI build. e.g. kubeedge%2Fkubeedge
+	RepoName string `yaml:"repo_name,omitempty"`
+	// JobNames are the job names by the job order of the builds, the first one is the name of the job x.1.
+	// The job names in .travis.yml are used if it is empty.
+	JobNames []string `yaml:"job_names,omitempty"`
+}
+
+// Jenkins defines the Jenkins settings
+type Jenkins struct {
+	Endpoint string `yaml:"endpoint,omitempty"`
+	User     string `yaml:"user,omitempty"`
+	Token    string `yaml:"token,omitempty"`
+	// Jobs are the names of the parameterized jobs which test the pull requests.
+	// They are triggered with the PR_NUMBER, HEAD_SHA and HEAD_REF parameters.
+	Jobs []string `yaml:"jobs,omitempty"`
+}
+
+// GitLab defines the GitLab CI settings
+type GitLab struct {
+	Endpoint string `yaml:"endpoint,omitempty"`
+	Token    string `yaml:"token,omitempty"`
+	// Project is the id or the path of the GitLab project which runs the pipelines. e.g. kubeedge%2Fkubeedge
+	Project string `yaml:"project,omitempty"`
+}
+
+// Tide defines the merge queue settings
+type Tide struct {
+	// RequiredContexts are the status contexts which must be success, all the contexts must be success if it is empty
+	RequiredContexts []string `yaml:"required_contexts,omitempty"`
+	// UpdateMethod is retest or merge. It is used when the base branch moves.
+	UpdateMethod string `yaml:"update_method,omitempty"`
+	// StatusContext is the status context which explains why a pull request is not merging yet
+	StatusContext string `yaml:"status_context,omitempty"`
+}
+
+// Trigger defines who is trusted to run the CI jobs. The members of the org and the collaborators
+// of the repository are trusted, the pull requests of the others need /ok-to-test.
+type Trigger struct {
+	// TrustedOrgs are the other orgs whose members are trusted
+	TrustedOrgs []string `yaml:"trusted_orgs,omitempty"`
+	// ReapproveOnPush requires /ok-to-test again when an untrusted author pushes new commits.
+	// It is a pointer so that an org or a repository can turn it off.
+	ReapproveOnPush *bool `yaml:"reapprove_on_push,omitempty"`
+}
+
+// GetReapproveOnPush returns ReapproveOnPush, it is false if it is not set
+func (t Trigger) GetReapproveOnPush() bool {
+	return t.ReapproveOnPush != nil && *t.ReapproveOnPush
+}
+
+// Lgtm defines how the lgtm label is kept when new commits are pushed
+type Lgtm struct {
+	// StoreTreeHash records the tree of the head commit which gets lgtm. The lgtm label is kept
+	// when the new head commit has the same tree, e.g. the commits are squashed or their messages are amended.
+	// A rebase onto a moved base branch changes the tree, so it removes the label.
+	// It is a pointer so that an org or a repository can turn it off.
+	StoreTreeHash *bool `yaml:"store_tree_hash,omitempty"`
+}
+
+// GetStoreTreeHash returns StoreTreeHash, it is false if it is not set
+func (l Lgtm) GetStoreTreeHash() bool {
+	return l.StoreTreeHash != nil && *l.StoreTreeHash
+}
+
+// Blunderbuss defines how the reviewers are picked from the OWNERS files when a pull request is opened
+type Blunderbuss struct {
+	// ReviewerCount is the number of the reviewers to request
+	ReviewerCount int `yaml:"reviewer_count,omitempty"`
+}
+
+// Stale defines when the idle issues and pull requests are marked as stale and rotten, and then closed.
+// The days are counted from their last update, which the labels and the comments of ci-bot reset.
+type Stale struct {
+	// StaleDays is the number of the idle days before an item gets the lifecycle/stale label, it is disabled if it is 0.
+	// It is a pointer so that an org or a repository can disable it with 0.
+	StaleDays *int `yaml:"stale_days,omitempty"`
+	// RottenDays is the number of the idle days before a stale item gets the lifecycle/rotten label
+	RottenDays int `yaml:"rotten_days,omitempty"`
+	// CloseDays is the number of the idle days before a rotten item is closed
+	CloseDays int `yaml:"close_days,omitempty"`
+}
+
+// GetStaleDays returns StaleDays, it is 0 if it is not set
+func (s Stale) GetStaleDays() int {
+	if s.StaleDays == nil {
+		return 0
+	}
+	return *s.StaleDays
+}
+
+// Bool returns a pointer to the bool value, e.g. for ReapproveOnPush
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Int returns a pointer to the int value, e.g. for StaleDays
+func Int(v int) *int {
+	return &v
+}
+
+// Load reads the configuration file
+func Load(path string) (*Config, error) {
+	c := &Config{}
+	if path == "" {
+		return c, nil
+	}
+
+	glog.Infof("Load config file: %s", path)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		glog.Errorf("Failed to read the config file: %s", path)
+		return nil, err
+	}
+	// json is a subset of yaml so json files are accepted as well
+	err = yaml.UnmarshalStrict(b, c)
+	if err != nil {
+		glog.Errorf("Failed to unmarshal %s", path)
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the settings
+func (c *Config) Validate() error {
+	err := c.RepoConfig.validate()
+	if err != nil {
+		return err
+	}
+	for org, rc := range c.Orgs {
+		if org == "" || strings.Contains(org, "/") {
+			return fmt.Errorf("invalid org %q", org)
+		}
+		err = rc.validate()
+		if err != nil {
+			return fmt.Errorf("org %s: %v", org, err)
+		}
+	}
+	for repo, rc := range c.Repos {
+		strs := strings.Split(repo, "/")
+		if len(strs) != 2 || strs[0] == "" || strs[1] == "" {
+			return fmt.Errorf("invalid repo %q, it should be org/repo", repo)
+		}
+		err = rc.validate()
+		if err != nil {
+			return fmt.Errorf("repo %s: %v", repo, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the settings of a repository
+func (rc RepoConfig) validate() error {
+	if rc.MergeMethod != "" && !IsMergeMethod(rc.MergeMethod) {
+		return fmt.Errorf("invalid merge_method %q", rc.MergeMethod)
+	}
+	for branch, method := range rc.BranchMergeMethods {
+		if !IsMergeMethod(method) {
+			return fmt.Errorf("invalid merge method %q of branch %s", method, branch)
+		}
+	}
+	templates := map[string]string{
+		"title": rc.CommitTemplate.Title,
+		"body":  rc.CommitTemplate.Body,
+	}
+	for name, text := range templates {
+		_, err := template.New(name).Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid commit_template %s: %v", name, err)
+		}
+	}
+	switch rc.Tide.UpdateMethod {
+	case "", UpdateMethodRetest, UpdateMethodMerge, updateMethodRebase:
+	default:
+		return fmt.Errorf("invalid tide update_method %q", rc.Tide.UpdateMethod)
+	}
+	switch rc.OwnersBackend {
+	case "", OwnersBackendGit, OwnersBackendAPI:
+	default:
+		return fmt.Errorf("invalid owners_backend %q", rc.OwnersBackend)
+	}
+	if rc.Blunderbuss.ReviewerCount < 0 {
+		return fmt.Errorf("invalid blunderbuss reviewer_count %d", rc.Blunderbuss.ReviewerCount)
+	}
+	days := map[string]int{
+		"stale_days":  rc.Stale.GetStaleDays(),
+		"rotten_days": rc.Stale.RottenDays,
+		"close_days":  rc.Stale.CloseDays,
+	}
+	for name, n := range days {
+		if n < 0 {
+			return fmt.Errorf("invalid stale %s %d", name, n)
+		}
+	}
+	switch rc.CIProvider {
+	case "", CIProviderTravis, CIProviderGitHubActions, CIProviderJenkins, CIProviderGitLab:
+	default:
+		return fmt.Errorf("invalid ci_provider %q", rc.CIProvider)
+	}
+	endpoints := map[string]string{
+		"travis":  rc.Travis.Endpoint,
+		"jenkins": rc.Jenkins.Endpoint,
+		"gitlab":  rc.GitLab.Endpoint,
+	}
+	for name, endpoint := range endpoints {
+		if endpoint == "" {
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid %s endpoint %q", name, endpoint)
+		}
+	}
+	return nil
+}
+
+// PluginNames returns all the plugin names in the configuration
+func (c *Config) PluginNames() []string {
+	names := append([]string{}, c.Plugins...)
+	for _, rc := range c.Orgs {
+		names = append(names, rc.Plugins...)
+	}
+	for _, rc := range c.Repos {
+		names = append(names, rc.Plugins...)
+	}
+	return names
+}
+
+// RepoConfigFor returns the settings of org/repo
+func (c *Config) RepoConfigFor(org string, repo string) RepoConfig {
+	rc := c.RepoConfig
+	if oc, ok := c.Orgs[org]; ok {
+		rc = rc.override(oc)
+	}
+	if orc, ok := c.Repos[fmt.Sprintf("%s/%s", org, repo)]; ok {
+		rc = rc.override(orc)
+	}
+
+	// defaults
+	if rc.Labels.Approved == "" {
+		rc.Labels.Approved = DefaultLabelApproved
+	}
+	if rc.Labels.Lgtm == "" {
+		rc.Labels.Lgtm = DefaultLabelLgtm
+	}
+	if rc.MergeMethod == "" {
+		rc.MergeMethod = MergeMethodMerge
+	}
+	if rc.CommitTemplate.Title == "" {
+		rc.CommitTemplate.Title = DefaultCommitTitle
+	}
+	if rc.CommitTemplate.Body == "" {
+		rc.CommitTemplate.Body = DefaultCommitBody
+	}
+	if rc.CIProvider == "" {
+		rc.CIProvider = CIProviderTravis
+	}
+	if rc.Travis.Endpoint == "" {
+		rc.Travis.Endpoint = DefaultTravisEndpoint
+	}
+	if rc.GitLab.Endpoint == "" {
+		rc.GitLab.Endpoint = DefaultGitLabEndpoint
+	}
+	if rc.Tide.UpdateMethod == "" {
+		rc.Tide.UpdateMethod = UpdateMethodRetest
+	} else if rc.Tide.UpdateMethod == updateMethodRebase {
+		rc.Tide.UpdateMethod = UpdateMethodMerge
+	}
+	if rc.Tide.StatusContext == "" {
+		rc.Tide.StatusContext = DefaultTideStatusContext
+	}
+	if rc.Blunderbuss.ReviewerCount == 0 {
+		rc.Blunderbuss.ReviewerCount = DefaultReviewerCount
+	}
+	if rc.OwnersBackend == "" {
+		rc.OwnersBackend = OwnersBackendGit
+	}
+	if rc.Stale.RottenDays == 0 {
+		rc.Stale.RottenDays = DefaultRottenDays
+	}
+	if rc.Stale.CloseDays == 0 {
+		rc.Stale.CloseDays = DefaultCloseDays
+	}
+	if rc.Travis.RepoName == "" {
+		// e.g. kubeedge%2Fkubeedge
+		rc.Travis.RepoName = url.QueryEscape(fmt.Sprintf("%s/%s", org, repo))
+	}
+	if rc.GitLab.Project == "" {
+		rc.GitLab.Project = url.QueryEscape(fmt.Sprintf("%s/%s", org, repo))
+	}
+	return rc
+}
+
+// override returns the settings overridden by the non-empty fields of o.
+// The pointer fields are overridden when they are set, even to false or 0.
+func (rc RepoConfig) override(o RepoConfig) RepoConfig {
+	if len(o.Plugins) > 0 {
+		rc.Plugins = o.Plugins
+	}
+	if o.Labels.Approved != "" {
+		rc.Labels.Approved = o.Labels.Approved
+	}
+	if o.Labels.Lgtm != "" {
+		rc.Labels.Lgtm = o.Labels.Lgtm
+	}
+	if o.MergeMethod != "" {
+		rc.MergeMethod = o.MergeMethod
+	}
+	if len(o.BranchMergeMethods) > 0 {
+		rc.BranchMergeMethods = o.BranchMergeMethods
+	}
+	if o.CommitTemplate.Title != "" {
+		rc.CommitTemplate.Title = o.CommitTemplate.Title
+	}
+	if o.CommitTemplate.Body != "" {
+		rc.CommitTemplate.Body = o.CommitTemplate.Body
+	}
+	if o.CIProvider != "" {
+		rc.CIProvider = o.CIProvider
+	}
+	if o.Travis.Endpoint != "" {
+		rc.Travis.Endpoint = o.Travis.Endpoint
+	}
+	if o.Travis.Token != "" {
+		rc.Travis.Token = o.Travis.Token
+	}
+	if o.Travis.RepoName != "" {
+		rc.Travis.RepoName = o.Travis.RepoName
+	}
+	if len(o.Travis.JobNames) > 0 {
+		rc.Travis.JobNames = o.Travis.JobNames
+	}
+	if o.Jenkins.Endpoint != "" {
+		rc.Jenkins.Endpoint = o.Jenkins.Endpoint
+	}
+	if o.Jenkins.User != "" {
+		rc.Jenkins.User = o.Jenkins.User
+	}
+	if o.Jenkins.Token != "" {
+		rc.Jenkins.Token = o.Jenkins.Token
+	}
+	if len(o.Jenkins.Jobs) > 0 {
+		rc.Jenkins.Jobs = o.Jenkins.Jobs
+	}
+	if o.GitLab.Endpoint != "" {
+		rc.GitLab.Endpoint = o.GitLab.Endpoint
+	}
+	if o.GitLab.Token != "" {
+		rc.GitLab.Token = o.GitLab.Token
+	}
+	if o.GitLab.Project != "" {
+		rc.GitLab.Project = o.GitLab.Project
+	}
+	if len(o.CommandAuthors) > 0 {
+		rc.CommandAuthors = o.CommandAuthors
+	}
+	if len(o.BlockingLabels) > 0 {
+		rc.BlockingLabels = o.BlockingLabels
+	}
+	if len(o.Tide.RequiredContexts) > 0 {
+		rc.Tide.RequiredContexts = o.Tide.RequiredContexts
+	}
+	if o.Tide.UpdateMethod != "" {
+		rc.Tide.UpdateMethod = o.Tide.UpdateMethod
+	}
+	if o.Tide.StatusContext != "" {
+		rc.Tide.StatusContext = o.Tide.StatusContext
+	}
+	if len(o.Trigger.TrustedOrgs) > 0 {
+		rc.Trigger.TrustedOrgs = o.Trigger.TrustedOrgs
+	}
+	if o.Trigger.ReapproveOnPush != nil {
+		rc.Trigger.ReapproveOnPush = o.Trigger.ReapproveOnPush
+	}
+	if o.Blunderbuss.ReviewerCount != 0 {
+		rc.Blunderbuss.ReviewerCount = o.Blunderbuss.ReviewerCount
+	}
+	if o.Lgtm.StoreTreeHash != nil {
+		rc.Lgtm.StoreTreeHash = o.Lgtm.StoreTreeHash
+	}
+	if o.OwnersBackend != "" {
+		rc.OwnersBackend = o.OwnersBackend
+	}
+	if o.Stale.StaleDays != nil {
+		rc.Stale.StaleDays = o.Stale.StaleDays
+	}
+	if o.Stale.RottenDays != 0 {
+		rc.Stale.RottenDays = o.Stale.RottenDays
+	}
+	if o.Stale.CloseDays != 0 {
+		rc.Stale.CloseDays = o.Stale.CloseDays
+	}
+	return rc
+}
+
+// IsMergeMethod checks if the method is one of merge, squash and rebase
+func IsMergeMethod(method string) bool {
+	switch method {
+	case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return true
+	}
+	return false
+}
+
+// MergeMethodOf returns the merge method of the base branch
+func (rc RepoConfig) MergeMethodOf(branch string) string {
+	if method, ok := rc.BranchMergeMethods[branch]; ok {
+		return method
+	}
+	return rc.MergeMethod
+}
+
+// IsCommandAuthor checks if the user is allowed to comment commands
+func (rc RepoConfig) IsCommandAuthor(user string) bool {
+	if len(rc.CommandAuthors) == 0 {
+		return true
+	}
+	for _, a := range rc.CommandAuthors {
+		if strings.EqualFold(a, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseEnabledPlugins parses the "org/repo=name1,name2", "org=name1,name2" or "name1,name2" flag values
+// and appends them to the plugin settings
+func (c *Config) ParseEnabledPlugins(values []string) {
+	for _, v := range values {
+		key := ""
+		names := v
+		if i := strings.Index(v, "="); i >= 0 {
+			key = strings.TrimSpace(v[:i])
+			names = v[i+1:]
+		}
+		list := make([]string, 0)
+		for _, n := range strings.Split(names, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				list = append(list, n)
+			}
+		}
+
+		switch {
+		case key == "":
+			c.Plugins = append(c.Plugins, list...)
+		case strings.Contains(key, "/"):
+			if c.Repos == nil {
+				c.Repos = make(map[string]RepoConfig)
+			}
+			rc := c.Repos[key]
+			rc.Plugins = append(rc.Plugins, list...)
+			c.Repos[key] = rc
+		default:
+			if c.Orgs == nil {
+				c.Orgs = make(map[string]RepoConfig)
+			}
+			rc := c.Orgs[key]
+			rc.Plugins = append(rc.Plugins, list...)
+			c.Orgs[key] = rc
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+plugins: [label, lgtm]
+merge_method: merge
+travis:
+  token: default-token
+orgs:
+  test:
+    labels:
+      lgtm: looks-good
+repos:
+  test/hello:
+    plugins: [lgtm, approve]
+    merge_method: squash
+    branch_merge_methods:
+      release-1.0: rebase
+    commit_template:
+      title: "{{.Title}}"
+    command_authors: [alice]
+    ci_provider: gitlab
+    blunderbuss:
+      reviewer_count: 3
+    owners_backend: api
+    lgtm:
+      store_tree_hash: true
+    stale:
+      stale_days: 90
+      close_days: 7
+    tide:
+      update_method: rebase
+`
+
+// writeConfig writes the content into a tmp config file
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+//TestRepoConfigFor tests the settings resolved per repository
+func TestRepoConfigFor(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		org  string
+		repo string
+		want RepoConfig
+	}{
+		{
+			name: "repository",
+			org:  "test",
+			repo: "hello",
+			want: RepoConfig{
+				Plugins:            []string{"lgtm", "approve"},
+				Labels:             Labels{Approved: DefaultLabelApproved, Lgtm: "looks-good"},
+				MergeMethod:        MergeMethodSquash,
+				BranchMergeMethods: map[string]string{"release-1.0": MergeMethodRebase},
+				CommitTemplate:     CommitTemplate{Title: "{{.Title}}", Body: DefaultCommitBody},
+				CIProvider:         CIProviderGitLab,
+				Travis:             Travis{Endpoint: DefaultTravisEndpoint, Token: "default-token", RepoName: "test%2Fhello"},
+				GitLab:             GitLab{Endpoint: DefaultGitLabEndpoint, Project: "test%2Fhello"},
+				CommandAuthors:     []string{"alice"},
+				Tide:               Tide{UpdateMethod: UpdateMethodMerge, StatusContext: DefaultTideStatusContext},
+				Blunderbuss:        Blunderbuss{ReviewerCount: 3},
+				Lgtm:               Lgtm{StoreTreeHash: Bool(true)},
+				Stale:              Stale{StaleDays: Int(90), RottenDays: DefaultRottenDays, CloseDays: 7},
+				OwnersBackend:      OwnersBackendAPI,
+			},
+		},
+		{
+			name: "default",
+			org:  "other",
+			repo: "world",
+			want: RepoConfig{
+				Plugins:        []string{"label", "lgtm"},
+				Labels:         Labels{Approved: DefaultLabelApproved, Lgtm: DefaultLabelLgtm},
+				MergeMethod:    MergeMethodMerge,
+				CommitTemplate: CommitTemplate{Title: DefaultCommitTitle, Body: DefaultCommitBody},
+				CIProvider:     CIProviderTravis,
+				Travis:         Travis{Endpoint: DefaultTravisEndpoint, Token: "default-token", RepoName: "other%2Fworld"},
+				GitLab:         GitLab{Endpoint: DefaultGitLabEndpoint, Project: "other%2Fworld"},
+				Tide:           Tide{UpdateMethod: UpdateMethodRetest, StatusContext: DefaultTideStatusContext},
+				Blunderbuss:    Blunderbuss{ReviewerCount: DefaultReviewerCount},
+				Stale:          Stale{RottenDays: DefaultRottenDays, CloseDays: DefaultCloseDays},
+				OwnersBackend:  OwnersBackendGit,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.RepoConfigFor(tt.org, tt.repo); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RepoConfigFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	rc := c.RepoConfigFor("test", "hello")
+	if rc.MergeMethodOf("release-1.0") != MergeMethodRebase || rc.MergeMethodOf("master") != MergeMethodSquash {
+		t.Errorf("MergeMethodOf() does not match branch_merge_methods %v", rc.BranchMergeMethods)
+	}
+	if !rc.IsCommandAuthor("Alice") || rc.IsCommandAuthor("bob") {
+		t.Errorf("IsCommandAuthor() does not match command_authors %v", rc.CommandAuthors)
+	}
+}
+
+//TestOverrideOff tests that an org or a repository can turn off the settings which are enabled globally
+func TestOverrideOff(t *testing.T) {
+	path := writeConfig(t, `
+trigger:
+  reapprove_on_push: true
+lgtm:
+  store_tree_hash: true
+stale:
+  stale_days: 90
+orgs:
+  test:
+    lgtm:
+      store_tree_hash: false
+repos:
+  test/hello:
+    trigger:
+      reapprove_on_push: false
+    stale:
+      stale_days: 0
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	tests := []struct {
+		repo          string
+		wantReapprove bool
+		wantTreeHash  bool
+		wantStaleDays int
+	}{
+		{repo: "test/hello"},
+		{repo: "test/world", wantReapprove: true, wantStaleDays: 90},
+		{repo: "other/world", wantReapprove: true, wantTreeHash: true, wantStaleDays: 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			strs := strings.Split(tt.repo, "/")
+			rc := c.RepoConfigFor(strs[0], strs[1])
+			if rc.Trigger.GetReapproveOnPush() != tt.wantReapprove || rc.Lgtm.GetStoreTreeHash() != tt.wantTreeHash ||
+				rc.Stale.GetStaleDays() != tt.wantStaleDays {
+				t.Errorf("RepoConfigFor() = %v %v %v, want %v %v %v", rc.Trigger.GetReapproveOnPush(), rc.Lgtm.GetStoreTreeHash(),
+					rc.Stale.GetStaleDays(), tt.wantReapprove, tt.wantTreeHash, tt.wantStaleDays)
+			}
+		})
+	}
+}
+
+//TestValidate tests the invalid settings
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "merge method", content: "merge_method: fast-forward"},
+		{name: "repo", content: "repos:\n  hello:\n    merge_method: merge"},
+		{name: "travis endpoint", content: "travis:\n  endpoint: travis"},
+		{name: "ci provider", content: "ci_provider: circleci"},
+		{name: "jenkins endpoint", content: "repos:\n  test/hello:\n    jenkins:\n      endpoint: jenkins"},
+		{name: "tide update method", content: "tide:\n  update_method: force-push"},
+		{name: "reviewer count", content: "blunderbuss:\n  reviewer_count: -1"},
+		{name: "owners backend", content: "owners_backend: svn"},
+		{name: "branch merge method", content: "branch_merge_methods:\n  master: fast-forward"},
+		{name: "commit template", content: "commit_template:\n  title: \"{{.Title\""},
+		{name: "stale days", content: "stale:\n  stale_days: -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.content)
+			defer os.RemoveAll(filepath.Dir(path))
+
+			c, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if err := c.Validate(); err == nil {
+				t.Errorf("Validate() error = nil, want error")
+			}
+		})
+	}
+
+	// unknown fields are reported
+	path := writeConfig(t, "merge_methods: merge")
+	defer os.RemoveAll(filepath.Dir(path))
+	if _, err := Load(path); err == nil {
+		t.Errorf("Load() error = nil, want error")
+	}
+}
+
+//TestReload tests that an invalid config does not replace the current one
+func TestReload(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	load := func() (*Config, error) {
+		c, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		return c, c.Validate()
+	}
+	agent, err := NewAgent(load)
+	if err != nil {
+		t.Fatalf("NewAgent() error = %v", err)
+	}
+
+	err = ioutil.WriteFile(path, []byte("merge_method: rebase"), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := agent.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := agent.Config().MergeMethod; got != MergeMethodRebase {
+		t.Errorf("MergeMethod = %s, want %s", got, MergeMethodRebase)
+	}
+
+	err = ioutil.WriteFile(path, []byte("merge_method: unknown"), 0644)
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := agent.Reload(); err == nil {
+		t.Errorf("Reload() error = nil, want error")
+	}
+	if got := agent.Config().MergeMethod; got != MergeMethodRebase {
+		t.Errorf("MergeMethod = %s, want %s", got, MergeMethodRebase)
+	}
+}
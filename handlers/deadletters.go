@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/queue"
+)
+
+// AdminTokenHeader is the header which contains the admin token
+const AdminTokenHeader = "X-Admin-Token"
+
+// authorized checks the admin token of the request. The admin endpoints are disabled if the token is not set.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.AdminToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	token := r.Header.Get(AdminTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// ServeDeadLetters writes the webhook events which failed too many times
+func (s *Server) ServeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list, err := s.Queue.DeadLetters()
+	if err != nil {
+		glog.Errorf("Failed to list dead letters: %v", err)
+		http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		glog.Errorf("Failed to encode dead letters: %v", err)
+	}
+}
+
+// ServeReplay moves a dead letter back into the queue. e.g. POST /deadletters/replay?id=<delivery id>
+func (s *Server) ServeReplay(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	err := s.Queue.Replay(id)
+	if err == queue.ErrNotFound {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		glog.Errorf("Failed to replay %s: %v", id, err)
+		http.Error(w, "Failed to replay the dead letter", http.StatusInternalServerError)
+		return
+	}
+	glog.Infof("Replayed dead letter: %s", id)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/queue"
+)
+
+// recorder records the plugins which are invoked
+type recorder struct {
+	called []string
+}
+
+// plugin returns a plugin which records its invocations
+func (r *recorder) plugin(name string, actions ...string) plugins.Plugin {
+	return plugins.Plugin{
+		Name:     name,
+		Commands: []*regexp.Regexp{regexp.MustCompile(`(?mi)^/` + name + `\s*$`)},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			r.called = append(r.called, name)
+			return nil
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			r.called = append(r.called, name)
+			return nil
+		},
+		PullRequestActions: actions,
+	}
+}
+
+//TestDispatchPullRequest tests that each pr action only invokes the plugins which handle it
+func TestDispatchPullRequest(t *testing.T) {
+	tests := []struct {
+		action string
+		want   []string
+	}{
+		{action: plugins.ActionOpened, want: []string{"body", "all"}},
+		{action: plugins.ActionEdited, want: []string{"body", "all"}},
+		{action: plugins.ActionReopened, want: []string{"body", "all"}},
+		{action: plugins.ActionSynchronize, want: []string{"push", "all"}},
+		{action: plugins.ActionLabeled, want: []string{"label", "all"}},
+		{action: plugins.ActionUnlabeled, want: []string{"label", "all"}},
+		{action: plugins.ActionClosed, want: []string{"all"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			r := &recorder{}
+			list := []plugins.Plugin{
+				r.plugin("body", plugins.ActionOpened, plugins.ActionEdited, plugins.ActionReopened),
+				r.plugin("push", plugins.ActionSynchronize),
+				r.plugin("label", plugins.ActionLabeled, plugins.ActionUnlabeled),
+				r.plugin("all"),
+				{Name: "comment", IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+					t.Errorf("comment plugin is invoked by a pr event")
+					return nil
+				}},
+			}
+			event := github.PullRequestEvent{Action: github.String(tt.action)}
+			if err := dispatchPullRequest(list, plugins.Agent{}, event, &queue.Delivery{}); err != nil {
+				t.Errorf("dispatchPullRequest() error = %v", err)
+			}
+			if !reflect.DeepEqual(r.called, tt.want) {
+				t.Errorf("called = %v, want %v", r.called, tt.want)
+			}
+		})
+	}
+}
+
+//TestDispatchIssueComment tests that only the new comments invoke the plugins whose commands match
+func TestDispatchIssueComment(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		comment string
+		want    []string
+	}{
+		{name: "command", action: plugins.ActionCreated, comment: "/foo", want: []string{"foo"}},
+		{name: "other command", action: plugins.ActionCreated, comment: "/bar", want: []string{"bar"}},
+		{name: "no command", action: plugins.ActionCreated, comment: "looks good", want: nil},
+		{name: "edited", action: plugins.ActionEdited, comment: "/foo", want: nil},
+		{name: "deleted", action: "deleted", comment: "/foo", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			list := []plugins.Plugin{r.plugin("foo"), r.plugin("bar")}
+			event := github.IssueCommentEvent{
+				Action:  github.String(tt.action),
+				Comment: &github.IssueComment{Body: github.String(tt.comment)},
+			}
+			if err := dispatchIssueComment(list, plugins.Agent{}, event, &queue.Delivery{}); err != nil {
+				t.Errorf("dispatchIssueComment() error = %v", err)
+			}
+			if !reflect.DeepEqual(r.called, tt.want) {
+				t.Errorf("called = %v, want %v", r.called, tt.want)
+			}
+		})
+	}
+}
+
+//TestDispatchErrors tests that the errors of the plugins are returned so that the event is retried only by the failed plugins
+func TestDispatchErrors(t *testing.T) {
+	r := &recorder{}
+	broken := 0
+	list := []plugins.Plugin{
+		{Name: "broken", PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			broken++
+			if broken == 1 {
+				return errors.New("github is down")
+			}
+			return nil
+		}},
+		r.plugin("all"),
+	}
+	event := github.PullRequestEvent{Action: github.String(plugins.ActionOpened)}
+	delivery := &queue.Delivery{}
+	err := dispatchPullRequest(list, plugins.Agent{}, event, delivery)
+	if err == nil || err.Error() != "broken: github is down" {
+		t.Errorf("dispatchPullRequest() error = %v, want broken: github is down", err)
+	}
+	// the other plugins still handle the event
+	if !reflect.DeepEqual(r.called, []string{"all"}) {
+		t.Errorf("called = %v, want [all]", r.called)
+	}
+
+	// the retry only invokes the failed plugin
+	if err := dispatchPullRequest(list, plugins.Agent{}, event, delivery); err != nil {
+		t.Errorf("dispatchPullRequest() error = %v", err)
+	}
+	if broken != 2 || !reflect.DeepEqual(r.called, []string{"all"}) {
+		t.Errorf("broken = %d, called = %v, want the broken plugin retried only", broken, r.called)
+	}
+}
+
+//TestDispatchReview tests that the submitted reviews invoke the review handlers and the commands in their bodies
+func TestDispatchReview(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		body   string
+		want   []string
+	}{
+		{name: "approved", action: plugins.ActionSubmitted, want: []string{"review"}},
+		{name: "command", action: plugins.ActionSubmitted, body: "/foo", want: []string{"review", "foo"}},
+		{name: "dismissed", action: "dismissed", body: "/foo", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			list := []plugins.Plugin{
+				r.plugin("foo"),
+				{Name: "review", ReviewHandler: func(agent plugins.Agent, event github.PullRequestReviewEvent) error {
+					r.called = append(r.called, "review")
+					return nil
+				}},
+			}
+			event := github.PullRequestReviewEvent{
+				Action:      github.String(tt.action),
+				Review:      &github.PullRequestReview{Body: github.String(tt.body), State: github.String("approved")},
+				PullRequest: &github.PullRequest{Number: github.Int(1)},
+			}
+			if err := dispatchReview(list, plugins.Agent{}, event, &queue.Delivery{}); err != nil {
+				t.Errorf("dispatchReview() error = %v", err)
+			}
+			if !reflect.DeepEqual(r.called, tt.want) {
+				t.Errorf("called = %v, want %v", r.called, tt.want)
+			}
+		})
+	}
+}
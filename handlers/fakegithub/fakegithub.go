@@ -0,0 +1,57 @@
+// Package fakegithub serves the github APIs which are shared by the tests of the plugins
+package fakegithub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// the label APIs of the issue #1 of test/hello
+const labelsPath = "/repos/test/hello/issues/1/labels"
+
+// Labels records the labels which are added to and removed from the issue #1 of test/hello
+type Labels struct {
+	lock    sync.Mutex
+	Added   []string
+	Removed []string
+}
+
+// Serve records the label request, it returns false if the request is not a label request
+func (f *Labels) Serve(w http.ResponseWriter, r *http.Request) bool {
+	switch {
+	case r.URL.Path == labelsPath && r.Method == http.MethodPost:
+		var labels []string
+		json.NewDecoder(r.Body).Decode(&labels)
+		f.lock.Lock()
+		f.Added = append(f.Added, labels...)
+		f.lock.Unlock()
+		json.NewEncoder(w).Encode([]github.Label{})
+	case strings.HasPrefix(r.URL.Path, labelsPath+"/") && r.Method == http.MethodDelete:
+		f.lock.Lock()
+		f.Removed = append(f.Removed, strings.TrimPrefix(r.URL.Path, labelsPath+"/"))
+		f.lock.Unlock()
+	default:
+		return false
+	}
+	return true
+}
+
+// ServeHTTP serves the label requests, the others are not found
+func (f *Labels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.Serve(w, r) {
+		http.NotFound(w, r)
+	}
+}
+
+// NewClient returns a github client which sends the requests to the server
+func NewClient(server *httptest.Server) *github.Client {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return client
+}
@@ -0,0 +1,78 @@
+package hold
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+)
+
+var (
+	// RegHold is the regular expression of /hold
+	RegHold = regexp.MustCompile(`(?mi)^/hold\s*$`)
+	// RegHoldCancel is the regular expression of /hold cancel
+	RegHoldCancel = regexp.MustCompile(`(?mi)^/hold cancel\s*$`)
+)
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "hold",
+		Help: "/hold and /hold cancel add or remove the " + config.LabelHold + " label. " +
+			"A pr is not merged while it has any " + config.LabelPrefixDoNotMerge + "* label.",
+		Commands: []*regexp.Regexp{RegHold, RegHoldCancel},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return Handle(agent, event)
+		},
+	})
+}
+
+// Handle event with hold
+func Handle(agent plugins.Agent, event github.IssueCommentEvent) error {
+	// only handle pr which is open
+	if !event.Issue.IsPullRequest() || event.Issue.GetState() != "open" {
+		return nil
+	}
+	ctx := context.Background()
+	client := agent.GithubClient
+	comment := event.Comment.GetBody()
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.Issue.GetNumber()
+
+	held := false
+	for _, l := range event.Issue.Labels {
+		if l.GetName() == config.LabelHold {
+			held = true
+			break
+		}
+	}
+
+	if RegHoldCancel.MatchString(comment) {
+		if !held {
+			return nil
+		}
+		_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, config.LabelHold)
+		if err != nil {
+			glog.Errorf("Unable to remove label: %s err: %v", config.LabelHold, err)
+			return err
+		}
+		glog.Infof("Remove label %s from pr #%d", config.LabelHold, number)
+		return nil
+	}
+	if RegHold.MatchString(comment) {
+		if held {
+			return nil
+		}
+		_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{config.LabelHold})
+		if err != nil {
+			glog.Errorf("Unable to add label: %s err: %v", config.LabelHold, err)
+			return err
+		}
+		glog.Infof("Add label %s to pr #%d", config.LabelHold, number)
+	}
+	return nil
+}
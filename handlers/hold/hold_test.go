@@ -0,0 +1,75 @@
+package hold
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/fakegithub"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+// newEvent returns the comment event of the open pr with the labels
+func newEvent(comment string, labels ...string) github.IssueCommentEvent {
+	issue := &github.Issue{
+		Number:           github.Int(1),
+		State:            github.String("open"),
+		PullRequestLinks: &github.PullRequestLinks{},
+	}
+	for _, l := range labels {
+		issue.Labels = append(issue.Labels, github.Label{Name: github.String(l)})
+	}
+	return github.IssueCommentEvent{
+		Issue:   issue,
+		Comment: &github.IssueComment{Body: github.String(comment)},
+		Repo:    &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+	}
+}
+
+//TestHandle tests that /hold and /hold cancel add and remove the hold label only once
+func TestHandle(t *testing.T) {
+	f := &fakegithub.Labels{}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	agent := plugins.Agent{GithubClient: fakegithub.NewClient(server)}
+
+	events := []github.IssueCommentEvent{
+		newEvent("/hold"),
+		newEvent("/hold", config.LabelHold),
+		newEvent("/hold cancel", config.LabelHold),
+		newEvent("/hold cancel"),
+	}
+	for _, e := range events {
+		if err := Handle(agent, e); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+	if want := []string{config.LabelHold}; !reflect.DeepEqual(f.Added, want) {
+		t.Errorf("added = %v, want %v", f.Added, want)
+	}
+	if len(f.Removed) != 1 {
+		t.Errorf("removed = %v, want the hold label", f.Removed)
+	}
+}
+
+//TestLabelBlockers tests that any do-not-merge label blocks merging
+func TestLabelBlockers(t *testing.T) {
+	cfg := config.RepoConfig{Labels: config.Labels{Approved: "approved", Lgtm: "lgtm"}}
+	labels := []*github.Label{
+		{Name: github.String("approved")},
+		{Name: github.String("lgtm")},
+		{Name: github.String("do-not-merge/release-note-label-needed")},
+	}
+	reasons := util.LabelBlockers(cfg, labels)
+	if len(reasons) != 1 || !strings.Contains(reasons[0], "do-not-merge/release-note-label-needed") {
+		t.Errorf("LabelBlockers() = %v, want the do-not-merge label", reasons)
+	}
+	if reasons := util.LabelBlockers(cfg, labels[:2]); len(reasons) != 0 {
+		t.Errorf("LabelBlockers() = %v, want none", reasons)
+	}
+}
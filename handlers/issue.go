@@ -2,13 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 
-	"github.com/huawei-cloudnative/ci-bot/handlers/approve"
-	"github.com/huawei-cloudnative/ci-bot/handlers/assign"
-	"github.com/huawei-cloudnative/ci-bot/handlers/label"
-	"github.com/huawei-cloudnative/ci-bot/handlers/lgtm"
-	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
-	"github.com/huawei-cloudnative/ci-bot/handlers/retest"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/queue"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
@@ -18,60 +15,66 @@ type GithubIssue github.Issue
 
 func (s *Server) handleIssueEvent(body []byte) {
 	glog.Info("Received an Issue Event")
+
+	var issueEvent github.IssuesEvent
+	err := json.Unmarshal(body, &issueEvent)
+	if err != nil {
+		glog.Errorf("Failed to unmarshal issueEvent: %v", err)
+		return
+	}
+	// the stale controller looks for the idle issues of the repository
+	s.Stale.AddRepository(issueEvent.Repo.GetOwner().GetLogin(), issueEvent.Repo.GetName())
 }
 
 //function to handle issue comments
-func (s *Server) handleIssueCommentEvent(body []byte, client *github.Client, r repository.Interface) {
+func (s *Server) handleIssueCommentEvent(delivery *queue.Delivery, client *github.Client) error {
 	var commentEvent github.IssueCommentEvent
 
 	// Unmarshal
-	err := json.Unmarshal(body, &commentEvent)
+	err := json.Unmarshal(delivery.Payload, &commentEvent)
 	if err != nil {
+		// it can not be fixed by retries
 		glog.Errorf("Failed to unmarshal commentEvent: %v", err)
+		return nil
 	}
-	// label
-	if label.RegAddLabel.MatchString(*commentEvent.Comment.Body) || label.RegRemoveLabel.MatchString(*commentEvent.Comment.Body) {
-		err = label.Handle(client, commentEvent)
-		if err != nil {
-			glog.Errorf("Failed to handle label: %v", err)
-		}
+
+	// dispatch the comment to the enabled plugins whose commands match it
+	org := commentEvent.Repo.GetOwner().GetLogin()
+	repo := commentEvent.Repo.GetName()
+	agent, err := s.newAgent(client, org, repo)
+	if err != nil {
+		glog.Errorf("Failed to load repository %s/%s: %v", org, repo, err)
+		return err
 	}
-	// assign
-	if AssignOrUnassing.MatchString(*commentEvent.Comment.Body) {
-		err = assign.Handle(client, commentEvent)
-		if err != nil {
-			glog.Errorf("Failed to handle assign: %v", err)
-		}
-	}
-	// retest
-	if TestReg.MatchString(*commentEvent.Comment.Body) || RetestReg.MatchString(*commentEvent.Comment.Body) {
-		err = retest.Handle(client, commentEvent, s.Config.TravisCIToken, s.Config.TravisRepoName)
-		if err != nil {
-			glog.Errorf("Failed to handle retest: %v", err)
-		}
+	// only the allowed users can comment commands
+	commentAuthor := commentEvent.Comment.GetUser().GetLogin()
+	if !agent.Config.IsCommandAuthor(commentAuthor) {
+		glog.Infof("%s is not allowed to comment commands in %s/%s", commentAuthor, org, repo)
+		return nil
 	}
+	return dispatchIssueComment(plugins.Enabled(agent.Config.Plugins), agent, commentEvent, delivery)
+}
 
-	// approve
-	if approve.RegAddApprove.MatchString(*commentEvent.Comment.Body) || approve.RegCancelApprove.MatchString(*commentEvent.Comment.Body) {
-		err = approve.Handle(client, r, commentEvent)
-		if err != nil {
-			glog.Errorf("Failed to handle: %v", err)
-		}
+// dispatchIssueComment invokes the plugins whose commands match the comment and which did not handle the delivery yet.
+// Only the new comments are handled so that editing or deleting a comment does not run its commands again.
+func dispatchIssueComment(list []plugins.Plugin, agent plugins.Agent, event github.IssueCommentEvent, delivery *queue.Delivery) error {
+	if event.GetAction() != plugins.ActionCreated {
+		glog.Infof("Skip issue comment action: %s", event.GetAction())
+		return nil
 	}
-
-	// lgtm
-	if lgtm.RegAddLgtm.MatchString(*commentEvent.Comment.Body) || lgtm.RegCancelLgtm.MatchString(*commentEvent.Comment.Body) {
-		err = lgtm.Handle(client, r, commentEvent)
-		if err != nil {
-			glog.Errorf("Failed to handle: %v", err)
+	comment := event.Comment.GetBody()
+	errs := make([]error, 0)
+	for _, p := range list {
+		if p.IssueCommentHandler == nil || !p.MatchComment(comment) || delivery.IsDone(p.Name) {
+			continue
 		}
-	}
-
-	// reviewers
-	if assign.CCRegExp.MatchString(*commentEvent.Comment.Body) {
-		err = assign.ReviewerReqByComment(client, commentEvent)
+		err := p.IssueCommentHandler(agent, event)
 		if err != nil {
-			glog.Errorf("Failed to handle: %v", err)
+			glog.Errorf("Failed to handle %s: %v", p.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %v", p.Name, err))
+			continue
 		}
+		delivery.MarkDone(p.Name)
 	}
+	return aggregateErrors(errs)
 }
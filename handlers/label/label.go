@@ -2,11 +2,15 @@ package label
 
 import (
 	"context"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
 )
 
 var (
@@ -21,6 +25,22 @@ const (
 	removeLabel = "/remove-kind"
 )
 
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name:     "label",
+		Help:     "/kind <label>, /priority <label>, /remove-kind <label> and /remove-priority <label> add or remove the kind/* and priority/* labels.",
+		Commands: []*regexp.Regexp{RegAddLabel, RegRemoveLabel},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return Handle(agent, event)
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePRLabels(context.Background(), event, agent.GithubClient)
+		},
+		// the commands in the pr body
+		PullRequestActions: []string{plugins.ActionOpened, plugins.ActionEdited, plugins.ActionReopened},
+	})
+}
+
 // Get Labels from Regexp matches
 func getLabelsFromREMatches(matches [][]string) (labels []string) {
 	for _, match := range matches {
@@ -47,7 +67,7 @@ func RemoveLabelsToPR(ctx context.Context, prEvent github.PullRequestEvent, clie
 	// list labels in current issue
 	listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name, *prEvent.Number, nil)
 	if err != nil {
-		glog.Fatalf("Unable to list issue labels. err: %v", err)
+		glog.Errorf("Unable to list issue labels. err: %v", err)
 		return err
 	}
 	glog.Infof("list of issue labels: %v", listofIssueLabels)
@@ -61,7 +81,7 @@ func RemoveLabelsToPR(ctx context.Context, prEvent github.PullRequestEvent, clie
 		for _, l := range listOfRemoveLabels {
 			_, err := client.Issues.RemoveLabelForIssue(ctx, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name, *prEvent.Number, l)
 			if err != nil {
-				glog.Fatalf("unable to remove label: %v err: %v", l, err)
+				glog.Errorf("unable to remove label: %v err: %v", l, err)
 			} else {
 				glog.Infof("remove label successfully: %v", l)
 			}
@@ -83,21 +103,21 @@ func AddLabelsToPR(ctx context.Context, prEvent github.PullRequestEvent, client
 	lableBody := strings.Join(Label, " ")
 	mapOfAddLabels := GetLabelsMap(lableBody)
 
-	listofRepoLabels, _, err:= client.Issues.ListLabels(ctx, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name, nil)
+	listofRepoLabels, err := ListRepoLabels(ctx, client, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name)
 	if err != nil {
-		glog.Fatalf("Unable to list repository labels. err: %v", err)
+		glog.Errorf("Unable to list repository labels. err: %v", err)
 	}
 	// list labels in current issue
 	listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name, *prEvent.Number, nil)
 	if err != nil {
-		glog.Fatalf("Unable to list issue labels. err: %v", err)
+		glog.Errorf("Unable to list issue labels. err: %v", err)
 	}
 	glog.Infof("List of issue labels: %v", listofIssueLabels)
 	//Get the list of add labels
 	listOfAddLabels = GetListOfAddLabels(mapOfAddLabels, listofRepoLabels, listofIssueLabels)
 	_, _, err = client.Issues.AddLabelsToIssue(ctx, *prEvent.Repo.Owner.Login, *prEvent.Repo.Name, *prEvent.Number, listOfAddLabels)
 	if err != nil {
-		glog.Fatalf("Unable to add labels: %v err: %v", listOfAddLabels, err)
+		glog.Errorf("Unable to add labels: %v err: %v", listOfAddLabels, err)
 		return err
 	} else {
 		glog.Infof("Add labels successfully: %v", listOfAddLabels)
@@ -107,53 +127,54 @@ func AddLabelsToPR(ctx context.Context, prEvent github.PullRequestEvent, client
 }
 //HandlePRLabels function to handle add or remove label to the PR
 func HandlePRLabels(ctx context.Context, prEvent github.PullRequestEvent, client *github.Client)error{
-	addLabelMatches := RegAddLabel.FindAllStringSubmatch(*prEvent.PullRequest.Body, -1)
-	removeLabelMatches := RegRemoveLabel.FindAllStringSubmatch(*prEvent.PullRequest.Body, -1)
+	addLabelMatches := RegAddLabel.FindAllStringSubmatch(prEvent.GetPullRequest().GetBody(), -1)
+	removeLabelMatches := RegRemoveLabel.FindAllStringSubmatch(prEvent.GetPullRequest().GetBody(), -1)
 	if len(addLabelMatches) == 0 && len(removeLabelMatches) == 0{
 		return nil
 	}
 
 	//get all labels from submatch and store in slice
-	labelsToAdd := append(getLabelsFromREMatches(addLabelMatches))
-	labelsToRemove := append(getLabelsFromREMatches(removeLabelMatches))
+	labelsToAdd := getLabelsFromREMatches(addLabelMatches)
+	labelsToRemove := getLabelsFromREMatches(removeLabelMatches)
 
 	for i,_ := range labelsToAdd {
 		err := AddLabelsToPR(ctx, prEvent, client, labelsToAdd[i])
 		if err != nil {
-			glog.Fatalf("Unable to list issue labels. err: %v", err)
+			glog.Errorf("Unable to list issue labels. err: %v", err)
 		}
 	}
 
 	for i,_ := range labelsToRemove {
 		err := RemoveLabelsToPR(ctx, prEvent, client, labelsToRemove[i])
 		if err != nil {
-			glog.Fatalf("Unable to list issue labels. err: %v", err)
+			glog.Errorf("Unable to list issue labels. err: %v", err)
 		}
 	}
 	return nil
 }
 
 // Handle event with label
-func Handle(client *github.Client, event github.IssueCommentEvent) error {
+func Handle(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// get basic params
 	comment := *event.Comment.Body
 	glog.Infof("receive event with label. comment: %s", comment)
 
 	// add labels
 	if RegAddLabel.MatchString(comment) {
-		return Add(client, event)
+		return Add(agent, event)
 	}
 	// remove labels
 	if RegRemoveLabel.MatchString(comment) {
-		return Remove(client, event)
+		return Remove(agent.GithubClient, event)
 	}
 
 	return nil
 }
 
 // add labels
-func Add(client *github.Client, event github.IssueCommentEvent) error {
+func Add(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// get basic params
+	client := agent.GithubClient
 	ctx := context.Background()
 	comment := *event.Comment.Body
 	owner := *event.Repo.Owner.Login
@@ -161,27 +182,31 @@ func Add(client *github.Client, event github.IssueCommentEvent) error {
 	number := *event.Issue.Number
 	glog.Infof("add label started. comment: %s owner: %s repo: %s number: %d", comment, owner, repo, number)
 
+	// list labels in current github repository
+	listofRepoLabels, err := ListRepoLabels(ctx, client, owner, repo)
+	if err != nil {
+		glog.Errorf("unable to list repository labels. err: %v", err)
+		return err
+	}
+	glog.Infof("list of repository labels: %v", listofRepoLabels)
+
 	//	/kind label1
-	//	/kind lable2 format handling
-	getLables := strings.Split(comment, "\r\n")
+	//	/kind lable2 format handling, the other lines of the comment are not commands
+	getLables := RegAddLabel.FindAllString(comment, -1)
 
+	// labels which are not existing in repository
+	listOfUnknownLabels := make([]string, 0)
 	for _, labelToAdd := range getLables{
 		// map of add labels
 		mapOfAddLabels := GetLabelsMap(labelToAdd)
 		glog.Infof("map of add labels: %v", mapOfAddLabels)
 
-		// list labels in current github repository
-		listofRepoLabels, _, err := client.Issues.ListLabels(ctx, owner, repo, nil)
-		if err != nil {
-			glog.Fatalf("unable to list repository labels. err: %v", err)
-			return err
-		}
-		glog.Infof("list of repository labels: %v", listofRepoLabels)
+		listOfUnknownLabels = append(listOfUnknownLabels, GetListOfUnknownLabels(mapOfAddLabels, listofRepoLabels)...)
 
 		// list labels in current issue
 		listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, owner, repo, number, nil)
 		if err != nil {
-			glog.Fatalf("unable to list issue labels. err: %v", err)
+			glog.Errorf("unable to list issue labels. err: %v", err)
 			return err
 		}
 		glog.Infof("list of issue labels: %v", listofIssueLabels)
@@ -194,7 +219,7 @@ func Add(client *github.Client, event github.IssueCommentEvent) error {
 		if len(listOfAddLabels) > 0 {
 			_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, listOfAddLabels)
 			if err != nil {
-				glog.Fatalf("unable to add labels: %v err: %v", listOfAddLabels, err)
+				glog.Errorf("unable to add labels: %v err: %v", listOfAddLabels, err)
 				return err
 			} else {
 				glog.Infof("add labels successfully: %v", listOfAddLabels)
@@ -204,6 +229,11 @@ func Add(client *github.Client, event github.IssueCommentEvent) error {
 		}
 	}
 
+	if len(listOfUnknownLabels) > 0 {
+		sort.Strings(listOfUnknownLabels)
+		return agent.Respond(event, fmt.Sprintf("the label(s) %s can not be added, they are not existing in this repository.",
+			strings.Join(listOfUnknownLabels, ", ")))
+	}
 	return nil
 }
 
@@ -217,7 +247,7 @@ func Remove(client *github.Client, event github.IssueCommentEvent) error {
 	number := *event.Issue.Number
 	glog.Infof("remove label started. comment: %s owner: %s repo: %s number: %d", comment, owner, repo, number)
 
-	getLables := strings.Split(comment, "\r\n")
+	getLables := RegRemoveLabel.FindAllString(comment, -1)
 
 	for _, labelToRemove := range getLables{
 		// map of add labels
@@ -227,7 +257,7 @@ func Remove(client *github.Client, event github.IssueCommentEvent) error {
 		// list labels in current issue
 		listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, owner, repo, number, nil)
 		if err != nil {
-			glog.Fatalf("unable to list issue labels. err: %v", err)
+			glog.Errorf("unable to list issue labels. err: %v", err)
 			return err
 		}
 		glog.Infof("list of issue labels: %v", listofIssueLabels)
@@ -241,7 +271,7 @@ func Remove(client *github.Client, event github.IssueCommentEvent) error {
 			for _, l := range listOfRemoveLabels {
 				_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, l)
 				if err != nil {
-					glog.Fatalf("unable to remove label: %v err: %v", l, err)
+					glog.Errorf("unable to remove label: %v err: %v", l, err)
 				} else {
 					glog.Infof("remove label successfully: %v", l)
 				}
@@ -254,6 +284,23 @@ func Remove(client *github.Client, event github.IssueCommentEvent) error {
 	return nil
 }
 
+// ListRepoLabels returns all the labels of the repository
+func ListRepoLabels(ctx context.Context, client *github.Client, owner string, repo string) ([]*github.Label, error) {
+	labels := make([]*github.Label, 0)
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Issues.ListLabels(ctx, owner, repo, opt)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, page...)
+		if resp.NextPage == 0 {
+			return labels, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
 // getListOfAddLabels return the exact list of add labels
 func GetListOfAddLabels(mapOfAddLabels map[string]string, listofRepoLabels []*github.Label, listofIssueLabels []*github.Label) []string {
 	// init
@@ -294,6 +341,24 @@ func GetListOfAddLabels(mapOfAddLabels map[string]string, listofRepoLabels []*gi
 	return listOfAddLabels
 }
 
+// GetListOfUnknownLabels returns the labels which are not existing in current github repository
+func GetListOfUnknownLabels(mapOfAddLabels map[string]string, listofRepoLabels []*github.Label) []string {
+	listOfUnknownLabels := make([]string, 0)
+	for l := range mapOfAddLabels {
+		existingInRepo := false
+		for _, repoLabel := range listofRepoLabels {
+			if l == repoLabel.GetName() {
+				existingInRepo = true
+				break
+			}
+		}
+		if !existingInRepo {
+			listOfUnknownLabels = append(listOfUnknownLabels, l)
+		}
+	}
+	return listOfUnknownLabels
+}
+
 // getListOfRemoveLabels return the exact list of remove labels
 func GetListOfRemoveLabels(mapOfRemoveLabels map[string]string, listofIssueLabels []*github.Label) []string {
 	// init
@@ -0,0 +1,110 @@
+package label
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/response"
+)
+
+// fakeGithub serves the repository labels in two pages and records the added labels
+type fakeGithub struct {
+	server *httptest.Server
+	added  []string
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/repos/test/hello/labels" && r.URL.Query().Get("page") == "2":
+		json.NewEncoder(w).Encode([]github.Label{{Name: github.String("priority/high")}})
+	case r.URL.Path == "/repos/test/hello/labels":
+		w.Header().Set("Link", fmt.Sprintf(`<%s/repos/test/hello/labels?page=2>; rel="next"`, f.server.URL))
+		json.NewEncoder(w).Encode([]github.Label{{Name: github.String("kind/bug")}})
+	case r.URL.Path == "/repos/test/hello/issues/1/labels" && r.Method == http.MethodPost:
+		var labels []string
+		json.NewDecoder(r.Body).Decode(&labels)
+		f.added = append(f.added, labels...)
+		json.NewEncoder(w).Encode([]github.Label{})
+	case r.URL.Path == "/repos/test/hello/issues/1/labels":
+		json.NewEncoder(w).Encode([]github.Label{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeResponder records the responses
+type fakeResponder struct {
+	messages []string
+}
+
+func (f *fakeResponder) Respond(t response.Target, message string) error {
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+//TestAdd tests that only the command lines are parsed and the labels of all the pages are known
+func TestAdd(t *testing.T) {
+	f := &fakeGithub{}
+	f.server = httptest.NewServer(f)
+	defer f.server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(f.server.URL + "/")
+	responder := &fakeResponder{}
+	agent := plugins.Agent{GithubClient: client, Responder: responder}
+
+	event := github.IssueCommentEvent{
+		Issue:   &github.Issue{Number: github.Int(1)},
+		Comment: &github.IssueComment{Body: github.String("Thanks for the fix\n/kind bug\n/priority high\nplease rebase it")},
+		Repo:    &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+	}
+	if err := Add(agent, event); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	sort.Strings(f.added)
+	if want := []string{"kind/bug", "priority/high"}; !reflect.DeepEqual(f.added, want) {
+		t.Errorf("added = %v, want %v", f.added, want)
+	}
+	if len(responder.messages) != 0 {
+		t.Errorf("responses = %v, want none", responder.messages)
+	}
+
+	event.Comment.Body = github.String("/kind feature\r\n/kind bug")
+	if err := Add(agent, event); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if len(responder.messages) != 1 || responder.messages[0] !=
+		"the label(s) kind/feature can not be added, they are not existing in this repository." {
+		t.Errorf("responses = %v, want the unknown kind/feature", responder.messages)
+	}
+}
+
+//TestHandlePRLabelsEmptyBody tests that a pr without description is handled
+func TestHandlePRLabelsEmptyBody(t *testing.T) {
+	f := &fakeGithub{}
+	f.server = httptest.NewServer(f)
+	defer f.server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(f.server.URL + "/")
+
+	event := github.PullRequestEvent{
+		Number:      github.Int(1),
+		PullRequest: &github.PullRequest{},
+		Repo:        &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+	}
+	if err := HandlePRLabels(context.Background(), event, client); err != nil {
+		t.Errorf("HandlePRLabels() error = %v", err)
+	}
+	if len(f.added) != 0 {
+		t.Errorf("added = %v, want none", f.added)
+	}
+}
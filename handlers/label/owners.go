@@ -0,0 +1,70 @@
+package label
+
+import (
+	"context"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "owners-label",
+		Help: "Adds the labels of the OWNERS files of the changed files to a pr.",
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandleOwnersLabels(agent, event)
+		},
+		PullRequestActions: []string{plugins.ActionOpened, plugins.ActionReopened, plugins.ActionSynchronize},
+	})
+}
+
+// HandleOwnersLabels adds the labels of the OWNERS files which own the changed files
+func HandleOwnersLabels(agent plugins.Agent, event github.PullRequestEvent) error {
+	ctx := context.Background()
+	client := agent.GithubClient
+	pr := event.GetPullRequest()
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := pr.GetNumber()
+
+	files, err := util.ListFileNames(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	owners, err := agent.Repository.LoadOwners(pr.GetBase().GetRef())
+	if err != nil {
+		glog.Errorf("Unable to load owners. err: %v", err)
+		return err
+	}
+
+	mapOfAddLabels := make(map[string]string)
+	for _, f := range files {
+		for k, v := range owners.GetLabels(f) {
+			mapOfAddLabels[k] = v
+		}
+	}
+	for _, l := range pr.Labels {
+		delete(mapOfAddLabels, l.GetName())
+	}
+	if len(mapOfAddLabels) == 0 {
+		glog.Infof("No owners label to add for pr #%d", number)
+		return nil
+	}
+
+	listOfAddLabels := make([]string, 0, len(mapOfAddLabels))
+	for l := range mapOfAddLabels {
+		listOfAddLabels = append(listOfAddLabels, l)
+	}
+	sort.Strings(listOfAddLabels)
+	_, _, err = client.Issues.AddLabelsToIssue(ctx, owner, repo, number, listOfAddLabels)
+	if err != nil {
+		glog.Errorf("unable to add labels: %v err: %v", listOfAddLabels, err)
+		return err
+	}
+	glog.Infof("add owners labels successfully: %v", listOfAddLabels)
+	return nil
+}
@@ -2,26 +2,65 @@ package lgtm
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
 
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
 	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
 	"github.com/huawei-cloudnative/ci-bot/handlers/util"
 )
 
+// maxSuggestions limits the suggested reviewers in the responses
+const maxSuggestions = 5
+
 var (
-	// lgtm label name
-	LabelNameLgtm = util.LabelNameLgtm
 	// regular expression to add lgtm
 	RegAddLgtm = regexp.MustCompile(`(?mi)^/lgtm\s*$`)
 	// regular expression to cancel lgtm
 	RegCancelLgtm = regexp.MustCompile(`(?mi)^/lgtm cancel\s*$`)
 )
 
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "lgtm",
+		Help: "/lgtm and /lgtm cancel add or remove the lgtm label. Only the reviewers and approvers in the OWNERS files can lgtm. " +
+			"The lgtm label is added after a required_reviewers of each changed file gives lgtm. " +
+			"The lgtm label is removed when new commits are pushed. " +
+			"An approving review counts as /lgtm, and a review which requests changes counts as /lgtm cancel.",
+		Commands: []*regexp.Regexp{RegAddLgtm, RegCancelLgtm},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return Handle(agent, event)
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePullRequest(agent, event)
+		},
+		PullRequestActions: []string{plugins.ActionSynchronize},
+		ReviewHandler: func(agent plugins.Agent, event github.PullRequestReviewEvent) error {
+			return HandleReview(agent, event)
+		},
+	})
+}
+
+// HandleReview adds lgtm for an approving review and removes it for a review which requests changes
+func HandleReview(agent plugins.Agent, event github.PullRequestReviewEvent) error {
+	if event.GetPullRequest().GetState() != "open" {
+		return nil
+	}
+	review := event.GetReview()
+	if plugins.IsReviewState(review, plugins.ReviewStateApproved) {
+		return Add(agent, plugins.ReviewCommentEvent(event, "/lgtm"))
+	}
+	if plugins.IsReviewState(review, plugins.ReviewStateChangesRequested) {
+		return Cancel(agent, plugins.ReviewCommentEvent(event, "/lgtm cancel"))
+	}
+	return nil
+}
+
 // Handle event with lgtm
-func Handle(client *github.Client, r repository.Interface, event github.IssueCommentEvent) error {
+func Handle(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// only handle pr which is open
 	if event.Issue.IsPullRequest() && *event.Issue.State == "open" {
 		// get basic params
@@ -30,20 +69,22 @@ func Handle(client *github.Client, r repository.Interface, event github.IssueCom
 
 		// add lgtm label
 		if RegAddLgtm.MatchString(comment) {
-			return Add(client, r, event)
+			return Add(agent, event)
 		}
 		// remove lgtm label
 		if RegCancelLgtm.MatchString(comment) {
-			return Cancel(client, r, event)
+			return Cancel(agent, event)
 		}
 	}
 	return nil
 }
 
 // Add lgtm label
-func Add(client *github.Client, r repository.Interface, event github.IssueCommentEvent) error {
+func Add(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// get basic params
 	ctx := context.Background()
+	client := agent.GithubClient
+	labelNameLgtm := agent.Config.Labels.Lgtm
 	comment := *event.Comment.Body
 	issueAuthor := *event.Issue.User.Login
 	commentAuthor := *event.Comment.User.Login
@@ -56,51 +97,34 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 	// can not lgtm on self-own pr
 	if issueAuthor == commentAuthor {
 		glog.Info("can not lgtm on self-own pr")
-		return nil
+		return agent.Respond(event, "you cannot LGTM your own PR.")
+	}
+
+	// the changed files are checked against the owners and the required reviewers
+	listOfFileNames, owners, err := loadOwners(agent, owner, repo, number)
+	if err != nil {
+		return err
 	}
 
 	// check if current author is collaborator
 	IsCollaborator, _, err := client.Repositories.IsCollaborator(ctx, owner, repo, commentAuthor)
 	if err != nil {
-		glog.Fatalf("Unable to check if current author is collaborator. err: %v", err)
+		glog.Errorf("Unable to check if current author is collaborator. err: %v", err)
 		return err
 	}
 	// not collaborator
 	if !IsCollaborator {
-		// list file names in current pr e.g. test/hello.go
-		prChangedFiles, _, err := client.PullRequests.ListFiles(ctx, owner, repo, number, nil)
-		if err != nil {
-			glog.Fatalf("Unable to list pr changed files. err: %v", err)
-			return err
-		}
-		listOfFileNames := make([]string, 0)
-		for _, f := range prChangedFiles {
-			listOfFileNames = append(listOfFileNames, *f.Filename)
-		}
-		glog.Infof("List of pr file names: %v", listOfFileNames)
-
-		// e.g. master
-		pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
-		glog.Infof("Pr base ref: %v", *pr.Base.Ref)
-
-		// load owners
-		err = r.LoadOwners(*pr.Base.Ref)
-		if err != nil {
-			glog.Fatalf("Unable to load owners. err: %v", err)
-			return err
-		}
-
 		// get all approvers and reviewers
 		mapOfOwners := make(map[string]string)
 		for _, path := range listOfFileNames {
 			// get all approvers by path
-			allApprovers := r.GetAllApprovers(path)
+			allApprovers := owners.GetAllApprovers(path)
 			glog.Infof("Path: %s AllApprovers: %v", path, allApprovers)
 			for k, v := range allApprovers {
 				mapOfOwners[k] = v
 			}
 			// get all reviewers by path
-			allReviewers := r.GetAllReviewers(path)
+			allReviewers := owners.GetAllReviewers(path)
 			glog.Infof("Path: %s AllReviewers: %v", path, allReviewers)
 			for k, v := range allReviewers {
 				mapOfOwners[k] = v
@@ -111,16 +135,46 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 		// can not find from the owners
 		if _, ok := mapOfOwners[commentAuthor]; !ok {
 			glog.Infof("can not find %s from owners", commentAuthor)
-			return nil
+			// suggest the closest reviewers of the changed files
+			suggestedReviewers := make(map[string]string)
+			for _, path := range listOfFileNames {
+				for k, v := range owners.GetClosestReviewers(path) {
+					suggestedReviewers[k] = v
+				}
+			}
+			delete(suggestedReviewers, issueAuthor)
+			message := "you are not a reviewer or an approver of the changed files"
+			if len(suggestedReviewers) > 0 {
+				message += "; suggested reviewers: " + util.Mentions(suggestedReviewers, maxSuggestions)
+			}
+			return agent.Respond(event, message)
 		}
 	} else {
 		glog.Infof("Current author %s is collaborator", commentAuthor)
 	}
 
+	// the givers are recorded for the merge commit message and the required reviewers
+	givers := []string{commentAuthor}
+	err = util.UpdateGivers(client, owner, repo, number, func(g *util.Givers) {
+		g.Lgtm = util.AddUsers(g.Lgtm, commentAuthor)
+		givers = g.Lgtm
+	})
+	if err != nil {
+		return err
+	}
+
+	// the required reviewers of the changed files must give lgtm before the label is added
+	missing := MissingRequiredReviewers(owners, listOfFileNames, givers, issueAuthor)
+	if len(missing) > 0 {
+		glog.Infof("Pr #%d is waiting for the required reviewers: %v", number, missing)
+		return agent.Respond(event, fmt.Sprintf("the lgtm is recorded, and the lgtm label is added after a required reviewer "+
+			"of each changed file gives lgtm; waiting for: %s", util.Mentions(missing, 0)))
+	}
+
 	// list labels in current issue
 	listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, owner, repo, number, nil)
 	if err != nil {
-		glog.Fatalf("Unable to list issue labels. err: %v", err)
+		glog.Errorf("Unable to list issue labels. err: %v", err)
 		return err
 	}
 	glog.Infof("List of issue labels: %v", listofIssueLabels)
@@ -128,7 +182,7 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 	// check if it has lgtm
 	hasLgtm := false
 	for _, l := range listofIssueLabels {
-		if *l.Name == LabelNameLgtm {
+		if *l.Name == labelNameLgtm {
 			hasLgtm = true
 			break
 		}
@@ -137,32 +191,102 @@ func Add(client *github.Client, r repository.Interface, event github.IssueCommen
 	// it has no lgtm
 	if !hasLgtm {
 		// add label lgtm
-		listOfAddLabels := []string{LabelNameLgtm}
+		listOfAddLabels := []string{labelNameLgtm}
 		_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, listOfAddLabels)
 		if err != nil {
-			glog.Fatalf("Unable to add label: %v err: %v", listOfAddLabels, err)
+			glog.Errorf("Unable to add label: %v err: %v", listOfAddLabels, err)
 			return err
 		} else {
 			glog.Infof("Add label successfully: %v", listOfAddLabels)
 		}
 	} else {
-		glog.Infof("No label to add: %v", LabelNameLgtm)
+		glog.Infof("No label to add: %v", labelNameLgtm)
 	}
 
-	// try to merge pr
-	err = util.MergePullRequest(client, owner, repo, number)
-	if err != nil {
-		glog.Errorf("Unable to merge pr: #%d err: %v", number, err)
-		return err
+	// the lgtm label is kept on the pushes which do not change the tree
+	if agent.Config.Lgtm.GetStoreTreeHash() {
+		err = StoreTreeHash(client, owner, repo, number)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the merge queue merges the pr when it is ready
+	if agent.MergeQueue != nil {
+		agent.MergeQueue.Enqueue(owner, repo, number)
 	}
 
 	return nil
 }
 
+// loadOwners returns the changed files of the pr and the owners of its base branch
+func loadOwners(agent plugins.Agent, owner string, repo string, number int) ([]string, repository.Owners, error) {
+	ctx := context.Background()
+	client := agent.GithubClient
+
+	// list file names in current pr e.g. test/hello.go
+	listOfFileNames, err := util.ListFileNames(client, owner, repo, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	glog.Infof("List of pr file names: %v", listOfFileNames)
+
+	// e.g. master
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return nil, nil, err
+	}
+	glog.Infof("Pr base ref: %v", pr.GetBase().GetRef())
+
+	// load owners
+	owners, err := agent.Repository.LoadOwners(pr.GetBase().GetRef())
+	if err != nil {
+		glog.Errorf("Unable to load owners. err: %v", err)
+		return nil, nil, err
+	}
+	return listOfFileNames, owners, nil
+}
+
+// MissingRequiredReviewers returns the required reviewers of the changed files which are not covered by the givers of lgtm.
+// A file is covered if one of its required reviewers gave lgtm, or if the pr author is one of them.
+func MissingRequiredReviewers(owners repository.Owners, files []string, givers []string, author string) map[string]string {
+	gave := make(map[string]bool)
+	for _, g := range givers {
+		gave[g] = true
+	}
+	missing := make(map[string]string)
+	for _, path := range files {
+		required := owners.GetRequiredReviewers(path)
+		if len(required) == 0 {
+			continue
+		}
+		if _, ok := required[author]; ok {
+			continue
+		}
+		covered := false
+		for k := range required {
+			if gave[k] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			for k, v := range required {
+				missing[k] = v
+			}
+		}
+	}
+	return missing
+}
+
 // Cancel removes lgtm label
-func Cancel(client *github.Client, r repository.Interface, event github.IssueCommentEvent) error {
+func Cancel(agent plugins.Agent, event github.IssueCommentEvent) error {
 	// get basic params
 	ctx := context.Background()
+	client := agent.GithubClient
+	r := agent.Repository
+	labelNameLgtm := agent.Config.Labels.Lgtm
 	comment := *event.Comment.Body
 	issueAuthor := *event.Issue.User.Login
 	commentAuthor := *event.Comment.User.Login
@@ -177,21 +301,16 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 		// check if current author is collaborator
 		IsCollaborator, _, err := client.Repositories.IsCollaborator(ctx, owner, repo, commentAuthor)
 		if err != nil {
-			glog.Fatalf("Unable to check if current author is collaborator. err: %v", err)
+			glog.Errorf("Unable to check if current author is collaborator. err: %v", err)
 			return err
 		}
 		// Not collaborator
 		if !IsCollaborator {
 			// list file names in current pr e.g. test/hello.go
-			prChangedFiles, _, err := client.PullRequests.ListFiles(ctx, owner, repo, number, nil)
+			listOfFileNames, err := util.ListFileNames(client, owner, repo, number)
 			if err != nil {
-				glog.Fatalf("Unable to list pr changed files. err: %v", err)
 				return err
 			}
-			listOfFileNames := make([]string, 0)
-			for _, f := range prChangedFiles {
-				listOfFileNames = append(listOfFileNames, *f.Filename)
-			}
 			glog.Infof("List of pr file names: %v", listOfFileNames)
 
 			// e.g. master
@@ -199,9 +318,9 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 			glog.Infof("Pr base ref: %v", *pr.Base.Ref)
 
 			// load owners
-			err = r.LoadOwners(*pr.Base.Ref)
+			owners, err := r.LoadOwners(*pr.Base.Ref)
 			if err != nil {
-				glog.Fatalf("Unable to load owners. err: %v", err)
+				glog.Errorf("Unable to load owners. err: %v", err)
 				return err
 			}
 
@@ -209,13 +328,13 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 			mapOfOwners := make(map[string]string)
 			for _, path := range listOfFileNames {
 				// get all approvers by path
-				allApprovers := r.GetAllApprovers(path)
+				allApprovers := owners.GetAllApprovers(path)
 				glog.Infof("Path: %s AllApprovers: %v", path, allApprovers)
 				for k, v := range allApprovers {
 					mapOfOwners[k] = v
 				}
 				// get all reviewers by path
-				allReviewers := r.GetAllReviewers(path)
+				allReviewers := owners.GetAllReviewers(path)
 				glog.Infof("Path: %s AllReviewers: %v", path, allReviewers)
 				for k, v := range allReviewers {
 					mapOfOwners[k] = v
@@ -226,7 +345,7 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 			// can not find from the owners
 			if _, ok := mapOfOwners[commentAuthor]; !ok {
 				glog.Infof("can not find %s from owners", commentAuthor)
-				return nil
+				return agent.Respond(event, "you are not a reviewer or an approver of the changed files, only the owners and the pr author can cancel lgtm.")
 			}
 		} else {
 			glog.Infof("Current author %s is collaborator", commentAuthor)
@@ -238,7 +357,7 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 	// list labels in current issue
 	listofIssueLabels, _, err := client.Issues.ListLabelsByIssue(ctx, owner, repo, number, nil)
 	if err != nil {
-		glog.Fatalf("Unable to list issue labels. err: %v", err)
+		glog.Errorf("Unable to list issue labels. err: %v", err)
 		return err
 	}
 	glog.Infof("List of issue labels: %v", listofIssueLabels)
@@ -246,7 +365,7 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 	// check if it has lgtm
 	hasLgtm := false
 	for _, l := range listofIssueLabels {
-		if *l.Name == LabelNameLgtm {
+		if *l.Name == labelNameLgtm {
 			hasLgtm = true
 			break
 		}
@@ -255,15 +374,18 @@ func Cancel(client *github.Client, r repository.Interface, event github.IssueCom
 	// it has no lgtm
 	if hasLgtm {
 		// remove label lgtm
-		_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, LabelNameLgtm)
+		_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, labelNameLgtm)
 		if err != nil {
-			glog.Fatalf("Unable to remove label: %v err: %v", LabelNameLgtm, err)
+			glog.Errorf("Unable to remove label: %v err: %v", labelNameLgtm, err)
 		} else {
-			glog.Infof("Remove label successfully: %v", LabelNameLgtm)
+			glog.Infof("Remove label successfully: %v", labelNameLgtm)
 		}
 	} else {
-		glog.Infof("No label to remove: %v", LabelNameLgtm)
+		glog.Infof("No label to remove: %v", labelNameLgtm)
 	}
 
-	return nil
+	// only the lgtm of the commenter is cancelled, it is given again after it is cancelled
+	return util.UpdateGivers(client, owner, repo, number, func(g *util.Givers) {
+		g.Lgtm = util.RemoveUsers(g.Lgtm, commentAuthor)
+	})
 }
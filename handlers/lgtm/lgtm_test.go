@@ -0,0 +1,50 @@
+package lgtm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+)
+
+// fakeOwners serves the required reviewers of the files
+type fakeOwners struct {
+	repository.Owners
+	required map[string][]string
+}
+
+func (f *fakeOwners) GetRequiredReviewers(path string) map[string]string {
+	out := make(map[string]string)
+	for _, u := range f.required[path] {
+		out[u] = u
+	}
+	return out
+}
+
+//TestMissingRequiredReviewers tests the required reviewers who must still give lgtm
+func TestMissingRequiredReviewers(t *testing.T) {
+	owners := &fakeOwners{required: map[string][]string{
+		"api/types.go": {"alice", "bob"},
+		"api/docs.md":  {"carol"},
+	}}
+	files := []string{"api/types.go", "api/docs.md", "main.go"}
+	tests := []struct {
+		name   string
+		givers []string
+		author string
+		want   map[string]string
+	}{
+		{name: "no givers", want: map[string]string{"alice": "alice", "bob": "bob", "carol": "carol"}},
+		{name: "one of the required reviewers", givers: []string{"bob"}, want: map[string]string{"carol": "carol"}},
+		{name: "all files covered", givers: []string{"alice", "carol"}, want: map[string]string{}},
+		{name: "author is required", givers: []string{"alice"}, author: "carol", want: map[string]string{}},
+		{name: "other givers", givers: []string{"dave"}, author: "erin", want: map[string]string{"alice": "alice", "bob": "bob", "carol": "carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingRequiredReviewers(owners, files, tt.givers, tt.author); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingRequiredReviewers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
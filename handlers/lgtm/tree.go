@@ -0,0 +1,146 @@
+package lgtm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+// TreeHashMarker starts the comment which records the tree of the head commit which gets lgtm
+const TreeHashMarker = "<!-- ci-bot lgtm tree hash"
+
+// regTreeHash is the regular expression of the tree hash record
+var regTreeHash = regexp.MustCompile(`^<!-- ci-bot lgtm tree hash: ([0-9a-f]+) -->`)
+
+// HandlePullRequest removes the lgtm label when new commits are pushed.
+// The label is kept if the tree hash is stored and the new head commit has the same tree.
+// The pushes of ci-bot itself, e.g. the merge queue merges the base branch into the pr, keep the label.
+func HandlePullRequest(agent plugins.Agent, event github.PullRequestEvent) error {
+	ctx := context.Background()
+	client := agent.GithubClient
+	pr := event.GetPullRequest()
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := pr.GetNumber()
+	labelNameLgtm := agent.Config.Labels.Lgtm
+
+	if !util.HasLabel(pr.Labels, labelNameLgtm) {
+		return nil
+	}
+	bot, err := util.BotLogin(client)
+	if err != nil {
+		return err
+	}
+	if event.GetSender().GetLogin() == bot {
+		glog.Infof("Keep %s on pr #%d, the new commits are pushed by %s", labelNameLgtm, number, bot)
+		// the record follows the update so that the next pushes are compared with it
+		if agent.Config.Lgtm.GetStoreTreeHash() {
+			return StoreTreeHash(client, owner, repo, number)
+		}
+		return nil
+	}
+	if agent.Config.Lgtm.GetStoreTreeHash() {
+		stored, _, err := storedTreeHash(client, owner, repo, number)
+		if err != nil {
+			return err
+		}
+		tree, err := treeHash(client, owner, repo, pr.GetHead().GetSHA())
+		if err != nil {
+			return err
+		}
+		if stored != "" && stored == tree {
+			glog.Infof("Keep %s on pr #%d, the tree %s is not changed", labelNameLgtm, number, tree)
+			return nil
+		}
+	}
+
+	_, err = client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, labelNameLgtm)
+	if err != nil {
+		glog.Errorf("Unable to remove label: %v err: %v", labelNameLgtm, err)
+		return err
+	}
+	glog.Infof("Remove label %s from pr #%d after new commits are pushed", labelNameLgtm, number)
+	msg := fmt.Sprintf("New changes are detected. The `%s` label has been removed.", labelNameLgtm)
+	_, _, err = client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(msg)})
+	if err != nil {
+		glog.Errorf("Unable to comment on pr #%d. err: %v", number, err)
+		return err
+	}
+	// the new changes need a new lgtm
+	return util.UpdateGivers(client, owner, repo, number, func(g *util.Givers) {
+		g.Lgtm = nil
+	})
+}
+
+// StoreTreeHash records the tree of the head commit of the pr in the tree hash comment, which is edited in place
+func StoreTreeHash(client *github.Client, owner string, repo string, number int) error {
+	ctx := context.Background()
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return err
+	}
+	tree, err := treeHash(client, owner, repo, pr.GetHead().GetSHA())
+	if err != nil {
+		return err
+	}
+	stored, comment, err := storedTreeHash(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	if stored == tree {
+		return nil
+	}
+
+	body := fmt.Sprintf("%s: %s -->\nLGTM is given to the tree `%s`. It is kept when the new commits have the same files.",
+		TreeHashMarker, tree, tree)
+	if comment != nil {
+		_, _, err = client.Issues.EditComment(ctx, owner, repo, comment.GetID(), &github.IssueComment{Body: github.String(body)})
+	} else {
+		_, _, err = client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(body)})
+	}
+	if err != nil {
+		glog.Errorf("Unable to store the tree hash of pr #%d. err: %v", number, err)
+		return err
+	}
+	glog.Infof("Store the tree hash %s of pr #%d", tree, number)
+	return nil
+}
+
+// storedTreeHash returns the recorded tree hash and its comment. Only the comments of the bot itself are read,
+// so that the others can not keep lgtm by posting a record.
+func storedTreeHash(client *github.Client, owner string, repo string, number int) (string, *github.IssueComment, error) {
+	bot, err := util.BotLogin(client)
+	if err != nil {
+		return "", nil, err
+	}
+	issueComments, err := util.ListComments(client, owner, repo, number)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, ic := range issueComments {
+		if !util.IsBotComment(ic, bot, TreeHashMarker) {
+			continue
+		}
+		if m := regTreeHash.FindStringSubmatch(ic.GetBody()); m != nil {
+			return m[1], ic, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// treeHash returns the tree of the commit
+func treeHash(client *github.Client, owner string, repo string, sha string) (string, error) {
+	commit, _, err := client.Git.GetCommit(context.Background(), owner, repo, sha)
+	if err != nil {
+		glog.Errorf("Unable to get commit %s. err: %v", sha, err)
+		return "", err
+	}
+	return commit.GetTree().GetSHA(), nil
+}
@@ -0,0 +1,138 @@
+package lgtm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+)
+
+// fakeGithub serves the comments and the commits of the pr #1, and records the removed labels
+type fakeGithub struct {
+	head     string
+	trees    map[string]string
+	comments []*github.IssueComment
+	removed  []string
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/user":
+		w.Write([]byte(`{"login": "ci-bot"}`))
+	case path == "/repos/test/hello/pulls/1":
+		fmt.Fprintf(w, `{"number": 1, "head": {"sha": "%s"}}`, f.head)
+	case strings.HasPrefix(path, "/repos/test/hello/git/commits/"):
+		fmt.Fprintf(w, `{"tree": {"sha": "%s"}}`, f.trees[strings.TrimPrefix(path, "/repos/test/hello/git/commits/")])
+	case path == "/repos/test/hello/issues/1/comments" && r.Method == http.MethodPost:
+		var ic github.IssueComment
+		json.NewDecoder(r.Body).Decode(&ic)
+		ic.ID = github.Int64(int64(len(f.comments) + 1))
+		ic.User = &github.User{Login: github.String("ci-bot")}
+		f.comments = append(f.comments, &ic)
+		json.NewEncoder(w).Encode(ic)
+	case path == "/repos/test/hello/issues/1/comments":
+		json.NewEncoder(w).Encode(f.comments)
+	case strings.HasPrefix(path, "/repos/test/hello/issues/comments/"):
+		var ic github.IssueComment
+		json.NewDecoder(r.Body).Decode(&ic)
+		for _, c := range f.comments {
+			if path == fmt.Sprintf("/repos/test/hello/issues/comments/%d", c.GetID()) {
+				c.Body = ic.Body
+			}
+		}
+		json.NewEncoder(w).Encode(ic)
+	case strings.HasPrefix(path, "/repos/test/hello/issues/1/labels/"):
+		f.removed = append(f.removed, strings.TrimPrefix(path, "/repos/test/hello/issues/1/labels/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newSynchronize returns the synchronize event of the pr #1 with lgtm which is pushed by the author
+func newSynchronize(head string) github.PullRequestEvent {
+	return github.PullRequestEvent{
+		Action: github.String(plugins.ActionSynchronize),
+		PullRequest: &github.PullRequest{
+			Number: github.Int(1),
+			Head:   &github.PullRequestBranch{SHA: github.String(head)},
+			Labels: []*github.Label{{Name: github.String("lgtm")}},
+		},
+		Repo:   &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+		Sender: &github.User{Login: github.String("author")},
+	}
+}
+
+//TestHandlePullRequest tests that lgtm is removed by the pushes which change the tree
+func TestHandlePullRequest(t *testing.T) {
+	f := &fakeGithub{head: "head1", trees: map[string]string{"head1": "aaa1", "head2": "aaa1", "head3": "bbb2"}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	agent := plugins.Agent{GithubClient: client, Config: config.RepoConfig{Labels: config.Labels{Lgtm: "lgtm"}}}
+
+	// lgtm is always removed without the tree hash
+	if err := HandlePullRequest(agent, newSynchronize("head2")); err != nil {
+		t.Fatalf("HandlePullRequest() error = %v", err)
+	}
+	if len(f.removed) != 1 || len(f.comments) != 1 {
+		t.Fatalf("removed = %v, comments = %d, want lgtm removed with a note", f.removed, len(f.comments))
+	}
+
+	// a record which is not written by the bot is ignored
+	f.removed, f.comments = nil, []*github.IssueComment{{
+		ID:   github.Int64(100),
+		User: &github.User{Login: github.String("author")},
+		Body: github.String(TreeHashMarker + ": bbb2 -->"),
+	}}
+	agent.Config.Lgtm.StoreTreeHash = config.Bool(true)
+	if err := StoreTreeHash(client, "test", "hello", 1); err != nil {
+		t.Fatalf("StoreTreeHash() error = %v", err)
+	}
+	// the record is edited in place
+	if err := StoreTreeHash(client, "test", "hello", 1); err != nil {
+		t.Fatalf("StoreTreeHash() error = %v", err)
+	}
+	if len(f.comments) != 2 || !strings.Contains(f.comments[1].GetBody(), "aaa1") {
+		t.Fatalf("comments = %d, want one tree hash record of aaa1", len(f.comments))
+	}
+
+	// a rebase with the same tree keeps lgtm
+	if err := HandlePullRequest(agent, newSynchronize("head2")); err != nil {
+		t.Fatalf("HandlePullRequest() error = %v", err)
+	}
+	if len(f.removed) != 0 {
+		t.Errorf("removed = %v, want lgtm kept", f.removed)
+	}
+
+	// new changes remove lgtm
+	if err := HandlePullRequest(agent, newSynchronize("head3")); err != nil {
+		t.Fatalf("HandlePullRequest() error = %v", err)
+	}
+	if len(f.removed) != 1 {
+		t.Errorf("removed = %v, want lgtm removed", f.removed)
+	}
+
+	// the pushes of the bot keep lgtm and update the record
+	f.removed, f.head = nil, "head3"
+	event := newSynchronize("head3")
+	event.Sender.Login = github.String("ci-bot")
+	if err := HandlePullRequest(agent, event); err != nil {
+		t.Fatalf("HandlePullRequest() error = %v", err)
+	}
+	if len(f.removed) != 0 {
+		t.Errorf("removed = %v, want lgtm kept", f.removed)
+	}
+	if !strings.Contains(f.comments[1].GetBody(), "bbb2") {
+		t.Errorf("record = %s, want the tree hash record of bbb2", f.comments[1].GetBody())
+	}
+}
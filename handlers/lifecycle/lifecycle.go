@@ -0,0 +1,292 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+)
+
+var (
+	// RegClose is the regular expression of /close
+	RegClose = regexp.MustCompile(`(?mi)^/close\s*$`)
+	// RegReopen is the regular expression of /reopen
+	RegReopen = regexp.MustCompile(`(?mi)^/reopen\s*$`)
+	// RegLock is the regular expression of /lock with an optional reason. e.g. /lock resolved
+	RegLock = regexp.MustCompile(`(?mi)^/lock(?:[ \t]+(.+?))?[ \t]*$`)
+	// RegLifecycle is the regular expression of /lifecycle. e.g. /lifecycle frozen
+	RegLifecycle = regexp.MustCompile(`(?mi)^/lifecycle (stale|rotten|frozen)\s*$`)
+	// RegRemoveLifecycle is the regular expression of /remove-lifecycle. e.g. /remove-lifecycle stale
+	RegRemoveLifecycle = regexp.MustCompile(`(?mi)^/remove-lifecycle (stale|rotten|frozen)\s*$`)
+)
+
+// lifecycleLabels are the lifecycle labels by the names in the commands
+var lifecycleLabels = map[string]string{
+	"stale":  config.LabelStale,
+	"rotten": config.LabelRotten,
+	"frozen": config.LabelFrozen,
+}
+
+// LockReasons are the reasons which github accepts to lock a conversation
+var LockReasons = []string{"off-topic", "too heated", "resolved", "spam"}
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "lifecycle",
+		Help: "/close, /reopen and /lock [" + strings.Join(LockReasons, "|") + "] close, reopen or lock the issue or pr. " +
+			"The author can close their own issue or pr, the collaborators and the approvers of the root OWNERS file or of the changed files of the pr can run all of them. " +
+			"/lifecycle stale|rotten|frozen and /remove-lifecycle stale|rotten|frozen add or remove the lifecycle labels, " +
+			"the issues and prs with the " + config.LabelFrozen + " label are never marked as stale.",
+		Commands: []*regexp.Regexp{RegClose, RegReopen, RegLock, RegLifecycle, RegRemoveLifecycle},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return Handle(agent, event)
+		},
+	})
+}
+
+// Handle event with lifecycle commands
+func Handle(agent plugins.Agent, event github.IssueCommentEvent) error {
+	comment := event.Comment.GetBody()
+	if RegClose.MatchString(comment) {
+		if err := Close(agent, event); err != nil {
+			return err
+		}
+	} else if RegReopen.MatchString(comment) {
+		if err := Reopen(agent, event); err != nil {
+			return err
+		}
+	}
+	if m := RegLock.FindStringSubmatch(comment); m != nil {
+		if err := Lock(agent, event, strings.ToLower(m[1])); err != nil {
+			return err
+		}
+	}
+	return Label(agent, event)
+}
+
+// Label adds a lifecycle label by /lifecycle, which replaces the other lifecycle labels,
+// or removes it by /remove-lifecycle. Everyone can comment them.
+func Label(agent plugins.Agent, event github.IssueCommentEvent) error {
+	ctx := context.Background()
+	client := agent.GithubClient
+	comment := event.Comment.GetBody()
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.Issue.GetNumber()
+
+	current := make(map[string]bool)
+	for _, l := range event.Issue.Labels {
+		current[l.GetName()] = true
+	}
+	remove := make([]string, 0)
+	if m := RegRemoveLifecycle.FindStringSubmatch(comment); m != nil {
+		remove = append(remove, lifecycleLabels[strings.ToLower(m[1])])
+	}
+	if m := RegLifecycle.FindStringSubmatch(comment); m != nil {
+		label := lifecycleLabels[strings.ToLower(m[1])]
+		for _, l := range lifecycleLabels {
+			if l != label {
+				remove = append(remove, l)
+			}
+		}
+		if !current[label] {
+			_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{label})
+			if err != nil {
+				glog.Errorf("Unable to add label: %s err: %v", label, err)
+				return err
+			}
+			glog.Infof("Add label %s to #%d", label, number)
+		}
+	}
+
+	sort.Strings(remove)
+	for _, l := range remove {
+		if !current[l] {
+			continue
+		}
+		_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, l)
+		if err != nil {
+			glog.Errorf("Unable to remove label: %s err: %v", l, err)
+			return err
+		}
+		glog.Infof("Remove label %s from #%d", l, number)
+		// removed once even if it is in both commands
+		delete(current, l)
+	}
+	return nil
+}
+
+// Close closes the issue or pr if the commenter is its author or privileged
+func Close(agent plugins.Agent, event github.IssueCommentEvent) error {
+	if event.Issue.GetState() == "closed" {
+		return nil
+	}
+	commenter := event.Comment.GetUser().GetLogin()
+	allowed := commenter == event.Issue.GetUser().GetLogin()
+	if !allowed {
+		var err error
+		allowed, err = privileged(agent, event)
+		if err != nil {
+			return err
+		}
+	}
+	if !allowed {
+		glog.Infof("%s is not allowed to close #%d", commenter, event.Issue.GetNumber())
+		return agent.Respond(event, fmt.Sprintf("only the author, the collaborators of %s and the approvers of the root OWNERS file or of the changed files can close it.",
+			event.Repo.GetName()))
+	}
+	return setState(agent, event, "closed")
+}
+
+// Reopen reopens the issue or pr if the commenter is privileged
+func Reopen(agent plugins.Agent, event github.IssueCommentEvent) error {
+	if event.Issue.GetState() != "closed" {
+		return nil
+	}
+	allowed, err := privileged(agent, event)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		glog.Infof("%s is not allowed to reopen #%d", event.Comment.GetUser().GetLogin(), event.Issue.GetNumber())
+		return agent.Respond(event, fmt.Sprintf("only the collaborators of %s and the approvers of the root OWNERS file or of the changed files can reopen it.",
+			event.Repo.GetName()))
+	}
+	return setState(agent, event, "open")
+}
+
+// Lock locks the conversation of the issue or pr with the reason if the commenter is privileged. The reason may be empty.
+func Lock(agent plugins.Agent, event github.IssueCommentEvent, reason string) error {
+	ctx := context.Background()
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.Issue.GetNumber()
+	if event.Issue.GetLocked() {
+		return nil
+	}
+	if reason != "" && !isLockReason(reason) {
+		return agent.Respond(event, fmt.Sprintf("the lock reason %q is unknown, it should be one of %s.", reason, strings.Join(LockReasons, ", ")))
+	}
+	allowed, err := privileged(agent, event)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		glog.Infof("%s is not allowed to lock #%d", event.Comment.GetUser().GetLogin(), number)
+		return agent.Respond(event, fmt.Sprintf("only the collaborators of %s and the approvers of the root OWNERS file or of the changed files can lock it.", repo))
+	}
+
+	var opt *github.LockIssueOptions
+	if reason != "" {
+		opt = &github.LockIssueOptions{LockReason: reason}
+	}
+	_, err = agent.GithubClient.Issues.Lock(ctx, owner, repo, number, opt)
+	if err != nil {
+		glog.Errorf("Unable to lock #%d err: %v", number, err)
+		return err
+	}
+	glog.Infof("Lock #%d reason: %s", number, reason)
+	return nil
+}
+
+// privileged checks if the commenter is a collaborator of the repository, an approver of the root OWNERS file,
+// or an approver of a changed file of the pr.
+// The OWNERS files of the base branch are used for a pr, and the ones of the default branch for an issue.
+func privileged(agent plugins.Agent, event github.IssueCommentEvent) (bool, error) {
+	ctx := context.Background()
+	client := agent.GithubClient
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	commenter := event.Comment.GetUser().GetLogin()
+
+	isCollaborator, _, err := client.Repositories.IsCollaborator(ctx, owner, repo, commenter)
+	if err != nil {
+		glog.Errorf("Unable to check if %s is a collaborator. err: %v", commenter, err)
+		return false, err
+	}
+	if isCollaborator {
+		return true, nil
+	}
+
+	branch := event.Repo.GetDefaultBranch()
+	// the root directory, and the changed files of the pr
+	paths := []string{""}
+	if event.Issue.IsPullRequest() {
+		pr, _, err := client.PullRequests.Get(ctx, owner, repo, event.Issue.GetNumber())
+		if err != nil {
+			glog.Errorf("Unable to get pr: #%d err: %v", event.Issue.GetNumber(), err)
+			return false, err
+		}
+		branch = pr.GetBase().GetRef()
+		files, err := listFileNames(client, owner, repo, event.Issue.GetNumber())
+		if err != nil {
+			return false, err
+		}
+		paths = append(paths, files...)
+	}
+	if branch == "" || agent.Repository == nil {
+		return false, nil
+	}
+	owners, err := agent.Repository.LoadOwners(branch)
+	if err != nil {
+		glog.Errorf("Unable to load owners. err: %v", err)
+		return false, err
+	}
+	for _, path := range paths {
+		if _, ok := owners.GetAllApprovers(path)[commenter]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listFileNames returns all the changed files of the pr
+func listFileNames(client *github.Client, owner string, repo string, number int) ([]string, error) {
+	files := make([]string, 0)
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.PullRequests.ListFiles(context.Background(), owner, repo, number, opt)
+		if err != nil {
+			glog.Errorf("Unable to list pr changed files. err: %v", err)
+			return nil, err
+		}
+		for _, f := range page {
+			files = append(files, f.GetFilename())
+		}
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// setState closes or reopens the issue or pr
+func setState(agent plugins.Agent, event github.IssueCommentEvent, state string) error {
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.Issue.GetNumber()
+	_, _, err := agent.GithubClient.Issues.Edit(context.Background(), owner, repo, number, &github.IssueRequest{State: github.String(state)})
+	if err != nil {
+		glog.Errorf("Unable to set the state of #%d to %s. err: %v", number, state, err)
+		return err
+	}
+	glog.Infof("Set the state of #%d to %s", number, state)
+	return nil
+}
+
+// isLockReason checks if github accepts the lock reason
+func isLockReason(reason string) bool {
+	for _, r := range LockReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/fakegithub"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+	"github.com/huawei-cloudnative/ci-bot/handlers/response"
+)
+
+// fakeGithub serves the collaborators and records the states, the locks and the labels of the issue
+type fakeGithub struct {
+	fakegithub.Labels
+	collaborators map[string]bool
+	states        []string
+	locks         []string
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.Serve(w, r) {
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/repos/test/hello/collaborators/"):
+		if f.collaborators[strings.TrimPrefix(r.URL.Path, "/repos/test/hello/collaborators/")] {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.NotFound(w, r)
+	case r.URL.Path == "/repos/test/hello/issues/1" && r.Method == http.MethodPatch:
+		var request github.IssueRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		f.states = append(f.states, request.GetState())
+		json.NewEncoder(w).Encode(github.Issue{})
+	case r.URL.Path == "/repos/test/hello/pulls/1":
+		w.Write([]byte(`{"number": 1, "base": {"ref": "master"}}`))
+	case r.URL.Path == "/repos/test/hello/pulls/1/files":
+		w.Write([]byte(`[{"filename": "pkg/foo/a.go"}]`))
+	case r.URL.Path == "/repos/test/hello/issues/1/lock":
+		var opt github.LockIssueOptions
+		json.NewDecoder(r.Body).Decode(&opt)
+		f.locks = append(f.locks, opt.LockReason)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeRepository has the approvers of the root OWNERS file and of the files
+type fakeRepository struct {
+	repository.Interface
+	repository.Owners
+	approvers map[string][]string
+}
+
+func (f *fakeRepository) LoadOwners(branch string) (repository.Owners, error) {
+	return f, nil
+}
+
+func (f *fakeRepository) GetAllApprovers(path string) map[string]string {
+	out := make(map[string]string)
+	for _, a := range append(f.approvers[""], f.approvers[path]...) {
+		out[a] = a
+	}
+	return out
+}
+
+// fakeResponder records the responses
+type fakeResponder struct {
+	messages []string
+}
+
+func (f *fakeResponder) Respond(target response.Target, message string) error {
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+// newEvent returns the comment event of the issue by the commenter
+func newEvent(comment string, commenter string, state string, locked bool) github.IssueCommentEvent {
+	return github.IssueCommentEvent{
+		Issue: &github.Issue{
+			Number: github.Int(1),
+			State:  github.String(state),
+			Locked: github.Bool(locked),
+			User:   &github.User{Login: github.String("author")},
+		},
+		Comment: &github.IssueComment{Body: github.String(comment), User: &github.User{Login: github.String(commenter)}},
+		Repo: &github.Repository{
+			Name:          github.String("hello"),
+			Owner:         &github.User{Login: github.String("test")},
+			DefaultBranch: github.String("master"),
+		},
+	}
+}
+
+// newPullRequestEvent returns the comment event of the open pr by the commenter
+func newPullRequestEvent(comment string, commenter string) github.IssueCommentEvent {
+	event := newEvent(comment, commenter, "open", false)
+	event.Issue.PullRequestLinks = &github.PullRequestLinks{}
+	return event
+}
+
+//TestHandle tests the permissions of /close, /reopen and /lock
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     github.IssueCommentEvent
+		states    []string
+		locks     []string
+		responded bool
+	}{
+		{name: "author closes", event: newEvent("/close", "author", "open", false), states: []string{"closed"}},
+		{name: "collaborator closes", event: newEvent("/close", "alice", "open", false), states: []string{"closed"}},
+		{name: "approver closes", event: newEvent("/close", "bob", "open", false), states: []string{"closed"}},
+		{name: "others can not close", event: newEvent("/close", "eve", "open", false), responded: true},
+		{name: "already closed", event: newEvent("/close", "author", "closed", false)},
+		{name: "author can not reopen", event: newEvent("/reopen", "author", "closed", false), responded: true},
+		{name: "approver reopens", event: newEvent("/reopen", "bob", "closed", false), states: []string{"open"}},
+		{name: "collaborator locks", event: newEvent("/lock", "alice", "open", false), locks: []string{""}},
+		{name: "lock with reason", event: newEvent("/lock Too Heated", "alice", "open", false), locks: []string{"too heated"}},
+		{name: "unknown lock reason", event: newEvent("/lock boring", "alice", "open", false), responded: true},
+		{name: "author can not lock", event: newEvent("/lock", "author", "open", false), responded: true},
+		{name: "already locked", event: newEvent("/lock", "alice", "open", true)},
+		{name: "close and lock", event: newEvent("/close\n/lock resolved", "bob", "open", false),
+			states: []string{"closed"}, locks: []string{"resolved"}},
+		{name: "file approver can not close an issue", event: newEvent("/close", "carol", "open", false), responded: true},
+		{name: "file approver closes a pr", event: newPullRequestEvent("/close", "carol"), states: []string{"closed"}},
+		{name: "others can not close a pr", event: newPullRequestEvent("/close", "eve"), responded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeGithub{collaborators: map[string]bool{"alice": true}}
+			server := httptest.NewServer(f)
+			defer server.Close()
+			client := fakegithub.NewClient(server)
+			responder := &fakeResponder{}
+			agent := plugins.Agent{
+				GithubClient: client,
+				Repository:   &fakeRepository{approvers: map[string][]string{"": {"bob"}, "pkg/foo/a.go": {"carol"}}},
+				Responder:    responder,
+			}
+
+			if err := Handle(agent, tt.event); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if !reflect.DeepEqual(f.states, tt.states) {
+				t.Errorf("states = %v, want %v", f.states, tt.states)
+			}
+			if !reflect.DeepEqual(f.locks, tt.locks) {
+				t.Errorf("locks = %v, want %v", f.locks, tt.locks)
+			}
+			if responded := len(responder.messages) > 0; responded != tt.responded {
+				t.Errorf("responses = %v, want responded %t", responder.messages, tt.responded)
+			}
+		})
+	}
+}
+
+//TestLabel tests that /lifecycle replaces the other lifecycle labels and /remove-lifecycle removes one
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		labels  []string
+		added   []string
+		removed []string
+	}{
+		{name: "frozen", comment: "/lifecycle frozen", labels: []string{config.LabelStale},
+			added: []string{config.LabelFrozen}, removed: []string{config.LabelStale}},
+		{name: "already frozen", comment: "/lifecycle frozen", labels: []string{config.LabelFrozen}},
+		{name: "remove stale", comment: "/remove-lifecycle stale", labels: []string{config.LabelStale, "kind/bug"},
+			removed: []string{config.LabelStale}},
+		{name: "not stale", comment: "/remove-lifecycle stale"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeGithub{}
+			server := httptest.NewServer(f)
+			defer server.Close()
+			client := fakegithub.NewClient(server)
+
+			event := newEvent(tt.comment, "eve", "open", false)
+			for _, l := range tt.labels {
+				event.Issue.Labels = append(event.Issue.Labels, github.Label{Name: github.String(l)})
+			}
+			if err := Handle(plugins.Agent{GithubClient: client}, event); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if !reflect.DeepEqual(f.Added, tt.added) {
+				t.Errorf("added = %v, want %v", f.Added, tt.added)
+			}
+			if !reflect.DeepEqual(f.Removed, tt.removed) {
+				t.Errorf("removed = %v, want %v", f.Removed, tt.removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	// plugins register themselves in the init functions
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/approve"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/assign"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/blunderbuss"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/hold"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/label"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/lgtm"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/lifecycle"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/retest"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/trigger"
+	_ "github.com/huawei-cloudnative/ci-bot/handlers/wip"
+)
+
+// PluginHelp describes a registered plugin
+type PluginHelp struct {
+	Name   string   `json:"name"`
+	Help   string   `json:"help"`
+	Events []string `json:"events"`
+}
+
+// newAgent returns the agent of org/repo which is passed to the plugins
+func (s *Server) newAgent(client *github.Client, org string, repo string) (plugins.Agent, error) {
+	r, err := s.Repositories.Get(org, repo)
+	if err != nil {
+		return plugins.Agent{}, err
+	}
+	// the merge queue looks for the ready pull requests of the repository, and the stale controller for the idle ones
+	s.Tide.AddRepository(org, repo)
+	s.Stale.AddRepository(org, repo)
+	agent := plugins.Agent{
+		GithubClient: client,
+		Repository:   r,
+		Config:       s.ConfigAgent.Config().RepoConfigFor(org, repo),
+		MergeQueue:   s.Tide,
+	}
+	if s.Responder != nil {
+		agent.Responder = s.Responder
+	}
+	return agent, nil
+}
+
+// ServePluginHelp writes the help of all the registered plugins
+func (s *Server) ServePluginHelp(w http.ResponseWriter, r *http.Request) {
+	list := make([]PluginHelp, 0)
+	for _, p := range plugins.List() {
+		list = append(list, PluginHelp{
+			Name:   p.Name,
+			Help:   p.Help,
+			Events: p.Events(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(list)
+	if err != nil {
+		glog.Errorf("Failed to encode plugin help: %v", err)
+	}
+}
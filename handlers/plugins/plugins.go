@@ -0,0 +1,240 @@
+package plugins
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+	"github.com/huawei-cloudnative/ci-bot/handlers/response"
+)
+
+const (
+	// EventIssues is the webhook event name of issues
+	EventIssues = "issues"
+	// EventIssueComment is the webhook event name of issue and pr comments
+	EventIssueComment = "issue_comment"
+	// EventPullRequest is the webhook event name of pull requests
+	EventPullRequest = "pull_request"
+	// EventPullRequestReview is the webhook event name of pr reviews
+	EventPullRequestReview = "pull_request_review"
+	// EventPullRequestReviewComment is the webhook event name of the comments on pr diffs
+	EventPullRequestReviewComment = "pull_request_review_comment"
+	// EventStatus is the webhook event name of status checks
+	EventStatus = "status"
+	// EventPush is the webhook event name of pushes to branches and tags
+	EventPush = "push"
+)
+
+// actions of the webhook events
+const (
+	ActionCreated          = "created"
+	ActionOpened           = "opened"
+	ActionEdited           = "edited"
+	ActionReopened         = "reopened"
+	ActionSynchronize      = "synchronize"
+	ActionLabeled          = "labeled"
+	ActionUnlabeled        = "unlabeled"
+	ActionClosed           = "closed"
+	ActionSubmitted        = "submitted"
+	ActionReadyForReview   = "ready_for_review"
+	ActionConvertedToDraft = "converted_to_draft"
+)
+
+// states of the pr reviews, the webhook events use the lower case and the API uses the upper case
+const (
+	ReviewStateApproved         = "approved"
+	ReviewStateChangesRequested = "changes_requested"
+)
+
+// Agent contains the clients and settings which are used by the plugins
+type Agent struct {
+	GithubClient *github.Client
+	Repository   repository.Interface
+	// Config contains the settings of the repository
+	Config config.RepoConfig
+	// MergeQueue merges the pull requests which are ready
+	MergeQueue MergeQueue
+	// Responder answers the commands with comments
+	Responder Responder
+}
+
+// Responder posts the responses to the commands
+type Responder interface {
+	// Respond posts the message as a comment which answers the target
+	Respond(t response.Target, message string) error
+}
+
+// Respond answers the command in the comment. e.g. why it is refused
+func (a Agent) Respond(event github.IssueCommentEvent, message string) error {
+	if a.Responder == nil {
+		glog.Infof("Response to %s: %s", event.Comment.GetUser().GetLogin(), message)
+		return nil
+	}
+	return a.Responder.Respond(response.Target{
+		Org:        event.Repo.GetOwner().GetLogin(),
+		Repo:       event.Repo.GetName(),
+		Number:     event.Issue.GetNumber(),
+		User:       event.Comment.GetUser().GetLogin(),
+		Comment:    event.Comment.GetBody(),
+		CommentURL: event.Comment.GetHTMLURL(),
+	}, message)
+}
+
+// MergeQueue queues the pull requests to be merged
+type MergeQueue interface {
+	// Enqueue adds a pull request into the merge queue
+	Enqueue(org string, repo string, number int)
+}
+
+// IssueCommentHandler handles an issue comment event
+type IssueCommentHandler func(agent Agent, event github.IssueCommentEvent) error
+
+// PullRequestHandler handles a pull request event
+type PullRequestHandler func(agent Agent, event github.PullRequestEvent) error
+
+// ReviewHandler handles a submitted pull request review
+type ReviewHandler func(agent Agent, event github.PullRequestReviewEvent) error
+
+// Plugin defines a command plugin of ci-bot
+type Plugin struct {
+	// Name of the plugin. e.g. lgtm
+	Name string
+	// Help describes the commands of the plugin
+	Help string
+	// Commands are the regular expressions of the comment commands.
+	// The IssueCommentHandler is invoked only if one of them matches the comment.
+	Commands []*regexp.Regexp
+
+	// IssueCommentHandler handles the issue_comment events
+	IssueCommentHandler IssueCommentHandler
+	// PullRequestHandler handles the pull_request events
+	PullRequestHandler PullRequestHandler
+	// PullRequestActions are the actions of the pull_request events which are passed to the PullRequestHandler.
+	// All the actions are passed if it is empty.
+	PullRequestActions []string
+	// ReviewHandler handles the pull_request_review events by the review state. The commands in the review body
+	// are passed to the IssueCommentHandler as well.
+	ReviewHandler ReviewHandler
+}
+
+// Events returns the event types which are handled by the plugin
+func (p Plugin) Events() []string {
+	events := make([]string, 0)
+	if p.IssueCommentHandler != nil {
+		events = append(events, EventIssueComment)
+	}
+	if p.PullRequestHandler != nil {
+		events = append(events, EventPullRequest)
+	}
+	if p.ReviewHandler != nil {
+		events = append(events, EventPullRequestReview)
+	}
+	return events
+}
+
+// MatchComment checks if the comment contains one of the plugin commands
+func (p Plugin) MatchComment(comment string) bool {
+	if len(p.Commands) == 0 {
+		return true
+	}
+	for _, reg := range p.Commands {
+		if reg.MatchString(comment) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPullRequestAction checks if the plugin handles the action of a pull_request event
+func (p Plugin) MatchPullRequestAction(action string) bool {
+	if p.PullRequestHandler == nil {
+		return false
+	}
+	if len(p.PullRequestActions) == 0 {
+		return true
+	}
+	for _, a := range p.PullRequestActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	lock    sync.RWMutex
+	plugins = map[string]Plugin{}
+)
+
+// Register adds a plugin into the registry. It is invoked in the init function of the plugin package.
+func Register(p Plugin) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if p.Name == "" {
+		panic("plugin name is empty")
+	}
+	if _, ok := plugins[p.Name]; ok {
+		panic(fmt.Sprintf("plugin %s is already registered", p.Name))
+	}
+	glog.Infof("Register plugin: %s events: %v", p.Name, p.Events())
+	plugins[p.Name] = p
+}
+
+// Get returns the registered plugin by name
+func Get(name string) (Plugin, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	p, ok := plugins[name]
+	return p, ok
+}
+
+// List returns all the registered plugins sorted by name
+func List() []Plugin {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	list := make([]Plugin, 0, len(plugins))
+	for _, p := range plugins {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Enabled returns the plugins of the names. All the registered plugins are enabled if names is empty.
+func Enabled(names []string) []Plugin {
+	if len(names) == 0 {
+		return List()
+	}
+
+	enabled := make([]Plugin, 0, len(names))
+	for _, n := range names {
+		p, ok := Get(n)
+		if !ok {
+			glog.Errorf("Plugin %s is not registered", n)
+			continue
+		}
+		enabled = append(enabled, p)
+	}
+	return enabled
+}
+
+// Validate checks if all the names are registered plugins
+func Validate(names []string) error {
+	for _, n := range names {
+		if _, ok := Get(n); !ok {
+			return fmt.Errorf("unknown plugin %s", n)
+		}
+	}
+	return nil
+}
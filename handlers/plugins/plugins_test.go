@@ -0,0 +1,93 @@
+package plugins
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func init() {
+	for _, name := range []string{"foo", "bar", "baz"} {
+		Register(Plugin{
+			Name:     name,
+			Commands: []*regexp.Regexp{regexp.MustCompile(`(?mi)^/` + name + `\s*$`)},
+			IssueCommentHandler: func(agent Agent, event github.IssueCommentEvent) error {
+				return nil
+			},
+		})
+	}
+}
+
+func names(list []Plugin) []string {
+	out := make([]string, 0)
+	for _, p := range list {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
+//TestEnabled tests the enabled plugins
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{name: "all", names: nil, want: []string{"bar", "baz", "foo"}},
+		{name: "some", names: []string{"foo", "baz"}, want: []string{"foo", "baz"}},
+		{name: "unknown", names: []string{"foo", "unknown"}, want: []string{"foo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(Enabled(tt.names)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+//TestValidate tests the unknown plugin name
+func TestValidate(t *testing.T) {
+	if err := Validate([]string{"foo", "bar"}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := Validate([]string{"foo", "unknown"}); err == nil {
+		t.Errorf("Validate() error = nil, want error")
+	}
+}
+
+//TestMatchComment tests the plugin commands
+func TestMatchComment(t *testing.T) {
+	p, _ := Get("foo")
+	if !p.MatchComment("/foo") {
+		t.Errorf("MatchComment(/foo) = false, want true")
+	}
+	if p.MatchComment("/bar") {
+		t.Errorf("MatchComment(/bar) = true, want false")
+	}
+}
+
+//TestMatchPullRequestAction tests the actions which are passed to the pr handler
+func TestMatchPullRequestAction(t *testing.T) {
+	handler := func(agent Agent, event github.PullRequestEvent) error { return nil }
+	tests := []struct {
+		name   string
+		plugin Plugin
+		action string
+		want   bool
+	}{
+		{name: "no handler", plugin: Plugin{}, action: ActionOpened, want: false},
+		{name: "all actions", plugin: Plugin{PullRequestHandler: handler}, action: ActionClosed, want: true},
+		{name: "listed action", plugin: Plugin{PullRequestHandler: handler, PullRequestActions: []string{ActionOpened, ActionEdited}}, action: ActionEdited, want: true},
+		{name: "other action", plugin: Plugin{PullRequestHandler: handler, PullRequestActions: []string{ActionOpened, ActionEdited}}, action: ActionSynchronize, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.plugin.MatchPullRequestAction(tt.action); got != tt.want {
+				t.Errorf("MatchPullRequestAction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
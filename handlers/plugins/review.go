@@ -0,0 +1,56 @@
+package plugins
+
+import (
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// IsReviewState checks the state of the review, it is case insensitive. e.g. approved
+func IsReviewState(review *github.PullRequestReview, state string) bool {
+	return strings.EqualFold(review.GetState(), state)
+}
+
+// ReviewCommentEvent returns the comment event of the review with the body,
+// so that the comment handlers can handle the commands of the review.
+func ReviewCommentEvent(event github.PullRequestReviewEvent, body string) github.IssueCommentEvent {
+	review := event.GetReview()
+	return commentEvent(event.GetPullRequest(), event.Repo, &github.IssueComment{
+		Body:      github.String(body),
+		User:      review.GetUser(),
+		HTMLURL:   review.HTMLURL,
+		CreatedAt: review.SubmittedAt,
+	})
+}
+
+// DiffCommentEvent returns the comment event of the comment on the pr diff
+func DiffCommentEvent(event github.PullRequestReviewCommentEvent) github.IssueCommentEvent {
+	c := event.GetComment()
+	return commentEvent(event.GetPullRequest(), event.Repo, &github.IssueComment{
+		ID:        c.ID,
+		Body:      c.Body,
+		User:      c.GetUser(),
+		HTMLURL:   c.HTMLURL,
+		CreatedAt: c.CreatedAt,
+	})
+}
+
+// commentEvent returns the comment event of the pr
+func commentEvent(pr *github.PullRequest, repo *github.Repository, comment *github.IssueComment) github.IssueCommentEvent {
+	issue := &github.Issue{
+		Number:           pr.Number,
+		State:            pr.State,
+		Title:            pr.Title,
+		User:             pr.User,
+		PullRequestLinks: &github.PullRequestLinks{URL: pr.URL},
+	}
+	for _, l := range pr.Labels {
+		issue.Labels = append(issue.Labels, *l)
+	}
+	return github.IssueCommentEvent{
+		Action:  github.String(ActionCreated),
+		Issue:   issue,
+		Comment: comment,
+		Repo:    repo,
+	}
+}
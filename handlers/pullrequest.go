@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"fmt"
 
-	"github.com/huawei-cloudnative/ci-bot/handlers/assign"
-	"github.com/huawei-cloudnative/ci-bot/handlers/label"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/queue"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
@@ -13,36 +13,44 @@ import (
 
 type GithubPR github.PullRequestEvent
 
-func (s *Server) handlePullRequestEvent(body []byte, client *github.Client) {
+func (s *Server) handlePullRequestEvent(delivery *queue.Delivery, client *github.Client) error {
 	glog.Infof("Received an PullRequest Event")
-	// get basic params
-	ctx := context.Background()
 
 	var prEvent github.PullRequestEvent
 
 	// Unmarshal
-	err := json.Unmarshal(body, &prEvent)
+	err := json.Unmarshal(delivery.Payload, &prEvent)
 	if err != nil {
+		// it can not be fixed by retries
 		glog.Errorf("Failed to unmarshal prEvent: %v", err)
+		return nil
 	}
-	//PR assignees
-	err = assign.HandlePRAssign(ctx, prEvent, client)
-	if err != nil {
-		glog.Fatalf("HandlePRAssign is failed. err: %v", err)
-	}
-	//PR Reviewers
-	err = assign.HandlePRReviewer(ctx, prEvent, client)
-	if err != nil {
-		glog.Fatalf("HandlePRReviewer is failed. err: %v", err)
-	}
-	//PR Labels
-	err = label.HandlePRLabels(ctx, prEvent, client)
+
+	// dispatch the event to the enabled plugins. e.g. assignees, reviewers and labels
+	org := prEvent.Repo.GetOwner().GetLogin()
+	repo := prEvent.Repo.GetName()
+	agent, err := s.newAgent(client, org, repo)
 	if err != nil {
-		glog.Fatalf("HandlePRLabels is failed. err: %v", err)
+		glog.Errorf("Failed to load repository %s/%s: %v", org, repo, err)
+		return err
 	}
+	return dispatchPullRequest(plugins.Enabled(agent.Config.Plugins), agent, prEvent, delivery)
 }
 
-func (s *Server) handlePullRequestCommentEvent(body []byte) {
-	glog.Infof("Received an PullRequestComment Event")
-
+// dispatchPullRequest invokes the plugins which handle the action of the pull request event and which did not handle the delivery yet
+func dispatchPullRequest(list []plugins.Plugin, agent plugins.Agent, event github.PullRequestEvent, delivery *queue.Delivery) error {
+	errs := make([]error, 0)
+	for _, p := range list {
+		if !p.MatchPullRequestAction(event.GetAction()) || delivery.IsDone(p.Name) {
+			continue
+		}
+		err := p.PullRequestHandler(agent, event)
+		if err != nil {
+			glog.Errorf("Failed to handle %s: %v", p.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %v", p.Name, err))
+			continue
+		}
+		delivery.MarkDone(p.Name)
+	}
+	return aggregateErrors(errs)
 }
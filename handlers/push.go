@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+)
+
+// handlePushEvent refreshes the owners of a branch which is pushed so that the next command does not wait for them
+func (s *Server) handlePushEvent(body []byte) error {
+	var pushEvent github.PushEvent
+
+	// Unmarshal
+	err := json.Unmarshal(body, &pushEvent)
+	if err != nil {
+		glog.Errorf("Failed to unmarshal pushEvent: %v", err)
+		return nil
+	}
+
+	// skip the tags and the deleted branches
+	if !strings.HasPrefix(pushEvent.GetRef(), "refs/heads/") || pushEvent.GetDeleted() {
+		return nil
+	}
+	org, repo, err := repository.ParseRepository(pushEvent.GetRepo().GetFullName())
+	if err != nil {
+		glog.Errorf("Failed to parse the repository of pushEvent: %v", err)
+		return nil
+	}
+	// the repositories which are not used yet are loaded on their first command
+	r, ok := s.Repositories.Lookup(org, repo)
+	if !ok {
+		return nil
+	}
+	branch := strings.TrimPrefix(pushEvent.GetRef(), "refs/heads/")
+	glog.Infof("Received a Push Event. repository: %s/%s branch: %s", org, repo, branch)
+	return r.Refresh(branch, pushEvent.GetAfter())
+}
@@ -0,0 +1,440 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// pendingDir stores the deliveries to be handled
+	pendingDir = "pending"
+	// deadDir stores the deliveries which failed too many times
+	deadDir = "dead"
+	// doneDir stores the markers of the handled deliveries
+	doneDir = "done"
+
+	// scanPeriod is the period to look for the pending deliveries which are due but not queued,
+	// e.g. when the ready channel was full
+	scanPeriod = 30 * time.Second
+)
+
+var (
+	// ErrDuplicate is returned when a delivery is already received
+	ErrDuplicate = errors.New("duplicate delivery")
+	// ErrNotFound is returned when a delivery does not exist
+	ErrNotFound = errors.New("delivery not found")
+
+	// delivery ids are used as file names. e.g. 72d3162e-cc78-11e3-81ab-4c9367dc0958
+	regDeliveryID = regexp.MustCompile(`^[-\w][-\w.]*$`)
+)
+
+// Delivery is a webhook event which is stored in the queue
+type Delivery struct {
+	// ID is the X-GitHub-Delivery header
+	ID string `json:"id"`
+	// Event is the X-GitHub-Event header. e.g. issue_comment
+	Event string `json:"event"`
+	// Payload is the body of the webhook
+	Payload json.RawMessage `json:"payload"`
+
+	ReceivedAt  time.Time `json:"received_at"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	// Done are the steps which succeeded, they are skipped by the retries. e.g. the plugins which handled the event
+	Done []string `json:"done,omitempty"`
+}
+
+// IsDone checks if the step succeeded in a previous attempt
+func (d *Delivery) IsDone(step string) bool {
+	for _, s := range d.Done {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkDone records that the step succeeded so that it is not run again by the retries
+func (d *Delivery) MarkDone(step string) {
+	if !d.IsDone(step) {
+		d.Done = append(d.Done, step)
+	}
+}
+
+// HandleFunc handles a delivery, the delivery is retried if it returns an error.
+// The steps which are marked done are kept for the retries.
+type HandleFunc func(d *Delivery) error
+
+// Options defines the retry settings of the queue
+type Options struct {
+	// Workers is the number of deliveries which are handled concurrently
+	Workers int
+	// MaxAttempts is the number of attempts before a delivery is moved to the dead letters
+	MaxAttempts int
+	// Backoff is the delay before the first retry, it is doubled for each retry
+	Backoff time.Duration
+	// MaxBackoff limits the delay of retries
+	MaxBackoff time.Duration
+	// Retention is how long the handled deliveries are remembered to drop redeliveries
+	Retention time.Duration
+}
+
+// New returns a queue which stores the deliveries in dir
+func New(dir string, options Options, handle HandleFunc) (*Queue, error) {
+	for _, d := range []string{pendingDir, deadDir, doneDir} {
+		err := os.MkdirAll(filepath.Join(dir, d), os.ModePerm)
+		if err != nil {
+			glog.Errorf("Failed to mkdir %s: %v", filepath.Join(dir, d), err)
+			return nil, err
+		}
+	}
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 1
+	}
+	if options.Retention <= 0 {
+		options.Retention = 7 * 24 * time.Hour
+	}
+	return &Queue{
+		Dir:        dir,
+		Options:    options,
+		handle:     handle,
+		ready:      make(chan string, 1024),
+		queued:     make(map[string]bool),
+		scanPeriod: scanPeriod,
+		now:        time.Now,
+	}, nil
+}
+
+// Queue is a durable queue of webhook deliveries. Each delivery is a file so that
+// it survives a crash, it is handled by a pool of workers with exponential backoff retries.
+type Queue struct {
+	Dir     string
+	Options Options
+
+	handle HandleFunc
+	// ready are the ids of the deliveries to be handled
+	ready chan string
+	// queued are the ids which are in ready or being handled, so that the scans do not queue them twice
+	queued     map[string]bool
+	scanPeriod time.Duration
+	// lock serializes the moves between the directories
+	lock sync.Mutex
+	now  func() time.Time
+}
+
+// Add stores a delivery and queues it. It returns ErrDuplicate for a redelivery.
+func (q *Queue) Add(id string, event string, payload []byte) error {
+	if !regDeliveryID.MatchString(id) {
+		return fmt.Errorf("invalid delivery id %q", id)
+	}
+
+	q.lock.Lock()
+	// drop the delivery which is pending, dead or done
+	for _, d := range []string{pendingDir, deadDir, doneDir} {
+		if _, err := os.Stat(q.path(d, id)); err == nil {
+			q.lock.Unlock()
+			glog.Infof("Drop duplicate delivery: %s", id)
+			return ErrDuplicate
+		}
+	}
+	err := q.write(pendingDir, Delivery{
+		ID:         id,
+		Event:      event,
+		Payload:    json.RawMessage(payload),
+		ReceivedAt: q.now(),
+	})
+	q.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	q.enqueue(id)
+	return nil
+}
+
+// Run starts the workers, it queues the pending deliveries which were stored before a restart
+func (q *Queue) Run(stop <-chan struct{}) {
+	pending, err := q.list(pendingDir)
+	if err != nil {
+		glog.Errorf("Failed to list pending deliveries: %v", err)
+	}
+	for _, d := range pending {
+		q.schedule(d)
+	}
+
+	for i := 0; i < q.Options.Workers; i++ {
+		go q.work(stop)
+	}
+	go q.scan(stop)
+	go q.prune(stop)
+}
+
+// DeadLetters returns the deliveries which failed too many times
+func (q *Queue) DeadLetters() ([]Delivery, error) {
+	return q.list(deadDir)
+}
+
+// Replay moves a dead letter back into the queue. The steps which succeeded before are still skipped.
+func (q *Queue) Replay(id string) error {
+	q.lock.Lock()
+	d, err := q.read(deadDir, id)
+	if err != nil {
+		q.lock.Unlock()
+		return err
+	}
+	glog.Infof("Replay delivery: %s", id)
+	d.Attempts = 0
+	d.LastError = ""
+	d.NextAttempt = time.Time{}
+	err = q.move(deadDir, pendingDir, d)
+	q.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	q.enqueue(id)
+	return nil
+}
+
+// enqueue sends the id to the workers unless it is queued already.
+// It does not block, the scans pick the delivery up if the ready channel is full.
+func (q *Queue) enqueue(id string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.queued[id] {
+		return
+	}
+	select {
+	case q.ready <- id:
+		q.queued[id] = true
+	default:
+		glog.Infof("Ready deliveries are full, delivery %s is queued by the next scan", id)
+	}
+}
+
+// work handles the ready deliveries until stop is closed
+func (q *Queue) work(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case id := <-q.ready:
+			q.process(id)
+		}
+	}
+}
+
+// process handles a delivery and retries it on failure
+func (q *Queue) process(id string) {
+	q.lock.Lock()
+	d, err := q.read(pendingDir, id)
+	if err != nil {
+		delete(q.queued, id)
+		q.lock.Unlock()
+		glog.Errorf("Failed to read delivery %s: %v", id, err)
+		return
+	}
+	q.lock.Unlock()
+
+	panicked, err := q.safeHandle(&d)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.queued, id)
+	if err == nil {
+		// remember the delivery to drop redeliveries
+		err = ioutil.WriteFile(q.path(doneDir, id), nil, 0644)
+		if err != nil {
+			glog.Errorf("Failed to mark delivery %s done: %v", id, err)
+		}
+		err = os.Remove(q.path(pendingDir, id))
+		if err != nil {
+			glog.Errorf("Failed to remove delivery %s: %v", id, err)
+		}
+		return
+	}
+
+	d.Attempts++
+	d.LastError = err.Error()
+	// a panic is not retried, it would panic again
+	if panicked || d.Attempts >= q.Options.MaxAttempts {
+		glog.Errorf("Delivery %s failed %d times, move it to dead letters: %v", id, d.Attempts, err)
+		err = q.move(pendingDir, deadDir, d)
+		if err != nil {
+			glog.Errorf("Failed to move delivery %s to dead letters: %v", id, err)
+		}
+		return
+	}
+
+	d.NextAttempt = q.now().Add(q.backoff(d.Attempts))
+	glog.Errorf("Delivery %s failed %d times, retry at %v: %v", id, d.Attempts, d.NextAttempt, err)
+	err = q.write(pendingDir, d)
+	if err != nil {
+		glog.Errorf("Failed to save delivery %s: %v", id, err)
+		return
+	}
+	q.schedule(d)
+}
+
+// safeHandle handles a delivery and turns a panic of the handler into an error
+func (q *Queue) safeHandle(d *Delivery) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			glog.Errorf("Handler panicked on delivery %s: %v\n%s", d.ID, r, debug.Stack())
+			panicked = true
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return false, q.handle(d)
+}
+
+// backoff returns the delay before the next attempt
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.Options.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if q.Options.MaxBackoff > 0 && delay >= q.Options.MaxBackoff {
+			return q.Options.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// schedule queues the delivery at its next attempt, it may be called with the lock held
+func (q *Queue) schedule(d Delivery) {
+	time.AfterFunc(d.NextAttempt.Sub(q.now()), func() { q.enqueue(d.ID) })
+}
+
+// scan queues the pending deliveries which are due periodically until stop is closed
+func (q *Queue) scan(stop <-chan struct{}) {
+	ticker := time.NewTicker(q.scanPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		q.lock.Lock()
+		pending, err := q.list(pendingDir)
+		q.lock.Unlock()
+		if err != nil {
+			glog.Errorf("Failed to list pending deliveries: %v", err)
+			continue
+		}
+		for _, d := range pending {
+			if !d.NextAttempt.After(q.now()) {
+				q.enqueue(d.ID)
+			}
+		}
+	}
+}
+
+// prune removes the done markers which are older than the retention
+func (q *Queue) prune(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		files, err := ioutil.ReadDir(filepath.Join(q.Dir, doneDir))
+		if err != nil {
+			glog.Errorf("Failed to list done deliveries: %v", err)
+		}
+		for _, f := range files {
+			if q.now().Sub(f.ModTime()) > q.Options.Retention {
+				os.Remove(filepath.Join(q.Dir, doneDir, f.Name()))
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// path returns the file path of a delivery
+func (q *Queue) path(dir string, id string) string {
+	return filepath.Join(q.Dir, dir, id)
+}
+
+// read loads a delivery from dir
+func (q *Queue) read(dir string, id string) (Delivery, error) {
+	d := Delivery{}
+	if !regDeliveryID.MatchString(id) {
+		return d, ErrNotFound
+	}
+	b, err := ioutil.ReadFile(q.path(dir, id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return d, ErrNotFound
+		}
+		return d, err
+	}
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// write stores a delivery into dir atomically
+func (q *Queue) write(dir string, d Delivery) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	tmp := q.path(dir, "."+d.ID+".tmp")
+	err = ioutil.WriteFile(tmp, b, 0644)
+	if err != nil {
+		glog.Errorf("Failed to write delivery %s: %v", d.ID, err)
+		return err
+	}
+	return os.Rename(tmp, q.path(dir, d.ID))
+}
+
+// move stores a delivery into dir to and removes it from dir from
+func (q *Queue) move(from string, to string, d Delivery) error {
+	err := q.write(to, d)
+	if err != nil {
+		return err
+	}
+	return os.Remove(q.path(from, d.ID))
+}
+
+// list returns the deliveries in dir sorted by received time
+func (q *Queue) list(dir string) ([]Delivery, error) {
+	files, err := ioutil.ReadDir(filepath.Join(q.Dir, dir))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Delivery, 0, len(files))
+	for _, f := range files {
+		if !regDeliveryID.MatchString(f.Name()) {
+			continue
+		}
+		d, err := q.read(dir, f.Name())
+		if err != nil {
+			glog.Errorf("Failed to read delivery %s: %v", f.Name(), err)
+			continue
+		}
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ReceivedAt.Before(list[j].ReceivedAt)
+	})
+	return list, nil
+}
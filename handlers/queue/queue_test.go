@@ -0,0 +1,200 @@
+package queue
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newQueue returns a queue in a temporary directory
+func newQueue(t *testing.T, options Options, handle HandleFunc) (*Queue, func()) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	q, err := New(dir, options, handle)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("New() error = %v", err)
+	}
+	return q, func() { os.RemoveAll(dir) }
+}
+
+// wait waits for the condition until timeout
+func wait(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+//TestDuplicate tests that a redelivery is dropped after the delivery is handled
+func TestDuplicate(t *testing.T) {
+	handled := make(chan string, 10)
+	q, clean := newQueue(t, Options{}, func(d *Delivery) error {
+		handled <- d.ID
+		return nil
+	})
+	defer clean()
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Run(stop)
+
+	if err := q.Add("1", "issue_comment", []byte(`{}`)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	<-handled
+	wait(t, func() bool {
+		_, err := os.Stat(q.path(doneDir, "1"))
+		return err == nil
+	})
+	if err := q.Add("1", "issue_comment", []byte(`{}`)); err != ErrDuplicate {
+		t.Errorf("Add() error = %v, want %v", err, ErrDuplicate)
+	}
+	if err := q.Add("../1", "issue_comment", []byte(`{}`)); err == nil {
+		t.Errorf("Add() with invalid id succeeded")
+	}
+}
+
+//TestDeadLetter tests that a failed delivery is retried, moved to the dead letters and replayed
+func TestDeadLetter(t *testing.T) {
+	fail := int32(1)
+	attempts := make(chan int, 10)
+	q, clean := newQueue(t, Options{MaxAttempts: 3, Backoff: time.Millisecond}, func(d *Delivery) error {
+		attempts <- d.Attempts
+		// the steps which succeeded are kept for the retries
+		if d.Attempts > 0 && !d.IsDone("label") {
+			t.Errorf("done = %v, want label done", d.Done)
+		}
+		d.MarkDone("label")
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("github is down")
+		}
+		return nil
+	})
+	defer clean()
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Run(stop)
+
+	if err := q.Add("1", "issue_comment", []byte(`{}`)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if got := <-attempts; got != i {
+			t.Errorf("attempts = %d, want %d", got, i)
+		}
+	}
+	var dead []Delivery
+	wait(t, func() bool {
+		dead, _ = q.DeadLetters()
+		return len(dead) == 1
+	})
+	if dead[0].Attempts != 3 || dead[0].LastError != "github is down" {
+		t.Errorf("dead letter = %+v, want 3 attempts", dead[0])
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	if err := q.Replay("2"); err != ErrNotFound {
+		t.Errorf("Replay() error = %v, want %v", err, ErrNotFound)
+	}
+	if err := q.Replay("1"); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	<-attempts
+	wait(t, func() bool {
+		_, err := os.Stat(q.path(doneDir, "1"))
+		return err == nil
+	})
+	if dead, _ = q.DeadLetters(); len(dead) != 0 {
+		t.Errorf("dead letters = %v, want empty", dead)
+	}
+}
+
+//TestPanic tests that a delivery whose handler panics is moved to the dead letters without retries
+func TestPanic(t *testing.T) {
+	attempts := int32(0)
+	q, clean := newQueue(t, Options{MaxAttempts: 3, Backoff: time.Millisecond}, func(d *Delivery) error {
+		atomic.AddInt32(&attempts, 1)
+		var body *string
+		_ = *body
+		return nil
+	})
+	defer clean()
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Run(stop)
+
+	if err := q.Add("1", "pull_request", []byte(`{}`)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	var dead []Delivery
+	wait(t, func() bool {
+		dead, _ = q.DeadLetters()
+		return len(dead) == 1
+	})
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	if _, err := os.Stat(q.path(pendingDir, "1")); !os.IsNotExist(err) {
+		t.Errorf("pending delivery exists, want it moved to the dead letters: %v", err)
+	}
+}
+
+//TestRestart tests that the pending deliveries are handled after a restart
+func TestRestart(t *testing.T) {
+	q, clean := newQueue(t, Options{}, nil)
+	defer clean()
+	err := q.write(pendingDir, Delivery{ID: "1", Event: "issue_comment"})
+	if err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	handled := make(chan string, 1)
+	q, err = New(q.Dir, Options{}, func(d *Delivery) error {
+		handled <- d.ID
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Run(stop)
+	if got := <-handled; got != "1" {
+		t.Errorf("handled = %s, want 1", got)
+	}
+}
+
+//TestFullReady tests that adding a delivery does not block when the ready channel is full, and the scan queues it later
+func TestFullReady(t *testing.T) {
+	handled := make(chan string, 1)
+	q, clean := newQueue(t, Options{}, func(d *Delivery) error {
+		handled <- d.ID
+		return nil
+	})
+	defer clean()
+	q.ready = make(chan string)
+	q.scanPeriod = 10 * time.Millisecond
+
+	if err := q.Add("1", "issue_comment", []byte(`{}`)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Run(stop)
+	select {
+	case got := <-handled:
+		if got != "1" {
+			t.Errorf("handled = %s, want 1", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout")
+	}
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+// NewAPIRepository returns a repository instance which reads the owners files with the GitHub API
+func NewAPIRepository(client *github.Client, repository string) (*APIRepository, error) {
+	glog.Infof("New api repository : %s", repository)
+	org, repo, err := ParseRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+	return &APIRepository{
+		GithubClient: client,
+		Org:          org,
+		Repo:         repo,
+		snapshots:    newSnapshotCache(DefaultMaxSnapshots),
+	}, nil
+}
+
+var _ Interface = &APIRepository{}
+
+// APIRepository reads the owners files without a local git mirror. It lists the owners files of a commit
+// with the Git Trees API and fetches only their blobs, and a blob is fetched again only if its sha is changed.
+// It is safe for concurrent use like Repository.
+type APIRepository struct {
+	GithubClient *github.Client
+
+	Org  string
+	Repo string
+
+	// buildLock serializes the builds of the snapshots
+	buildLock sync.Mutex
+	snapshots *snapshotCache
+}
+
+// Init repository, there is nothing to clone
+func (o *APIRepository) Init() error {
+	glog.Infof("Init api repository. org: %s repo: %s", o.Org, o.Repo)
+	return nil
+}
+
+// Clear repository, there is nothing to remove
+func (o *APIRepository) Clear() error {
+	glog.Infof("Clear api repository. org: %s repo: %s", o.Org, o.Repo)
+	return nil
+}
+
+// LoadOwners loads the owners of the branch
+func (o *APIRepository) LoadOwners(branch string) (Owners, error) {
+	glog.Infof("Load owners started. org: %s repo: %s branch: %s", o.Org, o.Repo, branch)
+	sha, err := headSha(o.GithubClient, o.Org, o.Repo, branch)
+	if err != nil {
+		return nil, err
+	}
+	s, err := o.snapshot(branch, sha)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh rebuilds the owners of a cached branch which is pushed to the sha
+func (o *APIRepository) Refresh(branch string, sha string) error {
+	if !o.snapshots.contains(branch) {
+		return nil
+	}
+	glog.Infof("Refresh owners. org: %s repo: %s branch: %s sha: %s", o.Org, o.Repo, branch, sha)
+	_, err := o.snapshot(branch, sha)
+	return err
+}
+
+// snapshot returns the snapshot of the branch at the sha, the blobs of the cached snapshot are reused
+func (o *APIRepository) snapshot(branch string, sha string) (*Snapshot, error) {
+	if s := o.snapshots.get(branch); s != nil && s.Sha == sha {
+		return s, nil
+	}
+
+	o.buildLock.Lock()
+	defer o.buildLock.Unlock()
+	// the snapshot may be built while waiting for the lock
+	prev := o.snapshots.get(branch)
+	if prev != nil && prev.Sha == sha {
+		return prev, nil
+	}
+
+	blobs, err := o.listOwnersFiles(sha)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := o.buildOwners(prev, blobs)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{Branch: branch, Sha: sha, Owners: owners, blobs: blobs}
+	o.snapshots.add(s)
+	return s, nil
+}
+
+// listOwnersFiles returns the blob shas of the owners files of the commit by their paths
+func (o *APIRepository) listOwnersFiles(sha string) (map[string]string, error) {
+	tree, _, err := o.GithubClient.Git.GetTree(context.Background(), o.Org, o.Repo, sha, true)
+	if err != nil {
+		glog.Errorf("Failed to get tree of %s: %v", sha, err)
+		return nil, err
+	}
+	// the owners files which are not listed would be lost silently
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("the tree of %s/%s at %s is truncated, use the git owners backend", o.Org, o.Repo, sha)
+	}
+	blobs := make(map[string]string)
+	for _, e := range tree.Entries {
+		if e.GetType() == "blob" && IsOwnersFile(e.GetPath()) {
+			blobs[e.GetPath()] = e.GetSHA()
+		}
+	}
+	return blobs, nil
+}
+
+// buildOwners reads the owners files whose blobs are changed since the previous snapshot.
+// All the owners files are read if the aliases are changed because they are used by all the owners files.
+func (o *APIRepository) buildOwners(prev *Snapshot, blobs map[string]string) (*OwnersIndex, error) {
+	if prev == nil || prev.blobs[OwnersAliasesFileName] != blobs[OwnersAliasesFileName] {
+		glog.Infof("Build owners. org: %s repo: %s", o.Org, o.Repo)
+		owners := NewOwnersIndex()
+		err := o.addOwnersFiles(owners, blobs, blobs)
+		if err != nil {
+			return nil, err
+		}
+		return owners, nil
+	}
+
+	changed := make(map[string]string)
+	removed := make([]string, 0)
+	for path, blob := range blobs {
+		if prev.blobs[path] != blob {
+			changed[path] = blob
+		}
+	}
+	for path := range prev.blobs {
+		if _, ok := blobs[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	// Owners files are not changed
+	if len(changed) == 0 && len(removed) == 0 {
+		glog.Info("Owners files are not changed.")
+		return prev.Owners, nil
+	}
+
+	glog.Infof("Update owners files: %v, remove owners files: %v", changed, removed)
+	owners := prev.Owners.Copy()
+	for _, path := range removed {
+		owners.Remove(path)
+	}
+	for path := range changed {
+		owners.Remove(path)
+	}
+	err := o.addOwnersFiles(owners, blobs, changed)
+	if err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// addOwnersFiles fetches the blobs of the files and reads them into the index
+func (o *APIRepository) addOwnersFiles(owners *OwnersIndex, blobs map[string]string, files map[string]string) error {
+	// the aliases are used by the owners files
+	if _, ok := files[OwnersAliasesFileName]; ok {
+		b, err := o.blob(blobs[OwnersAliasesFileName])
+		if err != nil {
+			return err
+		}
+		owners.SetAliases(b)
+	}
+	for path, sha := range files {
+		if path == OwnersAliasesFileName {
+			continue
+		}
+		b, err := o.blob(sha)
+		if err != nil {
+			return err
+		}
+		// a malformed file does not fail the load, it is kept in OwnersIndex.Malformed
+		owners.Add(path, b)
+	}
+	return nil
+}
+
+// blob returns the content of the blob
+func (o *APIRepository) blob(sha string) ([]byte, error) {
+	b, _, err := o.GithubClient.Git.GetBlobRaw(context.Background(), o.Org, o.Repo, sha)
+	if err != nil {
+		glog.Errorf("Failed to get blob %s: %v", sha, err)
+		return nil, err
+	}
+	return b, nil
+}
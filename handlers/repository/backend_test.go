@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeGitAPI serves the refs, trees and blobs of the upstream repository like the GitHub Git API
+type fakeGitAPI struct {
+	dir   string
+	blobs int64
+}
+
+// git runs a git command in the upstream repository
+func (f *fakeGitAPI) git(args ...string) (string, error) {
+	c := exec.Command("git", args...)
+	c.Dir = f.dir
+	bs, err := c.Output()
+	return string(bs), err
+}
+
+func (f *fakeGitAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/repos/test/hello/git/")
+	switch {
+	case strings.HasPrefix(path, "refs/heads/"):
+		sha, err := f.git("rev-parse", strings.TrimPrefix(path, "refs/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"ref": "refs/%s", "object": {"sha": "%s"}}`, strings.TrimPrefix(path, "refs/"), strings.TrimSpace(sha))
+	case strings.HasPrefix(path, "trees/"):
+		out, err := f.git("ls-tree", "-r", strings.TrimPrefix(path, "trees/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		// e.g. 100644 blob 0123abcd	pkg/OWNERS
+		entries := make([]string, 0)
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			strs := strings.SplitN(line, "\t", 2)
+			fields := strings.Fields(strs[0])
+			entries = append(entries, fmt.Sprintf(`{"path": "%s", "mode": "%s", "type": "%s", "sha": "%s"}`,
+				strs[1], fields[0], fields[1], fields[2]))
+		}
+		fmt.Fprintf(w, `{"sha": "%s", "tree": [%s], "truncated": false}`, strings.TrimPrefix(path, "trees/"), strings.Join(entries, ","))
+	case strings.HasPrefix(path, "blobs/"):
+		atomic.AddInt64(&f.blobs, 1)
+		out, err := f.git("cat-file", "blob", strings.TrimPrefix(path, "blobs/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(out))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestClient returns a github client of the fake api
+func newTestClient(server *httptest.Server) *github.Client {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return client
+}
+
+// testBackend runs the test suite which is shared by the owners backends
+func testBackend(t *testing.T, newBackend func(u *testUpstream, client *github.Client) Interface) {
+	u := newTestUpstream(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(u.dir)))
+	server := httptest.NewServer(&fakeGitAPI{dir: u.dir})
+	defer server.Close()
+	r := newBackend(u, newTestClient(server))
+	defer r.Clear()
+
+	u.commit(map[string]string{
+		"OWNERS":         "approvers: [root-team]",
+		"OWNERS_ALIASES": "aliases:\n  root-team: [alice]",
+		"pkg/OWNERS":     "approvers: [bob]\nlabels: [sig/pkg]",
+		"pkg/main.go":    "package main",
+		"broken/OWNERS":  "approvers: alice",
+	})
+	u.git("branch", "release-1.0")
+	master, err := r.LoadOwners("master")
+	if err != nil {
+		t.Fatalf("LoadOwners() error = %v", err)
+	}
+	if got := master.GetAllApprovers("pkg/main.go"); !reflect.DeepEqual(got, users("alice", "bob")) {
+		t.Errorf("approvers = %v, want alice and bob", got)
+	}
+	if got := master.GetApproversFilePath("pkg/main.go"); got != "pkg" {
+		t.Errorf("GetApproversFilePath() = %q, want pkg", got)
+	}
+	if got := master.GetLabels("pkg/main.go"); !reflect.DeepEqual(got, users("sig/pkg")) {
+		t.Errorf("labels = %v, want sig/pkg", got)
+	}
+	if _, ok := master.GetMalformedOwnersFiles()["broken/OWNERS"]; !ok {
+		t.Errorf("broken/OWNERS is not reported")
+	}
+
+	// the branches have their own owners
+	u.commit(map[string]string{"pkg/OWNERS": "approvers: [carol]"})
+	updated, err := r.LoadOwners("master")
+	if err != nil {
+		t.Fatalf("LoadOwners() error = %v", err)
+	}
+	if got := updated.GetClosestApprovers("pkg/main.go"); !reflect.DeepEqual(got, users("carol")) {
+		t.Errorf("approvers = %v, want carol", got)
+	}
+	release, err := r.LoadOwners("release-1.0")
+	if err != nil {
+		t.Fatalf("LoadOwners() error = %v", err)
+	}
+	if got := release.GetClosestApprovers("pkg/main.go"); !reflect.DeepEqual(got, users("bob")) {
+		t.Errorf("approvers of release-1.0 = %v, want bob", got)
+	}
+	if got := master.GetClosestApprovers("pkg/main.go"); !reflect.DeepEqual(got, users("bob")) {
+		t.Errorf("the previous owners are changed, approvers = %v", got)
+	}
+
+	// the deleted owners file and the changed aliases are refreshed by a push
+	sha := u.commit(map[string]string{"pkg/OWNERS": "", "OWNERS_ALIASES": "aliases:\n  root-team: [dave]"})
+	if err := r.Refresh("master", sha); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	pushed, err := r.LoadOwners("master")
+	if err != nil {
+		t.Fatalf("LoadOwners() error = %v", err)
+	}
+	if got := pushed.GetAllApprovers("pkg/main.go"); !reflect.DeepEqual(got, users("dave")) {
+		t.Errorf("approvers = %v, want dave", got)
+	}
+}
+
+//TestGitBackend tests the owners backend of the local git mirror
+func TestGitBackend(t *testing.T) {
+	testBackend(t, func(u *testUpstream, client *github.Client) Interface {
+		r := newTestRepository(t, u, 0)
+		r.GithubClient = client
+		return r
+	})
+}
+
+//TestAPIBackend tests the owners backend of the GitHub Git Trees API
+func TestAPIBackend(t *testing.T) {
+	testBackend(t, func(u *testUpstream, client *github.Client) Interface {
+		r, err := NewAPIRepository(client, "test/hello")
+		if err != nil {
+			t.Fatalf("NewAPIRepository() error = %v", err)
+		}
+		return r
+	})
+}
+
+//TestAPIBackendBlobs tests that only the changed blobs are fetched
+func TestAPIBackendBlobs(t *testing.T) {
+	u := newTestUpstream(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(u.dir)))
+	api := &fakeGitAPI{dir: u.dir}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	r, err := NewAPIRepository(newTestClient(server), "test/hello")
+	if err != nil {
+		t.Fatalf("NewAPIRepository() error = %v", err)
+	}
+
+	u.commit(map[string]string{"OWNERS": "approvers: [alice]", "pkg/OWNERS": "approvers: [bob]", "main.go": "package main"})
+	if _, err := r.LoadOwners("master"); err != nil {
+		t.Fatalf("LoadOwners() error = %v", err)
+	}
+	u.commit(map[string]string{"pkg/OWNERS": "approvers: [carol]", "main.go": "package main\n"})
+	if _, err := r.LoadOwners("master"); err != nil {
+		t.Fatalf("LoadOwners() error = %v", err)
+	}
+	if got := atomic.LoadInt64(&api.blobs); got != 3 {
+		t.Errorf("%d blobs are fetched, want 3", got)
+	}
+}
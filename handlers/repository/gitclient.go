@@ -8,6 +8,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/golang/glog"
 )
@@ -37,16 +39,20 @@ func NewGitClient(repo string) (*GitClient, error) {
 type GitClient struct {
 	// local mirror dir
 	LocalMirrorDir string
-	// local repository dir
-	LocalRepoDir string
 	// github base url
 	BaseURL string
 	// repository org and name
 	Repo string
+
+	// mirrorLock serializes the clones and fetches of the mirror
+	mirrorLock sync.Mutex
 }
 
 // CloneMirror clones a mirror into local tmp folder
 func (r *GitClient) CloneMirror() error {
+	r.mirrorLock.Lock()
+	defer r.mirrorLock.Unlock()
+
 	// e.g. localMirror=/tmp/mirror170610160/test/hello
 	localMirror := filepath.Join(r.LocalMirrorDir, r.Repo)
 	// e.g. remote=https://github.com/test/hello
@@ -95,42 +101,6 @@ func (r *GitClient) CloneMirror() error {
 	return nil
 }
 
-// CloneRepo clones a repository into local tmp folder
-func (r *GitClient) CloneRepo() error {
-	// e.g. localMirror=/tmp/mirror170610160/test/hello
-	localMirror := filepath.Join(r.LocalMirrorDir, r.Repo)
-
-	// clone mirror
-	err := r.CloneMirror()
-	if err != nil {
-		// clone mirror error
-		glog.Errorf("Failed to clone mirror: %v", err)
-		return err
-	}
-
-	// it is used to store repository. e.g. tmpRepoDir=/tmp/repo170610160
-	r.LocalRepoDir, err = ioutil.TempDir("", "repo")
-	if err != nil {
-		glog.Errorf("Failed to create tmp repository dir: %v", err)
-		return err
-	}
-
-	// clone repository
-	glog.Infof("Clone repository from localMirror: %s to LocalRepoDir: %s", localMirror, r.LocalRepoDir)
-	bs, err := exec.Command("git", "clone", localMirror, r.LocalRepoDir).CombinedOutput()
-	if err != nil {
-		// clone repository error
-		glog.Errorf("Failed to clone repository from localMirror %s error: %v", string(bs), err)
-		return err
-	}
-
-	return nil
-}
-
-func (r *GitClient) GetLocalRepoDir() string{
-	return r.LocalRepoDir
-}
-
 // RemoveMirror removes tmp local mirror dir
 func (r *GitClient) RemoveMirror() error {
 	// remove local mirror dir
@@ -144,46 +114,60 @@ func (r *GitClient) RemoveMirror() error {
 	return nil
 }
 
-// RemoveRepo removes tmp local repository dir
-func (r *GitClient) RemoveRepo() error {
-	// remove local repo dir
-	glog.Infof("Remove repository: %s", r.LocalRepoDir)
-	err := os.RemoveAll(r.LocalRepoDir)
+// Diff runs the command: git diff in the mirror. The renamed files are listed as deleted and added files.
+func (r *GitClient) Diff(head string, sha string) (changes []string, err error) {
+	glog.Infof("Diff head %s sha %s", head, sha)
+	bs, err := r.git("diff", "--name-only", "--no-renames", head, sha)
 	if err != nil {
-		// remove error
-		glog.Errorf("Failed to remove repository: %v", err)
-		return err
+		// git diff error
+		glog.Errorf("Failed to git diff: %v", err)
+		return nil, err
 	}
-	return nil
+	return lines(bs), nil
 }
 
-// CheckOut runs the command: git checkout
-func (r *GitClient) CheckOut(branch string, localDir string) error {
-	glog.Infof("Checkout: %s", branch)
-	bs:= exec.Command("git", "checkout", branch)
-	bs.Dir = localDir
-	output, err := bs.CombinedOutput()
+// ListFiles runs the command: git ls-tree in the mirror. It lists the files of the commit,
+// only the given paths are listed if they are not empty.
+func (r *GitClient) ListFiles(sha string, paths ...string) ([]string, error) {
+	args := append([]string{"ls-tree", "-r", "--name-only", sha, "--"}, paths...)
+	bs, err := r.git(args...)
 	if err != nil {
-		// git checkout error
-		glog.Errorf("Failed to git checkout %s: %v", string(output), err)
-		return err
+		glog.Errorf("Failed to git ls-tree: %v", err)
+		return nil, err
 	}
-	return err
+	return lines(bs), nil
 }
 
-// Diff runs the command: git diff
-func (r *GitClient) Diff(head string, sha string) (changes []string, err error) {
-	glog.Infof("Diff head %s sha %s", head, sha)
-	bs, err := exec.Command("git", "diff", head, sha, "--name-only").CombinedOutput()
+// ShowFile runs the command: git show in the mirror. It returns the content of the file in the commit.
+func (r *GitClient) ShowFile(sha string, path string) ([]byte, error) {
+	bs, err := r.git("show", fmt.Sprintf("%s:%s", sha, path))
 	if err != nil {
-		// git diff error
-		glog.Errorf("Failed to git diff %s: %v", string(bs), err)
+		glog.Errorf("Failed to git show %s:%s: %v", sha, path, err)
 		return nil, err
 	}
+	return bs, nil
+}
+
+// git runs a git command in the mirror and returns its output
+func (r *GitClient) git(args ...string) ([]byte, error) {
+	c := exec.Command("git", args...)
+	c.Dir = filepath.Join(r.LocalMirrorDir, r.Repo)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	bs, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return bs, nil
+}
+
+// lines splits the output into lines
+func lines(bs []byte) []string {
+	out := make([]string, 0)
 	scan := bufio.NewScanner(bytes.NewReader(bs))
 	scan.Split(bufio.ScanLines)
 	for scan.Scan() {
-		changes = append(changes, scan.Text())
+		out = append(out, scan.Text())
 	}
-	return changes, err
+	return out
 }
@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// OwnersAliasesFileName is the file in the root directory which defines the aliases
+	OwnersAliasesFileName = "OWNERS_ALIASES"
+)
+
+// OwnersFile defines the content format of owners file. e.g.
+//
+//	options:
+//	  no_parent_owners: true
+//	approvers: [alice, sig-node-approvers]
+//	reviewers: [bob]
+//	required_reviewers: [erin]
+//	emeritus_approvers: [carol]
+//	labels: [sig/node]
+//	filters:
+//	  "\\.md$":
+//	    approvers: [dave]
+//	    labels: [kind/documentation]
+type OwnersFile struct {
+	Options OwnersOptions `yaml:"options,omitempty"`
+	// OwnersConfig applies to all the files
+	OwnersConfig `yaml:",inline"`
+	// Filters apply to the files whose path matches the regular expression key
+	Filters map[string]OwnersConfig `yaml:"filters,omitempty"`
+}
+
+// OwnersOptions defines the options of an owners file
+type OwnersOptions struct {
+	// NoParentOwners stops the inheritance of the owners in the parent directories
+	NoParentOwners bool `yaml:"no_parent_owners,omitempty"`
+}
+
+// OwnersConfig defines the owners and the labels of a set of files
+type OwnersConfig struct {
+	Approvers []string `yaml:"approvers,omitempty"`
+	Reviewers []string `yaml:"reviewers,omitempty"`
+	// RequiredReviewers must give lgtm before the pull requests which change the files get the lgtm label
+	RequiredReviewers []string `yaml:"required_reviewers,omitempty"`
+	// EmeritusApprovers are the former approvers, they can not approve
+	EmeritusApprovers []string `yaml:"emeritus_approvers,omitempty"`
+	// Labels are added to the pull requests which change the files
+	Labels []string `yaml:"labels,omitempty"`
+}
+
+// OwnersAliasesFile defines the content format of the aliases file. e.g.
+//
+//	aliases:
+//	  sig-node-approvers: [alice, bob]
+type OwnersAliasesFile struct {
+	Aliases map[string][]string `yaml:"aliases,omitempty"`
+}
+
+// owners kinds
+const (
+	kindApprovers = iota
+	kindReviewers
+	kindLabels
+	kindRequiredReviewers
+)
+
+// ownersFilter is a filter of an owners file
+type ownersFilter struct {
+	re     *regexp.Regexp
+	owners [4]map[string]string
+}
+
+// ownersEntry is the parsed owners file of a directory
+type ownersEntry struct {
+	noParentOwners bool
+	emeritus       map[string]string
+	filters        []ownersFilter
+}
+
+// OwnersIndex contains the owners files of a repository
+type OwnersIndex struct {
+	aliases map[string][]string
+	// entries are keyed by the directory, it is empty for the root directory
+	entries map[string]*ownersEntry
+	// malformed are the owners files which can not be parsed, they are keyed by the file path
+	malformed map[string]string
+	// warnings are the unknown keys of the owners files which are used anyway, they are keyed by the file path
+	warnings map[string]string
+}
+
+// NewOwnersIndex returns an empty index
+func NewOwnersIndex() *OwnersIndex {
+	return &OwnersIndex{
+		aliases:   make(map[string][]string),
+		entries:   make(map[string]*ownersEntry),
+		malformed: make(map[string]string),
+		warnings:  make(map[string]string),
+	}
+}
+
+// IsOwnersFile checks if the file path is an owners file or the aliases file
+func IsOwnersFile(path string) bool {
+	return filepath.Base(path) == OwnersFileName || path == OwnersAliasesFileName
+}
+
+// SetAliases parses the content of the aliases file. The aliases must be set before the owners files are added.
+func (x *OwnersIndex) SetAliases(b []byte) {
+	f := OwnersAliasesFile{}
+	if !x.unmarshal(OwnersAliasesFileName, b, &f) {
+		return
+	}
+	x.aliases = make(map[string][]string)
+	for k, v := range f.Aliases {
+		x.aliases[k] = v
+	}
+}
+
+// Add parses the content of an owners file. e.g. path=pkg/OWNERS
+// The malformed file is reported by Malformed and it does not own any file.
+// The unknown keys are reported by Warnings and the file is used without them.
+func (x *OwnersIndex) Add(path string, b []byte) {
+	dir := filterFilePath(filepath.Dir(path))
+	f := OwnersFile{}
+	if !x.unmarshal(path, b, &f) {
+		return
+	}
+
+	e := &ownersEntry{
+		noParentOwners: f.Options.NoParentOwners,
+		emeritus:       make(map[string]string),
+	}
+	// the filter of the top level owners matches all the files
+	configs := map[string]OwnersConfig{".*": f.OwnersConfig}
+	keys := []string{".*"}
+	for k, c := range f.Filters {
+		if k == ".*" {
+			configs[k] = merge(configs[k], c)
+			continue
+		}
+		configs[k] = c
+		keys = append(keys, k)
+	}
+	sort.Strings(keys[1:])
+	for _, c := range configs {
+		for k, v := range x.expand(c.EmeritusApprovers) {
+			e.emeritus[k] = v
+		}
+	}
+	for _, k := range keys {
+		re, err := regexp.Compile(k)
+		if err != nil {
+			glog.Errorf("Malformed owners file %s: %v", path, err)
+			x.malformed[path] = fmt.Sprintf("invalid filter %q: %v", k, err)
+			return
+		}
+		c := configs[k]
+		filter := ownersFilter{re: re}
+		filter.owners[kindApprovers] = x.expand(c.Approvers)
+		filter.owners[kindReviewers] = x.expand(c.Reviewers)
+		filter.owners[kindLabels] = set(c.Labels)
+		filter.owners[kindRequiredReviewers] = x.expand(c.RequiredReviewers)
+		// the emeritus approvers can not approve
+		for k := range e.emeritus {
+			delete(filter.owners[kindApprovers], k)
+		}
+		e.filters = append(e.filters, filter)
+	}
+	delete(x.malformed, path)
+	x.entries[dir] = e
+}
+
+// unmarshal parses the file, it returns false if the file is malformed.
+// The unknown keys and the duplicated keys are not errors, they are recorded as the warnings of the file.
+func (x *OwnersIndex) unmarshal(path string, b []byte, out interface{}) bool {
+	delete(x.warnings, path)
+	err := yaml.Unmarshal(b, out)
+	if err != nil {
+		glog.Errorf("Malformed %s: %v", path, err)
+		x.malformed[path] = err.Error()
+		return false
+	}
+	// the strict parse fails only for the keys which the lenient parse ignores
+	strict := reflect.New(reflect.TypeOf(out).Elem()).Interface()
+	err = yaml.UnmarshalStrict(b, strict)
+	if err != nil {
+		glog.Warningf("Owners file %s has unknown keys: %v", path, err)
+		x.warnings[path] = err.Error()
+	}
+	return true
+}
+
+// Remove removes an owners file which is deleted. e.g. path=pkg/OWNERS
+func (x *OwnersIndex) Remove(path string) {
+	delete(x.malformed, path)
+	delete(x.warnings, path)
+	if path == OwnersAliasesFileName {
+		x.aliases = make(map[string][]string)
+		return
+	}
+	delete(x.entries, filterFilePath(filepath.Dir(path)))
+}
+
+// Copy returns a copy of the index which can be changed without changing the index
+func (x *OwnersIndex) Copy() *OwnersIndex {
+	c := NewOwnersIndex()
+	for k, v := range x.aliases {
+		c.aliases[k] = v
+	}
+	// the entries are not changed after they are added
+	for k, v := range x.entries {
+		c.entries[k] = v
+	}
+	for k, v := range x.malformed {
+		c.malformed[k] = v
+	}
+	for k, v := range x.warnings {
+		c.warnings[k] = v
+	}
+	return c
+}
+
+// Malformed returns the owners files which can not be parsed and their errors
+func (x *OwnersIndex) Malformed() map[string]string {
+	out := make(map[string]string)
+	for k, v := range x.malformed {
+		out[k] = v
+	}
+	return out
+}
+
+// Warnings returns the owners files which have unknown keys and the errors of the keys
+func (x *OwnersIndex) Warnings() map[string]string {
+	out := make(map[string]string)
+	for k, v := range x.warnings {
+		out[k] = v
+	}
+	return out
+}
+
+// Emeritus returns the emeritus approvers of the closest owners file
+func (x *OwnersIndex) Emeritus(path string) map[string]string {
+	out := make(map[string]string)
+	x.walk(path, func(d string, e *ownersEntry) bool {
+		for k, v := range e.emeritus {
+			out[k] = v
+		}
+		return len(out) == 0
+	})
+	return out
+}
+
+// OwnersFilePath returns the directory of the closest owners file which contains the owners of the path
+func (x *OwnersIndex) OwnersFilePath(path string, kind int) string {
+	found := ""
+	x.walk(path, func(d string, e *ownersEntry) bool {
+		if len(e.owners(path, kind)) > 0 {
+			found = d
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// Owners returns the owners of the path. Only the closest owners are returned if closest is true.
+func (x *OwnersIndex) Owners(path string, kind int, closest bool) map[string]string {
+	out := make(map[string]string)
+	x.walk(path, func(d string, e *ownersEntry) bool {
+		for k, v := range e.owners(path, kind) {
+			out[k] = v
+		}
+		// the closest owners are gotten
+		return !closest || len(out) == 0
+	})
+	return out
+}
+
+// walk calls f with the owners files from the path to the root directory until f returns false
+// or an owners file with no_parent_owners is reached
+func (x *OwnersIndex) walk(path string, f func(d string, e *ownersEntry) bool) {
+	d := filterFilePath(path)
+	for {
+		if e, ok := x.entries[d]; ok {
+			if !f(d, e) || e.noParentOwners {
+				return
+			}
+		}
+		// break if the path is root
+		if d == "" {
+			return
+		}
+		// get the directory of file or directory path
+		d = filterFilePath(filepath.Dir(d))
+	}
+}
+
+// expand replaces the aliases with their members
+func (x *OwnersIndex) expand(names []string) map[string]string {
+	out := make(map[string]string)
+	for _, n := range names {
+		if members, ok := x.aliases[n]; ok {
+			for _, m := range members {
+				out[m] = m
+			}
+			continue
+		}
+		out[n] = n
+	}
+	return out
+}
+
+// owners returns the owners of the filters which match the path
+func (e *ownersEntry) owners(path string, kind int) map[string]string {
+	out := make(map[string]string)
+	for _, f := range e.filters {
+		if !f.re.MatchString(path) {
+			continue
+		}
+		for k, v := range f.owners[kind] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// merge returns the union of the owners configs
+func merge(a OwnersConfig, b OwnersConfig) OwnersConfig {
+	return OwnersConfig{
+		Approvers:         append(append([]string{}, a.Approvers...), b.Approvers...),
+		Reviewers:         append(append([]string{}, a.Reviewers...), b.Reviewers...),
+		RequiredReviewers: append(append([]string{}, a.RequiredReviewers...), b.RequiredReviewers...),
+		EmeritusApprovers: append(append([]string{}, a.EmeritusApprovers...), b.EmeritusApprovers...),
+		Labels:            append(append([]string{}, a.Labels...), b.Labels...),
+	}
+}
+
+// set returns the set of the names
+func set(names []string) map[string]string {
+	out := make(map[string]string)
+	for _, n := range names {
+		out[n] = n
+	}
+	return out
+}
+
+// filterFilePath format the file path
+func filterFilePath(path string) string {
+	if path == "." || path == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(path, "/")
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testAliases = `
+aliases:
+  node-approvers: [alice, bob]
+`
+
+// testOwnersFiles are the owners files of the test repository
+var testOwnersFiles = map[string]string{
+	"OWNERS": `
+approvers: [root]
+reviewers: [root-reviewer]
+labels: [sig/all]
+`,
+	"pkg/node/OWNERS": `
+approvers: [node-approvers, carol]
+emeritus_approvers: [carol]
+labels: [sig/node]
+`,
+	"pkg/node/kubelet/OWNERS": `
+reviewers: [dave]
+required_reviewers: [dave, node-approvers]
+`,
+	"vendor/OWNERS": `
+options:
+  no_parent_owners: true
+approvers: [vendor-admin]
+`,
+	"docs/OWNERS": `
+filters:
+  ".*":
+    reviewers: [erin]
+  "\\.md$":
+    approvers: [writer]
+    labels: [kind/documentation]
+`,
+	"broken/OWNERS": `
+approvers: alice
+`,
+	"unknown/OWNERS": `
+aprovers: [alice]
+approvers: [frank]
+`,
+	"regexp/OWNERS": `
+filters:
+  "[":
+    approvers: [alice]
+`,
+}
+
+// newTestIndex returns the index of the test repository
+func newTestIndex() *OwnersIndex {
+	x := NewOwnersIndex()
+	x.SetAliases([]byte(testAliases))
+	for path, content := range testOwnersFiles {
+		x.Add(path, []byte(content))
+	}
+	return x
+}
+
+// users returns the set of the users
+func users(names ...string) map[string]string {
+	return set(names)
+}
+
+//TestOwners tests the owners of the paths
+func TestOwners(t *testing.T) {
+	x := newTestIndex()
+	tests := []struct {
+		name    string
+		path    string
+		kind    int
+		closest bool
+		want    map[string]string
+	}{
+		{name: "root", path: "main.go", kind: kindApprovers, closest: true, want: users("root")},
+		{name: "aliases and emeritus", path: "pkg/node/node.go", kind: kindApprovers, closest: true, want: users("alice", "bob")},
+		{name: "inherited", path: "pkg/node/node.go", kind: kindApprovers, want: users("alice", "bob", "root")},
+		{name: "closest reviewers", path: "pkg/node/kubelet/kubelet.go", kind: kindReviewers, closest: true, want: users("dave")},
+		{name: "parent approvers", path: "pkg/node/kubelet/kubelet.go", kind: kindApprovers, closest: true, want: users("alice", "bob")},
+		{name: "no parent owners", path: "vendor/lib/lib.go", kind: kindApprovers, want: users("vendor-admin")},
+		{name: "no parent reviewers", path: "vendor/lib/lib.go", kind: kindReviewers, want: users()},
+		{name: "filter", path: "docs/README.md", kind: kindApprovers, closest: true, want: users("writer")},
+		{name: "filter does not match", path: "docs/logo.png", kind: kindApprovers, closest: true, want: users("root")},
+		{name: "filter of all files", path: "docs/logo.png", kind: kindReviewers, closest: true, want: users("erin")},
+		{name: "labels", path: "pkg/node/node.go", kind: kindLabels, want: users("sig/all", "sig/node")},
+		{name: "filter labels", path: "docs/README.md", kind: kindLabels, want: users("sig/all", "kind/documentation")},
+		{name: "required reviewers", path: "pkg/node/kubelet/kubelet.go", kind: kindRequiredReviewers, want: users("alice", "bob", "dave")},
+		{name: "no required reviewers", path: "pkg/node/node.go", kind: kindRequiredReviewers, want: users()},
+		{name: "unknown keys", path: "unknown/main.go", kind: kindApprovers, closest: true, want: users("frank")},
+		{name: "malformed", path: "broken/main.go", kind: kindApprovers, closest: true, want: users("root")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := x.Owners(tt.path, tt.kind, tt.closest); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Owners() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := x.Emeritus("pkg/node/node.go"); !reflect.DeepEqual(got, users("carol")) {
+		t.Errorf("Emeritus() = %v, want carol", got)
+	}
+}
+
+//TestOwnersFilePath tests the closest owners file of the paths
+func TestOwnersFilePath(t *testing.T) {
+	x := newTestIndex()
+	tests := []struct {
+		path string
+		kind int
+		want string
+	}{
+		{path: "main.go", kind: kindApprovers, want: ""},
+		{path: "pkg/node/kubelet/kubelet.go", kind: kindApprovers, want: "pkg/node"},
+		{path: "pkg/node/kubelet/kubelet.go", kind: kindReviewers, want: "pkg/node/kubelet"},
+		{path: "docs/README.md", kind: kindApprovers, want: "docs"},
+		{path: "docs/logo.png", kind: kindApprovers, want: ""},
+		{path: "vendor/lib", kind: kindReviewers, want: ""},
+	}
+	for _, tt := range tests {
+		if got := x.OwnersFilePath(tt.path, tt.kind); got != tt.want {
+			t.Errorf("OwnersFilePath(%s, %d) = %q, want %q", tt.path, tt.kind, got, tt.want)
+		}
+	}
+}
+
+//TestMalformed tests that the malformed owners files and the unknown keys are reported
+func TestMalformed(t *testing.T) {
+	x := newTestIndex()
+	malformed := x.Malformed()
+	for _, path := range []string{"broken/OWNERS", "regexp/OWNERS"} {
+		if _, ok := malformed[path]; !ok {
+			t.Errorf("%s is not reported, malformed: %v", path, malformed)
+		}
+	}
+	if len(malformed) != 2 {
+		t.Errorf("Malformed() = %v, want 2 files", malformed)
+	}
+
+	// the unknown keys are warnings and the file is used without them
+	warnings := x.Warnings()
+	if _, ok := warnings["unknown/OWNERS"]; !ok || len(warnings) != 1 {
+		t.Errorf("Warnings() = %v, want unknown/OWNERS", warnings)
+	}
+	x.Add("unknown/OWNERS", []byte("approvers: [frank]"))
+	if len(x.Warnings()) != 0 {
+		t.Errorf("the fixed file has warnings: %v", x.Warnings())
+	}
+
+	// a fixed file is not reported anymore
+	x.Add("broken/OWNERS", []byte("approvers: [alice]"))
+	if _, ok := x.Malformed()["broken/OWNERS"]; ok {
+		t.Errorf("the fixed file is reported")
+	}
+
+	x.SetAliases([]byte("aliases: [alice]"))
+	if _, ok := x.Malformed()[OwnersAliasesFileName]; !ok {
+		t.Errorf("the malformed aliases file is not reported")
+	}
+}
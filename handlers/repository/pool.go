@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// NewPool returns a pool of repositories
+func NewPool(client *github.Client, configAgent *config.Agent) *Pool {
+	return &Pool{
+		GithubClient: client,
+		ConfigAgent:  configAgent,
+		entries:      make(map[string]*poolEntry),
+	}
+}
+
+// Pool keeps a repository instance per org/repo. Each repository has its own mirror and owners cache.
+// The owners_backend of the repository is read when it is created.
+type Pool struct {
+	GithubClient *github.Client
+	ConfigAgent  *config.Agent
+
+	lock    sync.Mutex
+	entries map[string]*poolEntry
+}
+
+// poolEntry initializes a repository only once
+type poolEntry struct {
+	once       sync.Once
+	repository Interface
+	err        error
+}
+
+// Get returns the repository of org/repo. It is created and initialized on first use.
+func (p *Pool) Get(org string, repo string) (Interface, error) {
+	// e.g. test/hello
+	name := fmt.Sprintf("%s/%s", org, repo)
+
+	p.lock.Lock()
+	e, ok := p.entries[name]
+	if !ok {
+		e = &poolEntry{}
+		p.entries[name] = e
+	}
+	p.lock.Unlock()
+
+	// init the repository without blocking the other repositories
+	e.once.Do(func() {
+		glog.Infof("Add repository %s into pool", name)
+		r, err := p.newRepository(org, repo)
+		if err != nil {
+			glog.Errorf("Failed to new repository %s: %v", name, err)
+			e.err = err
+			return
+		}
+		err = r.Init()
+		if err != nil {
+			glog.Errorf("Failed to init repository %s: %v", name, err)
+			e.err = err
+			return
+		}
+		e.repository = r
+	})
+
+	if e.err != nil {
+		// remove the failed entry so that it can be retried by the next event
+		p.lock.Lock()
+		if p.entries[name] == e {
+			delete(p.entries, name)
+		}
+		p.lock.Unlock()
+		return nil, e.err
+	}
+	return e.repository, nil
+}
+
+// newRepository returns the repository of org/repo with its owners backend
+func (p *Pool) newRepository(org string, repo string) (Interface, error) {
+	name := fmt.Sprintf("%s/%s", org, repo)
+	backend := config.OwnersBackendGit
+	if p.ConfigAgent != nil {
+		backend = p.ConfigAgent.Config().RepoConfigFor(org, repo).OwnersBackend
+	}
+	if backend == config.OwnersBackendAPI {
+		return NewAPIRepository(p.GithubClient, name)
+	}
+	return NewRepository(p.GithubClient, name)
+}
+
+// Lookup returns the repository of org/repo if it is initialized, it does not create the repository
+func (p *Pool) Lookup(org string, repo string) (Interface, bool) {
+	p.lock.Lock()
+	e, ok := p.entries[fmt.Sprintf("%s/%s", org, repo)]
+	p.lock.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	// wait for the initialization
+	e.once.Do(func() {})
+	if e.err != nil || e.repository == nil {
+		return nil, false
+	}
+	return e.repository, true
+}
+
+// Clear clears all the repositories in the pool
+func (p *Pool) Clear() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for name, e := range p.entries {
+		if e.repository == nil {
+			continue
+		}
+		err := e.repository.Clear()
+		if err != nil {
+			glog.Errorf("Failed to clear repository %s: %v", name, err)
+		}
+	}
+	p.entries = make(map[string]*poolEntry)
+}
@@ -4,15 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
-
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -20,47 +16,40 @@ var (
 	OwnersFileName = "OWNERS"
 )
 
-// NewRepository returns an repository instance
-func NewRepository(client *github.Client, repository string) (*Repository, error) {
-	// e.g. https://github.com/test/hello
-	glog.Infof("New repository : %s", repository)
+// ParseRepository returns the org and repo of a repository address.
+// e.g. https://github.com/test/hello or test/hello
+func ParseRepository(repository string) (string, string, error) {
 	if repository == "" {
-		return nil, errors.New("Paramter repository is empty")
+		return "", "", errors.New("Paramter repository is empty")
 	}
 	// get org and repo
 	strs := strings.Split(strings.TrimPrefix(repository, GithubBaseURL), "/")
-	if len(strs) >= 2 {
-		// e.g. test
-		org := strs[0]
-		// e.g. hello
-		repo := strs[1]
-		glog.Infof("New repository org: %s repo: %s", org, repo)
-		return &Repository{
-			GithubClient: client,
-			Org:          org,
-			Repo:         repo,
-		}, nil
-	} else {
-		return nil, errors.New("Failed to get org and repo")
+	if len(strs) < 2 || strs[0] == "" || strs[1] == "" {
+		return "", "", errors.New("Failed to get org and repo")
 	}
+	// e.g. test, hello
+	return strs[0], strings.TrimSuffix(strs[1], ".git"), nil
 }
 
-// OwnersFile defines the content format of owners file
-type OwnersFile struct {
-	Approvers []string `yaml:"approvers,omitempty"`
-	Reviewers []string `yaml:"reviewers,omitempty"`
+// NewRepository returns an repository instance
+func NewRepository(client *github.Client, repository string) (*Repository, error) {
+	// e.g. https://github.com/test/hello
+	glog.Infof("New repository : %s", repository)
+	org, repo, err := ParseRepository(repository)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("New repository org: %s repo: %s", org, repo)
+	return &Repository{
+		GithubClient: client,
+		Org:          org,
+		Repo:         repo,
+		snapshots:    newSnapshotCache(DefaultMaxSnapshots),
+	}, nil
 }
 
-// Interface defines for Owners
-type Interface interface {
-	// Init repository
-	Init() error
-	// Clear repository
-	Clear() error
-
-	// LoadOwners loads an owners list
-	LoadOwners(branch string) error
-
+// Owners defines the owners of the files of a branch
+type Owners interface {
 	// GetApproversFilePath returns the OWNERS file path that contains approvers
 	GetApproversFilePath(path string) string
 	// GetReviewersFilePath returns the OWNERS file path that contains reviewers
@@ -75,11 +64,37 @@ type Interface interface {
 	GetAllApprovers(path string) map[string]string
 	// GetAllReviewers returns all the reviewers including parent owners file
 	GetAllReviewers(path string) map[string]string
+
+	// GetLabels returns the labels which are added when the path is changed
+	GetLabels(path string) map[string]string
+	// GetRequiredReviewers returns all the required reviewers including parent owners file
+	GetRequiredReviewers(path string) map[string]string
+
+	// GetMalformedOwnersFiles returns the owners files which can not be parsed and their errors
+	GetMalformedOwnersFiles() map[string]string
+	// GetOwnersFileWarnings returns the owners files which have unknown keys and their errors
+	GetOwnersFileWarnings() map[string]string
+}
+
+// Interface defines for Owners
+type Interface interface {
+	// Init repository
+	Init() error
+	// Clear repository
+	Clear() error
+
+	// LoadOwners loads the owners of the branch. The owners are a snapshot which is not changed
+	// by the other loads, so it can be used by concurrent handlers.
+	LoadOwners(branch string) (Owners, error)
+	// Refresh rebuilds the owners of a cached branch which is pushed to the sha
+	Refresh(branch string, sha string) error
 }
 
 var _ Interface = &Repository{}
+var _ Owners = &Snapshot{}
 
-// Repository cache
+// Repository cache. The owners files of the recently used branches are cached by their sha.
+// It is safe for concurrent use: the snapshots are never changed after they are built.
 type Repository struct {
 	GithubClient *github.Client
 	GitClient    *GitClient
@@ -87,11 +102,9 @@ type Repository struct {
 	Org  string
 	Repo string
 
-	Sha     string
-	RepoDir string
-
-	Approvers map[string]map[string]string
-	Reviewers map[string]map[string]string
+	// buildLock serializes the fetches of the mirror and the builds of the snapshots
+	buildLock sync.Mutex
+	snapshots *snapshotCache
 }
 
 // Init repository
@@ -119,6 +132,9 @@ func (o *Repository) Init() error {
 // Clear repository
 func (o *Repository) Clear() error {
 	glog.Info("Clear repository started.")
+	// wait for the running builds
+	o.buildLock.Lock()
+	defer o.buildLock.Unlock()
 	// clear mirror
 	if o.GitClient != nil {
 		err := o.GitClient.RemoveMirror()
@@ -130,20 +146,34 @@ func (o *Repository) Clear() error {
 	return nil
 }
 
-// LoadOwners loads an owners list
-func (o *Repository) LoadOwners(branch string) error {
+// LoadOwners loads the owners of the branch
+func (o *Repository) LoadOwners(branch string) (Owners, error) {
 	// e.g. org=test repo=hello branch=master
 	glog.Infof("Load owners started. org: %s repo: %s branch: %s", o.Org, o.Repo, branch)
 
+	sha, err := headSha(o.GithubClient, o.Org, o.Repo, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := o.snapshot(branch, sha)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// headSha returns the sha of the head of the branch
+func headSha(client *github.Client, org string, repo string, branch string) (string, error) {
 	// get ref of the repository
-	ref, _, err := o.GithubClient.Git.GetRef(
+	ref, _, err := client.Git.GetRef(
 		context.Background(),
-		o.Org,
-		o.Repo,
+		org,
+		repo,
 		fmt.Sprintf("heads/%s", branch))
 	if err != nil {
 		glog.Errorf("Failed to get ref: %v", err)
-		return err
+		return "", err
 	}
 
 	// get sha of the ref
@@ -153,219 +183,139 @@ func (o *Repository) LoadOwners(branch string) error {
 			sha = *ref.Object.SHA
 		}
 	}
-	glog.Infof("Repository sha: %s sha: %s", o.Sha, sha)
+	return sha, nil
+}
 
-	// repository is not changed yet
-	if o.Sha != "" && o.Sha == sha {
-		glog.Info("Repository is not changed yet")
+// Refresh rebuilds the owners of a cached branch which is pushed to the sha, e.g. by a push event,
+// so that the next LoadOwners does not wait for the build. The branches which are not cached are skipped.
+func (o *Repository) Refresh(branch string, sha string) error {
+	if !o.snapshots.contains(branch) {
 		return nil
 	}
+	glog.Infof("Refresh owners. org: %s repo: %s branch: %s sha: %s", o.Org, o.Repo, branch, sha)
+	_, err := o.snapshot(branch, sha)
+	return err
+}
 
-	// clone repository
-	err = o.GitClient.CloneRepo()
-	if err != nil {
-		glog.Errorf("Failed to clone repository: %v", err)
-		return err
+// snapshot returns the snapshot of the branch at the sha. It is built from the cached snapshot
+// of the branch if the owners files are changed, otherwise the cached snapshot is reused.
+func (o *Repository) snapshot(branch string, sha string) (*Snapshot, error) {
+	// repository is not changed yet
+	if s := o.snapshots.get(branch); s != nil && s.Sha == sha {
+		return s, nil
 	}
-	defer o.GitClient.RemoveRepo()
 
-	// diff repository
-	if o.Sha != "" {
-		// get changes between different sha
-		changes, err := o.GitClient.Diff(sha, o.Sha)
-		if err != nil {
-			glog.Errorf("Failed to diff %s with %s", sha, o.Sha)
-			return err
-		}
-		// check if owners files are changed
-		ownersChanged := false
-		for _, change := range changes {
-			if strings.HasSuffix(change, OwnersFileName) {
-				ownersChanged = true
-				break
-			}
-		}
-		// Owners files are not changed
-		if !ownersChanged {
-			glog.Info("Owners files are not changed.")
-			return nil
-		}
+	o.buildLock.Lock()
+	defer o.buildLock.Unlock()
+	// the snapshot may be built while waiting for the lock
+	prev := o.snapshots.get(branch)
+	if prev != nil && prev.Sha == sha {
+		return prev, nil
 	}
 
-	// checkout branch
-	err = o.GitClient.CheckOut(branch, o.GitClient.LocalRepoDir)
+	// fetch mirror
+	err := o.GitClient.CloneMirror()
 	if err != nil {
-		glog.Errorf("Failed to checkout %s", branch)
-		return nil
-	}
-
-	// Walk
-	o.RepoDir = o.GitClient.LocalRepoDir
-	o.Sha = sha
-	o.Approvers = nil
-	o.Reviewers = nil
-	return filepath.Walk(o.RepoDir, o.walkFunc)
-}
-
-// GetApproversFilePath returns the OWNERS file path that contains approvers
-func (o *Repository) GetApproversFilePath(path string) string {
-	return o.getOwnersFilePath(path, o.Approvers)
-}
-
-// GetReviewersFilePath returns the OWNERS file path that contains reviewers
-func (o *Repository) GetReviewersFilePath(path string) string {
-	return o.getOwnersFilePath(path, o.Reviewers)
-}
-
-// GetClosestApprovers returns a set of users who are the closest approvers
-func (o *Repository) GetClosestApprovers(path string) map[string]string {
-	return o.getOwners(path, o.Approvers, true)
-}
-
-// GetClosestReviewers returns a set of users who are the closest reviewers
-func (o *Repository) GetClosestReviewers(path string) map[string]string {
-	return o.getOwners(path, o.Reviewers, true)
-}
-
-// GetAllApprovers returns all the approvers including parent owners file
-func (o *Repository) GetAllApprovers(path string) map[string]string {
-	return o.getOwners(path, o.Approvers, false)
-}
-
-// GetAllReviewers returns all the reviewers including parent owners file
-func (o *Repository) GetAllReviewers(path string) map[string]string {
-	return o.getOwners(path, o.Reviewers, false)
-}
-
-// filterFilePath format the file path
-func (o *Repository) filterFilePath(path string) string {
-	if path == "." {
-		return ""
-	} else {
-		return strings.TrimSuffix(path, "/")
+		glog.Errorf("Failed to clone mirror: %v", err)
+		return nil, err
 	}
-}
 
-// walkFunc reads the owners file and loads them into approvers or reviewers
-func (o *Repository) walkFunc(path string, info os.FileInfo, err error) error {
-	// skip
-	if err != nil {
-		glog.Error("Error while walking OWNERS files")
-		return nil
+	var owners *OwnersIndex
+	if prev != nil {
+		owners, err = o.updateOwners(prev, sha)
+		if err != nil {
+			glog.Errorf("Failed to update owners from %s to %s, rebuild them: %v", prev.Sha, sha, err)
+		}
 	}
-	if info == nil {
-		glog.Error("FileInfo is nil")
-		return nil
+	if owners == nil {
+		owners, err = o.buildOwners(sha)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// check if it is OWNERS file
-	filename := filepath.Base(path)
-	if !info.Mode().IsRegular() {
-		return nil
-	}
-	if filename != OwnersFileName {
-		return nil
-	}
+	s := &Snapshot{Branch: branch, Sha: sha, Owners: owners}
+	o.snapshots.add(s)
+	return s, nil
+}
 
-	// read content from OWNERS file
-	glog.Infof("WalkFunc path: %s", path)
-	b, err := ioutil.ReadFile(path)
+// buildOwners reads all the owners files of the commit
+func (o *Repository) buildOwners(sha string) (*OwnersIndex, error) {
+	glog.Infof("Build owners. org: %s repo: %s sha: %s", o.Org, o.Repo, sha)
+	files, err := o.GitClient.ListFiles(sha)
 	if err != nil {
-		glog.Errorf("Failed to read the OWNERS file: %s", path)
-		return nil
+		return nil, err
 	}
-
-	relPath, err := filepath.Rel(o.RepoDir, path)
+	owners := NewOwnersIndex()
+	err = o.addOwnersFiles(owners, sha, files)
 	if err != nil {
-		glog.Errorf("Unable to find relative path between repodir: %s and path: %s", o.RepoDir, path)
-		return err
+		return nil, err
 	}
-	glog.Infof("WalkFunc relPath: %s", relPath)
-	relPathDir := o.filterFilePath(filepath.Dir(relPath))
-	glog.Infof("WalkFunc relPathDir: %s", relPathDir)
+	return owners, nil
+}
 
-	// unmarshal
-	ownersfile := OwnersFile{}
-	err = yaml.Unmarshal(b, &ownersfile)
+// updateOwners reads only the owners files which are changed since the snapshot.
+// The owners are rebuilt if the aliases are changed because they are used by all the owners files.
+func (o *Repository) updateOwners(prev *Snapshot, sha string) (*OwnersIndex, error) {
+	// get changes between different sha
+	changes, err := o.GitClient.Diff(prev.Sha, sha)
 	if err != nil {
-		glog.Errorf("Failed to unmarshal %s", path)
-		return err
+		glog.Errorf("Failed to diff %s with %s", prev.Sha, sha)
+		return nil, err
 	}
-
-	if len(ownersfile.Approvers) > 0 {
-		if o.Approvers == nil {
-			o.Approvers = make(map[string]map[string]string)
+	changed := make([]string, 0)
+	for _, change := range changes {
+		if change == OwnersAliasesFileName {
+			return o.buildOwners(sha)
 		}
-		if o.Approvers[relPathDir] == nil {
-			o.Approvers[relPathDir] = make(map[string]string)
-		}
-		for _, v := range ownersfile.Approvers {
-			o.Approvers[relPathDir][v] = v
+		if IsOwnersFile(change) {
+			changed = append(changed, change)
 		}
 	}
-	if len(ownersfile.Reviewers) > 0 {
-		if o.Reviewers == nil {
-			o.Reviewers = make(map[string]map[string]string)
-		}
-		if o.Reviewers[relPathDir] == nil {
-			o.Reviewers[relPathDir] = make(map[string]string)
-		}
-		for _, v := range ownersfile.Reviewers {
-			o.Reviewers[relPathDir][v] = v
-		}
+	// Owners files are not changed
+	if len(changed) == 0 {
+		glog.Info("Owners files are not changed.")
+		return prev.Owners, nil
 	}
 
-	return nil
+	glog.Infof("Update owners files: %v", changed)
+	existing, err := o.GitClient.ListFiles(sha, changed...)
+	if err != nil {
+		return nil, err
+	}
+	owners := prev.Owners.Copy()
+	for _, change := range changed {
+		owners.Remove(change)
+	}
+	err = o.addOwnersFiles(owners, sha, existing)
+	if err != nil {
+		return nil, err
+	}
+	return owners, nil
 }
 
-// getOwnersFilePath returns the closest owners file path
-func (o *Repository) getOwnersFilePath(path string, maps map[string]map[string]string) string {
-	d := path
-	if maps != nil {
-		for {
-			glog.Infof("Get owners file path: %s", d)
-			// check if the directory is in maps
-			if o, ok := maps[d]; ok {
-				// the owners file contains approvers or reviewers
-				if len(o) > 0 {
-					return d
-				}
-			}
-			// break if the path is root
-			if d == "" {
-				break
+// addOwnersFiles reads the owners files in the files of the commit into the index
+func (o *Repository) addOwnersFiles(owners *OwnersIndex, sha string, files []string) error {
+	// the aliases are used by the owners files
+	for _, f := range files {
+		if f == OwnersAliasesFileName {
+			b, err := o.GitClient.ShowFile(sha, f)
+			if err != nil {
+				return err
 			}
-			// get the directory of file or directory path
-			d = o.filterFilePath(filepath.Dir(d))
+			owners.SetAliases(b)
 		}
 	}
-	return ""
-}
-
-// getOwners returns a set of users for the requested file.
-func (o *Repository) getOwners(path string, maps map[string]map[string]string, closest bool) map[string]string {
-	d := path
-	out := make(map[string]string)
-	if maps != nil {
-		for {
-			glog.Infof("Get owners: %s closest: %v", d, closest)
-			// check if the directory is in maps
-			if o, ok := maps[d]; ok {
-				for k, v := range o {
-					out[k] = v
-				}
-			}
-			// the closest owners are gotten
-			if closest && len(out) > 0 {
-				break
-			}
-			// break if the path is root
-			if d == "" {
-				break
-			}
-			// get the directory of file or directory path
-			d = o.filterFilePath(filepath.Dir(d))
+	for _, f := range files {
+		if f == OwnersAliasesFileName || !IsOwnersFile(f) {
+			continue
+		}
+		b, err := o.GitClient.ShowFile(sha, f)
+		if err != nil {
+			return err
 		}
+		// the malformed owners files are reported by GetMalformedOwnersFiles
+		owners.Add(f, b)
 	}
-	return out
+	return nil
 }
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// testUpstream is a local git repository which is mirrored by the tests
+type testUpstream struct {
+	t   *testing.T
+	dir string
+}
+
+// newTestUpstream creates the upstream repository test/hello in a tmp dir
+func newTestUpstream(t *testing.T) *testUpstream {
+	base, err := ioutil.TempDir("", "upstream")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	u := &testUpstream{t: t, dir: filepath.Join(base, "test", "hello")}
+	if err := os.MkdirAll(u.dir, os.ModePerm); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	u.git("init", "-q", "-b", "master")
+	u.git("config", "user.email", "test@example.com")
+	u.git("config", "user.name", "test")
+	return u
+}
+
+// git runs a git command in the upstream repository
+func (u *testUpstream) git(args ...string) string {
+	c := exec.Command("git", args...)
+	c.Dir = u.dir
+	bs, err := c.CombinedOutput()
+	if err != nil {
+		u.t.Fatalf("git %v error = %v: %s", args, err, bs)
+	}
+	return strings.TrimSpace(string(bs))
+}
+
+// commit writes the files, removes the files whose content is empty, and returns the sha of the commit
+func (u *testUpstream) commit(files map[string]string) string {
+	for path, content := range files {
+		full := filepath.Join(u.dir, path)
+		if content == "" {
+			u.git("rm", "-q", path)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), os.ModePerm); err != nil {
+			u.t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := ioutil.WriteFile(full, []byte(content), 0644); err != nil {
+			u.t.Fatalf("WriteFile() error = %v", err)
+		}
+		u.git("add", path)
+	}
+	u.git("commit", "-q", "-m", "update")
+	return u.git("rev-parse", "HEAD")
+}
+
+// newTestRepository returns the repository which mirrors the upstream
+func newTestRepository(t *testing.T, u *testUpstream, maxSnapshots int) *Repository {
+	r, err := NewRepository(nil, "test/hello")
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	r.snapshots = newSnapshotCache(maxSnapshots)
+	mirror, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	r.GitClient = &GitClient{
+		LocalMirrorDir: mirror,
+		BaseURL:        filepath.Dir(filepath.Dir(u.dir)) + "/",
+		Repo:           "test/hello",
+	}
+	return r
+}
+
+//TestSnapshot tests that the owners are rebuilt only when the owners files are changed
+func TestSnapshot(t *testing.T) {
+	u := newTestUpstream(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(u.dir)))
+	r := newTestRepository(t, u, 0)
+	defer r.Clear()
+
+	sha1 := u.commit(map[string]string{
+		"OWNERS":         "approvers: [root-team]",
+		"OWNERS_ALIASES": "aliases:\n  root-team: [alice]",
+		"pkg/OWNERS":     "approvers: [bob]",
+		"pkg/main.go":    "package main",
+	})
+	s1, err := r.snapshot("master", sha1)
+	if err != nil {
+		t.Fatalf("snapshot() error = %v", err)
+	}
+	if got := s1.Owners.Owners("pkg/main.go", kindApprovers, false); !reflect.DeepEqual(got, users("alice", "bob")) {
+		t.Errorf("approvers = %v, want alice and bob", got)
+	}
+
+	// only the changed owners file is read again
+	sha2 := u.commit(map[string]string{"pkg/OWNERS": "approvers: [carol]"})
+	s2, err := r.snapshot("master", sha2)
+	if err != nil {
+		t.Fatalf("snapshot() error = %v", err)
+	}
+	if got := s2.Owners.Owners("pkg/main.go", kindApprovers, true); !reflect.DeepEqual(got, users("carol")) {
+		t.Errorf("approvers = %v, want carol", got)
+	}
+	if got := s1.Owners.Owners("pkg/main.go", kindApprovers, true); !reflect.DeepEqual(got, users("bob")) {
+		t.Errorf("the previous snapshot is changed, approvers = %v", got)
+	}
+
+	// the owners are reused if no owners file is changed
+	sha3 := u.commit(map[string]string{"pkg/main.go": "package main\n"})
+	s3, err := r.snapshot("master", sha3)
+	if err != nil {
+		t.Fatalf("snapshot() error = %v", err)
+	}
+	if s3.Owners != s2.Owners {
+		t.Errorf("the owners are rebuilt without changes")
+	}
+
+	// the deleted owners file and the changed aliases
+	sha4 := u.commit(map[string]string{"pkg/OWNERS": "", "OWNERS_ALIASES": "aliases:\n  root-team: [dave]"})
+	s4, err := r.snapshot("master", sha4)
+	if err != nil {
+		t.Fatalf("snapshot() error = %v", err)
+	}
+	if got := s4.Owners.Owners("pkg/main.go", kindApprovers, false); !reflect.DeepEqual(got, users("dave")) {
+		t.Errorf("approvers = %v, want dave", got)
+	}
+}
+
+//TestSnapshotCache tests that the least recently used branches are evicted
+func TestSnapshotCache(t *testing.T) {
+	u := newTestUpstream(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(u.dir)))
+	r := newTestRepository(t, u, 2)
+	defer r.Clear()
+
+	sha := u.commit(map[string]string{"OWNERS": "approvers: [alice]"})
+	for _, branch := range []string{"master", "release-1.0", "master", "release-1.1"} {
+		if _, err := r.snapshot(branch, sha); err != nil {
+			t.Fatalf("snapshot() error = %v", err)
+		}
+	}
+	for branch, want := range map[string]bool{"master": true, "release-1.0": false, "release-1.1": true} {
+		if got := r.snapshots.contains(branch); got != want {
+			t.Errorf("contains(%s) = %v, want %v", branch, got, want)
+		}
+	}
+
+	// only the cached branches are refreshed
+	sha2 := u.commit(map[string]string{"OWNERS": "approvers: [bob]"})
+	if err := r.Refresh("release-1.0", sha2); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if r.snapshots.contains("release-1.0") {
+		t.Errorf("the branch which is not cached is refreshed")
+	}
+	if err := r.Refresh("master", sha2); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if s := r.snapshots.get("master"); s.Sha != sha2 {
+		t.Errorf("master is at %s after refresh, want %s", s.Sha, sha2)
+	}
+}
+
+// fakeRefs serves the refs of the branches, the ref of a branch rotates between its shas
+type fakeRefs struct {
+	shas  map[string][]string
+	count int64
+}
+
+func (f *fakeRefs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	branch := strings.TrimPrefix(r.URL.Path, "/repos/test/hello/git/refs/heads/")
+	shas, ok := f.shas[branch]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	sha := shas[int(atomic.AddInt64(&f.count, 1))%len(shas)]
+	fmt.Fprintf(w, `{"ref": "refs/heads/%s", "object": {"sha": "%s"}}`, branch, sha)
+}
+
+//TestConcurrentLoadOwners tests that the concurrent handlers see the owners of their own branches, run it with -race
+func TestConcurrentLoadOwners(t *testing.T) {
+	u := newTestUpstream(t)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(u.dir)))
+
+	// the owners of master are not changed by its commits
+	master1 := u.commit(map[string]string{"OWNERS": "approvers: [alice]", "pkg/OWNERS": "reviewers: [carol]", "main.go": "package main"})
+	master2 := u.commit(map[string]string{"main.go": "package main\n"})
+	u.git("checkout", "-q", "-b", "release-1.0")
+	release1 := u.commit(map[string]string{"OWNERS": "approvers: [bob]"})
+	release2 := u.commit(map[string]string{"pkg/OWNERS": "reviewers: [dave]"})
+
+	server := httptest.NewServer(&fakeRefs{shas: map[string][]string{
+		"master":      {master1, master2},
+		"release-1.0": {release1, release2},
+	}})
+	defer server.Close()
+	r := newTestRepository(t, u, 1)
+	defer r.Clear()
+	r.GithubClient = github.NewClient(nil)
+	r.GithubClient.BaseURL, _ = url.Parse(server.URL + "/")
+
+	want := map[string]map[string]string{"master": users("alice"), "release-1.0": users("bob")}
+	pushed := map[string]string{"master": master2, "release-1.0": release2}
+	errs := make(chan error, 100)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			branch := "master"
+			if i%2 == 1 {
+				branch = "release-1.0"
+			}
+			for j := 0; j < 5; j++ {
+				owners, err := r.LoadOwners(branch)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if got := owners.GetAllApprovers("pkg/main.go"); !reflect.DeepEqual(got, want[branch]) {
+					errs <- fmt.Errorf("approvers of %s = %v, want %v", branch, got, want[branch])
+					return
+				}
+				if got := owners.GetClosestReviewers("pkg/main.go"); len(got) != 1 {
+					errs <- fmt.Errorf("reviewers of %s = %v, want one reviewer", branch, got)
+					return
+				}
+				// the pushes refresh the cached branches while the others are loaded
+				if err := r.Refresh(branch, pushed[branch]); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMaxSnapshots is the default number of the branches whose owners are cached
+const DefaultMaxSnapshots = 8
+
+// Snapshot contains the owners files of a branch at a commit. It is not changed after it is built.
+type Snapshot struct {
+	Branch string
+	Sha    string
+	Owners *OwnersIndex
+
+	// blobs are the blob shas of the owners files by their paths, they are kept by APIRepository
+	blobs map[string]string
+}
+
+// GetApproversFilePath returns the OWNERS file path that contains approvers
+func (s *Snapshot) GetApproversFilePath(path string) string {
+	return s.Owners.OwnersFilePath(path, kindApprovers)
+}
+
+// GetReviewersFilePath returns the OWNERS file path that contains reviewers
+func (s *Snapshot) GetReviewersFilePath(path string) string {
+	return s.Owners.OwnersFilePath(path, kindReviewers)
+}
+
+// GetClosestApprovers returns a set of users who are the closest approvers
+func (s *Snapshot) GetClosestApprovers(path string) map[string]string {
+	return s.Owners.Owners(path, kindApprovers, true)
+}
+
+// GetClosestReviewers returns a set of users who are the closest reviewers
+func (s *Snapshot) GetClosestReviewers(path string) map[string]string {
+	return s.Owners.Owners(path, kindReviewers, true)
+}
+
+// GetAllApprovers returns all the approvers including parent owners file
+func (s *Snapshot) GetAllApprovers(path string) map[string]string {
+	return s.Owners.Owners(path, kindApprovers, false)
+}
+
+// GetAllReviewers returns all the reviewers including parent owners file
+func (s *Snapshot) GetAllReviewers(path string) map[string]string {
+	return s.Owners.Owners(path, kindReviewers, false)
+}
+
+// GetLabels returns the labels which are added when the path is changed
+func (s *Snapshot) GetLabels(path string) map[string]string {
+	return s.Owners.Owners(path, kindLabels, false)
+}
+
+// GetRequiredReviewers returns all the required reviewers including parent owners file
+func (s *Snapshot) GetRequiredReviewers(path string) map[string]string {
+	return s.Owners.Owners(path, kindRequiredReviewers, false)
+}
+
+// GetMalformedOwnersFiles returns the owners files which can not be parsed and their errors
+func (s *Snapshot) GetMalformedOwnersFiles() map[string]string {
+	return s.Owners.Malformed()
+}
+
+// GetOwnersFileWarnings returns the owners files which have unknown keys and their errors
+func (s *Snapshot) GetOwnersFileWarnings() map[string]string {
+	return s.Owners.Warnings()
+}
+
+// snapshotCache keeps the snapshots of the recently used branches
+type snapshotCache struct {
+	lock sync.Mutex
+	max  int
+	// order contains the branches, the most recently used one is at the front
+	order *list.List
+	items map[string]*list.Element
+}
+
+// newSnapshotCache returns a cache which keeps at most max snapshots
+func newSnapshotCache(max int) *snapshotCache {
+	if max <= 0 {
+		max = DefaultMaxSnapshots
+	}
+	return &snapshotCache{
+		max:   max,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the snapshot of the branch and marks it as recently used
+func (c *snapshotCache) get(branch string) *Snapshot {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.items[branch]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*Snapshot)
+}
+
+// add adds or replaces the snapshot of its branch, the least recently used snapshot is evicted if it is full
+func (c *snapshotCache) add(s *Snapshot) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e, ok := c.items[s.Branch]; ok {
+		e.Value = s
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[s.Branch] = c.order.PushFront(s)
+	for c.order.Len() > c.max {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*Snapshot).Branch)
+	}
+}
+
+// contains checks if the branch is cached without marking it as recently used
+func (c *snapshotCache) contains(branch string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.items[branch]
+	return ok
+}
@@ -0,0 +1,152 @@
+package response
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+// maxQuoteLength limits the length of the quoted command
+const maxQuoteLength = 1000
+
+// Target is the comment which is answered
+type Target struct {
+	Org    string
+	Repo   string
+	Number int
+	// User who commented the command
+	User string
+	// Comment contains the command. e.g. /lgtm
+	Comment string
+	// CommentURL links to the comment
+	CommentURL string
+}
+
+// Options defines how many responses are posted
+type Options struct {
+	// Window drops the same response to the same user on the same issue within the window
+	Window time.Duration
+	// Limit is the maximum number of responses on an issue within the period
+	Limit  int
+	Period time.Duration
+}
+
+// DefaultOptions are the options which are used by ci-bot
+var DefaultOptions = Options{
+	Window: time.Hour,
+	Limit:  5,
+	Period: 10 * time.Minute,
+}
+
+// Format returns the response which mentions the user and quotes the command
+func Format(t Target, message string) string {
+	quote := strings.TrimSpace(t.Comment)
+	if len(quote) > maxQuoteLength {
+		quote = quote[:maxQuoteLength] + "..."
+	}
+	quote = "> " + strings.Replace(quote, "\n", "\n> ", -1)
+	source := "In response to this:"
+	if t.CommentURL != "" {
+		source = fmt.Sprintf("In response to [this](%s):", t.CommentURL)
+	}
+	return fmt.Sprintf("@%s: %s\n\n<details>\n\n%s\n\n%s\n</details>", t.User, message, source, quote)
+}
+
+// NewResponder returns a responder which posts the responses with the github client
+func NewResponder(client *github.Client, options Options) *Responder {
+	return &Responder{
+		GithubClient: client,
+		Options:      options,
+		sent:         make(map[string]time.Time),
+		history:      make(map[string][]time.Time),
+		now:          time.Now,
+	}
+}
+
+// Responder posts the responses to the commands as comments. The same response is posted once within
+// the window, e.g. when a webhook event is retried, and the responses on an issue are rate limited.
+type Responder struct {
+	GithubClient *github.Client
+	Options      Options
+
+	lock sync.Mutex
+	// sent records when a response was posted
+	sent map[string]time.Time
+	// history records the responses on an issue within the period
+	history map[string][]time.Time
+	now     func() time.Time
+}
+
+// Respond posts the message as a comment which answers the target
+func (r *Responder) Respond(t Target, message string) error {
+	issue := fmt.Sprintf("%s/%s#%d", t.Org, t.Repo, t.Number)
+	if !r.allow(issue, fmt.Sprintf("%s@%s:%s", issue, t.User, message)) {
+		return nil
+	}
+
+	glog.Infof("Respond to %s on %s: %s", t.User, issue, message)
+	comment := &github.IssueComment{Body: github.String(Format(t, message))}
+	_, _, err := r.GithubClient.Issues.CreateComment(context.Background(), t.Org, t.Repo, t.Number, comment)
+	if err != nil {
+		glog.Errorf("Unable to respond on %s: %v", issue, err)
+		r.forget(issue, fmt.Sprintf("%s@%s:%s", issue, t.User, message))
+		return err
+	}
+	return nil
+}
+
+// allow checks and records if the response can be posted
+func (r *Responder) allow(issue string, key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	r.prune(now)
+	if _, ok := r.sent[key]; ok {
+		glog.Infof("Drop duplicate response: %s", key)
+		return false
+	}
+	if r.Options.Limit > 0 && len(r.history[issue]) >= r.Options.Limit {
+		glog.Infof("Drop response on %s, %d responses are posted within %v", issue, len(r.history[issue]), r.Options.Period)
+		return false
+	}
+	r.sent[key] = now
+	r.history[issue] = append(r.history[issue], now)
+	return true
+}
+
+// forget removes the record of a response which failed to post so that it can be retried
+func (r *Responder) forget(issue string, key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.sent, key)
+	if h := r.history[issue]; len(h) > 0 {
+		r.history[issue] = h[:len(h)-1]
+	}
+}
+
+// prune removes the records which are out of the window and the period
+func (r *Responder) prune(now time.Time) {
+	for key, t := range r.sent {
+		if now.Sub(t) >= r.Options.Window {
+			delete(r.sent, key)
+		}
+	}
+	for issue, h := range r.history {
+		i := 0
+		for i < len(h) && now.Sub(h[i]) >= r.Options.Period {
+			i++
+		}
+		if i == len(h) {
+			delete(r.history, issue)
+		} else {
+			r.history[issue] = h[i:]
+		}
+	}
+}
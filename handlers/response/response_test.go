@@ -0,0 +1,128 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeGithub counts the comments which are posted
+type fakeGithub struct {
+	lock     sync.Mutex
+	comments int
+	fail     bool
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	f.comments++
+	w.Write([]byte(`{}`))
+}
+
+// newTestResponder returns a responder whose clock is controlled by the test
+func newTestResponder(server *httptest.Server, options Options, now *time.Time) *Responder {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	r := NewResponder(client, options)
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+var testTarget = Target{Org: "test", Repo: "hello", Number: 1, User: "alice", Comment: "/lgtm", CommentURL: "https://github.com/test/hello/pull/1#issuecomment-1"}
+
+//TestFormat tests that the response mentions the user and quotes the command
+func TestFormat(t *testing.T) {
+	body := Format(testTarget, "you cannot LGTM your own PR.")
+	for _, want := range []string{"@alice: you cannot LGTM your own PR.", "In response to [this](" + testTarget.CommentURL + "):", "> /lgtm"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Format() = %q, want it to contain %q", body, want)
+		}
+	}
+
+	long := testTarget
+	long.Comment = "/lgtm\n" + strings.Repeat("x", 2*maxQuoteLength)
+	if body := Format(long, "message"); len(body) > 2*maxQuoteLength || !strings.Contains(body, "> /lgtm\n> xxx") {
+		t.Errorf("Format() does not truncate the quote: %d", len(body))
+	}
+}
+
+//TestRespondDuplicate tests that the same response is posted once within the window
+func TestRespondDuplicate(t *testing.T) {
+	f := &fakeGithub{}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	now := time.Now()
+	r := newTestResponder(server, Options{Window: time.Hour}, &now)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Respond(testTarget, "message"); err != nil {
+			t.Fatalf("Respond() error = %v", err)
+		}
+	}
+	r.Respond(testTarget, "another message")
+	if f.comments != 2 {
+		t.Errorf("comments = %d, want 2", f.comments)
+	}
+
+	now = now.Add(time.Hour)
+	r.Respond(testTarget, "message")
+	if f.comments != 3 {
+		t.Errorf("comments = %d after the window, want 3", f.comments)
+	}
+}
+
+//TestRespondRateLimit tests that the responses on an issue are limited within the period
+func TestRespondRateLimit(t *testing.T) {
+	f := &fakeGithub{}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	now := time.Now()
+	r := newTestResponder(server, Options{Window: time.Hour, Limit: 2, Period: 10 * time.Minute}, &now)
+
+	for _, message := range []string{"a", "b", "c"} {
+		r.Respond(testTarget, message)
+	}
+	other := testTarget
+	other.Number = 2
+	r.Respond(other, "a")
+	if f.comments != 3 {
+		t.Errorf("comments = %d, want 3", f.comments)
+	}
+
+	now = now.Add(10 * time.Minute)
+	r.Respond(testTarget, "c")
+	if f.comments != 4 {
+		t.Errorf("comments = %d after the period, want 4", f.comments)
+	}
+}
+
+//TestRespondFailure tests that a response which failed to post can be retried
+func TestRespondFailure(t *testing.T) {
+	f := &fakeGithub{fail: true}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	now := time.Now()
+	r := newTestResponder(server, DefaultOptions, &now)
+
+	if err := r.Respond(testTarget, "message"); err == nil {
+		t.Fatalf("Respond() error = nil, want an error")
+	}
+	f.fail = false
+	if err := r.Respond(testTarget, "message"); err != nil {
+		t.Fatalf("Respond() error = %v", err)
+	}
+	if f.comments != 1 {
+		t.Errorf("comments = %d, want 1", f.comments)
+	}
+}
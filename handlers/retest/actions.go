@@ -0,0 +1,136 @@
+package retest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	. "github.com/huawei-cloudnative/ci-bot/handlers/types"
+)
+
+// GitHubActions runs the workflows of the pull requests. Each workflow is a job named by the workflow name.
+type GitHubActions struct {
+	GithubClient *github.Client
+}
+
+// Name of the provider
+func (a *GitHubActions) Name() string {
+	return config.CIProviderGitHubActions
+}
+
+// Jobs lists the latest run of each workflow on the head commit
+func (a *GitHubActions) Jobs(pr PullRequest) ([]Job, error) {
+	var runs ActionsRunsRespStruct
+	path := fmt.Sprintf("repos/%s/%s/actions/runs?event=pull_request&head_sha=%s", pr.Org, pr.Repo, pr.HeadSHA)
+	err := a.send(http.MethodGet, path, &runs)
+	if err != nil {
+		glog.Errorf("Failed to list workflow runs: %v", err)
+		return nil, err
+	}
+
+	// the runs are sorted by the newest first
+	jobs := make([]Job, 0)
+	seen := make(map[string]bool)
+	for _, r := range runs.WorkflowRuns {
+		if r.HeadSHA != pr.HeadSHA || seen[r.Name] {
+			continue
+		}
+		seen[r.Name] = true
+		jobs = append(jobs, Job{
+			Name:  r.Name,
+			ID:    strconv.FormatInt(r.ID, 10),
+			State: actionsState(r.Status, r.Conclusion),
+			URL:   r.HTMLURL,
+		})
+	}
+	return jobs, nil
+}
+
+// RetestAll reruns all the workflows
+func (a *GitHubActions) RetestAll(pr PullRequest) error {
+	jobs, err := a.Jobs(pr)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no workflow run of pr #%d", pr.Number)
+	}
+	for _, j := range jobs {
+		err = a.rerun(pr, j)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retest reruns the workflow of the name
+func (a *GitHubActions) Retest(pr PullRequest, name string) error {
+	jobs, err := a.Jobs(pr)
+	if err != nil {
+		return err
+	}
+	found, err := findJobs(jobs, name)
+	if err != nil {
+		return err
+	}
+	for _, job := range found {
+		err = a.rerun(pr, job)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status returns the combined state of the workflows
+func (a *GitHubActions) Status(pr PullRequest) (string, error) {
+	jobs, err := a.Jobs(pr)
+	if err != nil {
+		return "", err
+	}
+	return CombinedState(jobs), nil
+}
+
+// rerun reruns a workflow run
+func (a *GitHubActions) rerun(pr PullRequest, job Job) error {
+	path := fmt.Sprintf("repos/%s/%s/actions/runs/%s/rerun", pr.Org, pr.Repo, job.ID)
+	err := a.send(http.MethodPost, path, nil)
+	if err != nil {
+		glog.Errorf("Failed to rerun workflow %s: %v", job.Name, err)
+		return err
+	}
+	glog.Infof("Rerun workflow %s of pr #%d", job.Name, pr.Number)
+	return nil
+}
+
+// send sends a request with the github client. The actions APIs are not supported by the vendored go-github.
+func (a *GitHubActions) send(method string, path string, out interface{}) error {
+	req, err := a.GithubClient.NewRequest(method, path, nil)
+	if err != nil {
+		return err
+	}
+	_, err = a.GithubClient.Do(context.Background(), req, out)
+	if _, ok := err.(*github.AcceptedError); ok {
+		// the rerun is scheduled
+		return nil
+	}
+	return err
+}
+
+// actionsState converts the status and conclusion of a workflow run
+func actionsState(status string, conclusion string) string {
+	if status != "completed" {
+		return JobStatePending
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return JobStateSuccess
+	}
+	return JobStateFailure
+}
@@ -0,0 +1,130 @@
+package retest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/golang/glog"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	. "github.com/huawei-cloudnative/ci-bot/handlers/types"
+)
+
+// GitLab runs the jobs of the GitLab CI pipelines of the pull requests. e.g. CI/CD for external repositories.
+type GitLab struct {
+	Config config.GitLab
+}
+
+// Name of the provider
+func (g *GitLab) Name() string {
+	return config.CIProviderGitLab
+}
+
+// Jobs lists the jobs of the latest pipeline of the head commit
+func (g *GitLab) Jobs(pr PullRequest) ([]Job, error) {
+	pipeline, err := g.latestPipeline(pr)
+	if err != nil {
+		return nil, err
+	}
+	var list []GitLabJobStruct
+	err = g.send(http.MethodGet, fmt.Sprintf("/pipelines/%d/jobs?per_page=100", pipeline.ID), &list)
+	if err != nil {
+		glog.Errorf("Failed to list jobs of pipeline %d: %v", pipeline.ID, err)
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(list))
+	for _, j := range list {
+		jobs = append(jobs, Job{
+			Name:  j.Name,
+			Stage: j.Stage,
+			ID:    strconv.Itoa(j.ID),
+			State: gitlabState(j.Status),
+			URL:   j.WebURL,
+		})
+	}
+	return jobs, nil
+}
+
+// RetestAll retries the failed and canceled jobs of the latest pipeline
+func (g *GitLab) RetestAll(pr PullRequest) error {
+	pipeline, err := g.latestPipeline(pr)
+	if err != nil {
+		return err
+	}
+	err = g.send(http.MethodPost, fmt.Sprintf("/pipelines/%d/retry", pipeline.ID), nil)
+	if err != nil {
+		glog.Errorf("Failed to retry pipeline %d: %v", pipeline.ID, err)
+		return err
+	}
+	glog.Infof("Retry pipeline %d of pr #%d", pipeline.ID, pr.Number)
+	return nil
+}
+
+// Retest retries the jobs whose name or stage is the name
+func (g *GitLab) Retest(pr PullRequest, name string) error {
+	jobs, err := g.Jobs(pr)
+	if err != nil {
+		return err
+	}
+	found, err := findJobs(jobs, name)
+	if err != nil {
+		return err
+	}
+	for _, job := range found {
+		err = g.send(http.MethodPost, fmt.Sprintf("/jobs/%s/retry", job.ID), nil)
+		if err != nil {
+			glog.Errorf("Failed to retry job %s: %v", job.Name, err)
+			return err
+		}
+		glog.Infof("Retry job %s of pr #%d", job.Name, pr.Number)
+	}
+	return nil
+}
+
+// Status returns the combined state of the jobs
+func (g *GitLab) Status(pr PullRequest) (string, error) {
+	jobs, err := g.Jobs(pr)
+	if err != nil {
+		return "", err
+	}
+	return CombinedState(jobs), nil
+}
+
+// latestPipeline returns the latest pipeline of the head commit
+func (g *GitLab) latestPipeline(pr PullRequest) (GitLabPipelineStruct, error) {
+	var list []GitLabPipelineStruct
+	err := g.send(http.MethodGet, fmt.Sprintf("/pipelines?sha=%s&order_by=id&sort=desc", pr.HeadSHA), &list)
+	if err != nil {
+		glog.Errorf("Failed to list pipelines: %v", err)
+		return GitLabPipelineStruct{}, err
+	}
+	if len(list) == 0 {
+		return GitLabPipelineStruct{}, fmt.Errorf("no gitlab pipeline of pr #%d", pr.Number)
+	}
+	return list[0], nil
+}
+
+// send sends a GitLab API request of the project
+func (g *GitLab) send(method string, path string, out interface{}) error {
+	u := fmt.Sprintf("%s/api/v4/projects/%s%s", g.Config.Endpoint, g.Config.Project, path)
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		glog.Errorf("HTTP request failed: %v", err)
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.Config.Token)
+	return sendRequest(req, out)
+}
+
+// gitlabState converts the status of a GitLab job
+func gitlabState(status string) string {
+	switch status {
+	case "success", "skipped", "manual":
+		return JobStateSuccess
+	case "failed", "canceled":
+		return JobStateFailure
+	}
+	return JobStatePending
+}
@@ -0,0 +1,146 @@
+package retest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	. "github.com/huawei-cloudnative/ci-bot/handlers/types"
+)
+
+// parameters of the jenkins jobs
+const (
+	jenkinsParamPRNumber = "PR_NUMBER"
+	jenkinsParamHeadSHA  = "HEAD_SHA"
+	jenkinsParamHeadRef  = "HEAD_REF"
+)
+
+// Jenkins runs the parameterized jenkins jobs of the pull requests
+type Jenkins struct {
+	Config config.Jenkins
+}
+
+// Name of the provider
+func (j *Jenkins) Name() string {
+	return config.CIProviderJenkins
+}
+
+// Jobs lists the latest build of each job which is triggered for the head commit
+func (j *Jenkins) Jobs(pr PullRequest) ([]Job, error) {
+	jobs := make([]Job, 0, len(j.Config.Jobs))
+	for _, name := range j.Config.Jobs {
+		var JenkinsJobRespBody JenkinsJobRespStruct
+		path := fmt.Sprintf("%s/api/json?tree=builds[number,result,building,url,actions[parameters[name,value]]]{0,50}", jenkinsJobPath(name))
+		err := j.send(http.MethodGet, path, &JenkinsJobRespBody)
+		if err != nil {
+			glog.Errorf("Failed to get builds of jenkins job %s: %v", name, err)
+			return nil, err
+		}
+
+		// the builds are sorted by the newest first. The job is pending if it is not built yet.
+		job := Job{Name: name, State: JobStatePending}
+		for _, b := range JenkinsJobRespBody.Builds {
+			params := make(map[string]string)
+			for _, a := range b.Actions {
+				for _, p := range a.Parameters {
+					params[p.Name] = fmt.Sprint(p.Value)
+				}
+			}
+			if params[jenkinsParamPRNumber] != strconv.Itoa(pr.Number) || params[jenkinsParamHeadSHA] != pr.HeadSHA {
+				continue
+			}
+			job.ID = strconv.Itoa(b.Number)
+			job.State = jenkinsState(b.Building, b.Result)
+			job.URL = b.URL
+			break
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// RetestAll triggers all the jobs
+func (j *Jenkins) RetestAll(pr PullRequest) error {
+	for _, name := range j.Config.Jobs {
+		err := j.Retest(pr, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retest triggers the job of the name with the pull request parameters
+func (j *Jenkins) Retest(pr PullRequest, name string) error {
+	found := false
+	for _, n := range j.Config.Jobs {
+		if n == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown job %s", name)
+	}
+
+	params := url.Values{}
+	params.Set(jenkinsParamPRNumber, strconv.Itoa(pr.Number))
+	params.Set(jenkinsParamHeadSHA, pr.HeadSHA)
+	params.Set(jenkinsParamHeadRef, pr.HeadRef)
+	path := fmt.Sprintf("%s/buildWithParameters?%s", jenkinsJobPath(name), params.Encode())
+	err := j.send(http.MethodPost, path, nil)
+	if err != nil {
+		glog.Errorf("Failed to trigger jenkins job %s: %v", name, err)
+		return err
+	}
+	glog.Infof("Trigger jenkins job %s of pr #%d", name, pr.Number)
+	return nil
+}
+
+// Status returns the combined state of the jobs
+func (j *Jenkins) Status(pr PullRequest) (string, error) {
+	jobs, err := j.Jobs(pr)
+	if err != nil {
+		return "", err
+	}
+	return CombinedState(jobs), nil
+}
+
+// send sends a jenkins API request, the API token does not require a crumb
+func (j *Jenkins) send(method string, path string, out interface{}) error {
+	req, err := http.NewRequest(method, j.Config.Endpoint+path, nil)
+	if err != nil {
+		glog.Errorf("HTTP request failed: %v", err)
+		return err
+	}
+	req.SetBasicAuth(j.Config.User, j.Config.Token)
+	return sendRequest(req, out)
+}
+
+// jenkinsJobPath returns the path of the job, the jobs in the folders are named by their folders. e.g. folder/job
+func jenkinsJobPath(name string) string {
+	var b strings.Builder
+	for _, segment := range strings.Split(strings.Trim(name, "/"), "/") {
+		b.WriteString("/job/" + url.PathEscape(segment))
+	}
+	return b.String()
+}
+
+// jenkinsState converts the result of a jenkins build
+func jenkinsState(building bool, result string) string {
+	if building {
+		return JobStatePending
+	}
+	switch result {
+	case "SUCCESS":
+		return JobStateSuccess
+	case "":
+		return JobStatePending
+	}
+	return JobStateFailure
+}
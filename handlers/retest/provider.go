@@ -0,0 +1,149 @@
+package retest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+const (
+	ContentTypeJSON = "application/json" //content-type
+)
+
+// states of the CI jobs
+const (
+	JobStatePending = "pending"
+	JobStateSuccess = "success"
+	JobStateFailure = "failure"
+)
+
+// httpClient sends the requests to the CI providers
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// PullRequest identifies the pull request whose jobs are run
+type PullRequest struct {
+	Org     string
+	Repo    string
+	Number  int
+	HeadSHA string
+	HeadRef string
+}
+
+// Job is a CI job of a pull request
+type Job struct {
+	// Name is used by the /test command. e.g. build
+	Name string
+	// Stage groups the jobs, all the jobs of a stage are rerun by /test <stage>
+	Stage string
+	ID    string
+	State string
+	URL   string
+}
+
+// CIProvider runs the CI jobs of the pull requests
+type CIProvider interface {
+	// Name of the provider. e.g. travis
+	Name() string
+	// Jobs lists the latest jobs of the pull request
+	Jobs(pr PullRequest) ([]Job, error)
+	// RetestAll reruns all the jobs of the pull request
+	RetestAll(pr PullRequest) error
+	// Retest reruns the jobs whose name or stage is the name
+	Retest(pr PullRequest, name string) error
+	// Status returns the combined state of the jobs
+	Status(pr PullRequest) (string, error)
+}
+
+// NewCIProvider returns the CI provider which is chosen by the repository settings
+func NewCIProvider(client *github.Client, cfg config.RepoConfig) (CIProvider, error) {
+	switch cfg.CIProvider {
+	case "", config.CIProviderTravis:
+		return &Travis{Config: cfg.Travis}, nil
+	case config.CIProviderGitHubActions:
+		return &GitHubActions{GithubClient: client}, nil
+	case config.CIProviderJenkins:
+		if cfg.Jenkins.Endpoint == "" || len(cfg.Jenkins.Jobs) == 0 {
+			return nil, fmt.Errorf("jenkins endpoint and jobs are required")
+		}
+		return &Jenkins{Config: cfg.Jenkins}, nil
+	case config.CIProviderGitLab:
+		return &GitLab{Config: cfg.GitLab}, nil
+	}
+	return nil, fmt.Errorf("unknown ci provider %s", cfg.CIProvider)
+}
+
+// CombinedState returns failure if any job failed, pending if any job is not finished, otherwise success
+func CombinedState(jobs []Job) string {
+	if len(jobs) == 0 {
+		return JobStatePending
+	}
+	state := JobStateSuccess
+	for _, j := range jobs {
+		switch j.State {
+		case JobStateFailure:
+			return JobStateFailure
+		case JobStatePending:
+			state = JobStatePending
+		}
+	}
+	return state
+}
+
+// FindJobs returns the jobs whose name or stage is the name
+func FindJobs(jobs []Job, name string) []Job {
+	found := make([]Job, 0)
+	for _, j := range jobs {
+		if MatchJobName(j.Name, name) || MatchJobName(j.Stage, name) {
+			found = append(found, j)
+		}
+	}
+	return found
+}
+
+// MatchJobName checks if the name in a /test command refers to the job name.
+// It is case insensitive and the spaces in the job name are written as "-". e.g. "Unit Test" is unit-test
+func MatchJobName(jobName string, name string) bool {
+	return jobName != "" && strings.EqualFold(strings.Replace(strings.TrimSpace(jobName), " ", "-", -1), name)
+}
+
+// findJobs returns the jobs of the name or an error if there is none
+func findJobs(jobs []Job, name string) ([]Job, error) {
+	found := FindJobs(jobs, name)
+	if len(found) == 0 {
+		return nil, fmt.Errorf("unknown job %s", name)
+	}
+	return found, nil
+}
+
+// sendRequest sends the request to the CI provider and decodes the json response into out if it is not nil
+func sendRequest(req *http.Request, out interface{}) error {
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		glog.Errorf("HTTP Do request failed: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		glog.Errorf("Failed to read resp: %v", err)
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		glog.Errorf("%s %s failed, HttpStatus Code: %d", req.Method, req.URL, resp.StatusCode)
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
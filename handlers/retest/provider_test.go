@@ -0,0 +1,181 @@
+package retest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// fakeCI serves the fixed responses of a CI provider and records the other requests
+type fakeCI struct {
+	lock      sync.Mutex
+	responses map[string]string
+	posted    []string
+}
+
+func (f *fakeCI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.Method == http.MethodPost {
+		f.posted = append(f.posted, r.URL.EscapedPath())
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	body, ok := f.responses[r.URL.EscapedPath()]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(body))
+}
+
+var testPR = PullRequest{Org: "test", Repo: "hello", Number: 1, HeadSHA: "head1", HeadRef: "feature"}
+
+// newProvider returns the provider of the name which sends the requests to the fake server
+func newProvider(t *testing.T, name string, server *httptest.Server) CIProvider {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	cfg := config.RepoConfig{
+		CIProvider: name,
+		Travis:     config.Travis{Endpoint: server.URL, RepoName: "test%2Fhello"},
+		Jenkins:    config.Jenkins{Endpoint: server.URL, Jobs: []string{"build", "e2e"}},
+		GitLab:     config.GitLab{Endpoint: server.URL, Project: "42"},
+	}
+	provider, err := NewCIProvider(client, cfg)
+	if err != nil {
+		t.Fatalf("NewCIProvider() error = %v", err)
+	}
+	if provider.Name() != name {
+		t.Fatalf("Name() = %s, want %s", provider.Name(), name)
+	}
+	return provider
+}
+
+//TestCIProviders tests that each provider lists and reruns the jobs of a pr
+func TestCIProviders(t *testing.T) {
+	tests := []struct {
+		name       string
+		responses  map[string]string
+		wantJobs   []string
+		wantStatus string
+		// wantRetest is the request to rerun the job named build
+		wantRetest string
+		// wantRetestAll are the requests to rerun all the jobs
+		wantRetestAll []string
+	}{
+		{
+			name: config.CIProviderTravis,
+			responses: map[string]string{
+				"/repo/test%2Fhello/builds": `{"builds": [{"@href": "/build/8", "pull_request_number": 2}, {"@href": "/build/7", "pull_request_number": 1}]}`,
+				"/build/7/jobs": `{"jobs": [
+					{"id": 71, "number": "7.1", "state": "passed", "config": {"name": "build"}, "stage": {"name": "test"}},
+					{"id": 72, "number": "7.2", "state": "started", "config": {"name": "Verify Code"}, "stage": {"name": "test"}}]}`,
+			},
+			wantJobs:      []string{"build", "Verify Code"},
+			wantStatus:    JobStatePending,
+			wantRetest:    "/job/71/restart",
+			wantRetestAll: []string{"/build/7/restart"},
+		},
+		{
+			name: config.CIProviderGitHubActions,
+			responses: map[string]string{
+				"/repos/test/hello/actions/runs": `{"workflow_runs": [
+					{"id": 3, "name": "build", "head_sha": "head1", "status": "completed", "conclusion": "failure"},
+					{"id": 2, "name": "build", "head_sha": "head1", "status": "completed", "conclusion": "success"},
+					{"id": 1, "name": "lint", "head_sha": "head1", "status": "completed", "conclusion": "success"}]}`,
+			},
+			wantJobs:      []string{"build", "lint"},
+			wantStatus:    JobStateFailure,
+			wantRetest:    "/repos/test/hello/actions/runs/3/rerun",
+			wantRetestAll: []string{"/repos/test/hello/actions/runs/3/rerun", "/repos/test/hello/actions/runs/1/rerun"},
+		},
+		{
+			name: config.CIProviderJenkins,
+			responses: map[string]string{
+				"/job/build/api/json": `{"builds": [
+					{"number": 5, "result": "FAILURE", "actions": [{"parameters": [{"name": "PR_NUMBER", "value": "2"}, {"name": "HEAD_SHA", "value": "head2"}]}]},
+					{"number": 4, "result": "SUCCESS", "actions": [{"parameters": [{"name": "PR_NUMBER", "value": "1"}, {"name": "HEAD_SHA", "value": "head1"}]}]}]}`,
+				"/job/e2e/api/json": `{"builds": []}`,
+			},
+			wantJobs:      []string{"build", "e2e"},
+			wantStatus:    JobStatePending,
+			wantRetest:    "/job/build/buildWithParameters",
+			wantRetestAll: []string{"/job/build/buildWithParameters", "/job/e2e/buildWithParameters"},
+		},
+		{
+			name: config.CIProviderGitLab,
+			responses: map[string]string{
+				"/api/v4/projects/42/pipelines":        `[{"id": 9, "sha": "head1"}]`,
+				"/api/v4/projects/42/pipelines/9/jobs": `[{"id": 91, "name": "build", "status": "success"}, {"id": 92, "name": "test", "status": "success"}]`,
+			},
+			wantJobs:      []string{"build", "test"},
+			wantStatus:    JobStateSuccess,
+			wantRetest:    "/api/v4/projects/42/jobs/91/retry",
+			wantRetestAll: []string{"/api/v4/projects/42/pipelines/9/retry"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeCI{responses: tt.responses}
+			server := httptest.NewServer(f)
+			defer server.Close()
+			provider := newProvider(t, tt.name, server)
+
+			jobs, err := provider.Jobs(testPR)
+			if err != nil {
+				t.Fatalf("Jobs() error = %v", err)
+			}
+			names := make([]string, 0)
+			for _, j := range jobs {
+				names = append(names, j.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantJobs) {
+				t.Errorf("Jobs() = %v, want %v", names, tt.wantJobs)
+			}
+			if status, err := provider.Status(testPR); err != nil || status != tt.wantStatus {
+				t.Errorf("Status() = %s, %v, want %s", status, err, tt.wantStatus)
+			}
+
+			if err := provider.Retest(testPR, "build"); err != nil {
+				t.Fatalf("Retest() error = %v", err)
+			}
+			if !reflect.DeepEqual(f.posted, []string{tt.wantRetest}) {
+				t.Errorf("Retest() posted %v, want %v", f.posted, tt.wantRetest)
+			}
+			if err := provider.Retest(testPR, "unknown"); err == nil {
+				t.Errorf("Retest() of unknown job error = nil, want error")
+			}
+
+			f.posted = nil
+			if err := provider.RetestAll(testPR); err != nil {
+				t.Fatalf("RetestAll() error = %v", err)
+			}
+			if !reflect.DeepEqual(f.posted, tt.wantRetestAll) {
+				t.Errorf("RetestAll() posted %v, want %v", f.posted, tt.wantRetestAll)
+			}
+		})
+	}
+}
+
+//TestJenkinsJobPath tests that the jobs in the folders are addressed one folder at a time
+func TestJenkinsJobPath(t *testing.T) {
+	tests := map[string]string{
+		"build":             "/job/build",
+		"folder/deploy":     "/job/folder/job/deploy",
+		"team/ci/unit test": "/job/team/job/ci/job/unit%20test",
+		"/folder/deploy/":   "/job/folder/job/deploy",
+	}
+	for name, want := range tests {
+		if got := jenkinsJobPath(name); got != want {
+			t.Errorf("jenkinsJobPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -1,52 +1,172 @@
 package retest
 
 import (
+	"context"
+	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
 )
 
-var(
-	retestReg   = regexp.MustCompile("^/[Rr][Ee][Tt][Ee][Ss][Tt]")
-	testReg     = regexp.MustCompile("^/[Tt][Ee][Ss][Tt]")
+var (
+	retestReg = regexp.MustCompile(`(?mi)^/retest\s*$`)
+	// testReg matches "/test build verify", names are separated by spaces
+	testReg = regexp.MustCompile(`(?mi)^/test((?: +[-\w.:]+)*)\s*$`)
 )
 
-// Handle event with label
-func Handle(client *github.Client, event github.IssueCommentEvent, token, repoid string) error {
+// testAll is the name which reruns all the jobs. e.g. /test all
+const testAll = "all"
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name:     "retest",
+		Help:     "/retest and /test all rerun all the CI jobs of a pr. /test <job> [<job>...] reruns the jobs or the stages of the names. e.g. /test build verify",
+		Commands: []*regexp.Regexp{retestReg, testReg},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return Handle(agent, event)
+		},
+	})
+}
+
+// Handle event with retest
+func Handle(agent plugins.Agent, event github.IssueCommentEvent) error {
+	if !event.Issue.IsPullRequest() {
+		return nil
+	}
 
 	comment := *event.Comment.Body
 	glog.Infof("Receive event with retest. comment: %s", comment)
 
-	// https://github.com/islinwb/test/pull/1
-	prID := strings.SplitAfter(event.Issue.PullRequestLinks.GetHTMLURL(), "github.com/")[1]
+	// the head commit is used by the CI providers to find the jobs
+	org := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.Issue.GetNumber()
+	pr, _, err := agent.GithubClient.PullRequests.Get(context.Background(), org, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return err
+	}
+	// the trusted users can always run the jobs, the pr author only when the pr is ok to test
+	commenter := event.Comment.GetUser().GetLogin()
+	trusted, err := util.IsTrusted(agent.GithubClient, agent.Config, org, repo, commenter)
+	if err != nil {
+		return err
+	}
+	if !trusted {
+		if util.HasLabel(pr.Labels, config.LabelNeedsOkToTest) {
+			glog.Infof("Pr #%d needs ok-to-test", number)
+			return agent.Respond(event, "the CI jobs can not run until a trusted member comments `/ok-to-test`.")
+		}
+		if commenter != pr.GetUser().GetLogin() {
+			glog.Infof("%s is not trusted to test pr #%d", commenter, number)
+			return agent.Respond(event, fmt.Sprintf("only the pr author, the members of %s and the collaborators of %s can run the CI jobs.", org, repo))
+		}
+	}
 
-	//First index contains -islinwb/test/pull/1
-	subStrings := strings.Split(prID, "/")
-	len := len(subStrings)
-	prNum, err:= strconv.Atoi(subStrings[len-1])
+	provider, err := NewCIProvider(agent.GithubClient, agent.Config)
 	if err != nil {
-		glog.Errorf("Fail to handle: %v", err)
+		glog.Errorf("Unable to create CI provider: %v", err)
 		return err
 	}
+	target := NewPullRequest(pr)
 
-	if retestReg.MatchString(comment) {
-		// "/retest"
-		err:= SendToCIForRetestAllJobs(prNum, token, repoid)
+	names := TestNames(comment)
+	if retestReg.MatchString(comment) || containsName(names, testAll) {
+		// "/retest" or "/test all"
+		err := provider.RetestAll(target)
 		if err != nil {
 			glog.Errorf("Retest operation failed: %v", err)
 			return err
 		}
-	} else if testReg.MatchString(comment) {
-		// trigger particular job(s)
-		job:= strings.Split(comment, " ")
-		err:= SendToCIForTestJob(prNum, job[1], token, repoid)
+		return nil
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	// trigger particular job(s)
+	jobs, err := provider.Jobs(target)
+	if err != nil {
+		glog.Errorf("Unable to list jobs: %v", err)
+		return err
+	}
+	known, unknown := ResolveJobNames(jobs, names)
+	for _, name := range known {
+		err := provider.Retest(target, name)
 		if err != nil {
 			glog.Errorf("Test job failed: %v", err)
 			return err
 		}
 	}
+	if len(unknown) > 0 {
+		return agent.Respond(event, fmt.Sprintf("unknown job %s. The jobs of %s are: %s. Use `/test all` to rerun all of them.",
+			strings.Join(unknown, ", "), provider.Name(), strings.Join(JobNames(jobs), ", ")))
+	}
 	return nil
 }
+
+// TestNames returns the job names of the /test commands in the comment
+func TestNames(comment string) []string {
+	names := make([]string, 0)
+	for _, match := range testReg.FindAllStringSubmatch(comment, -1) {
+		for _, n := range strings.Fields(match[1]) {
+			if !containsName(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// ResolveJobNames splits the names into the ones which refer to the jobs or stages and the unknown ones
+func ResolveJobNames(jobs []Job, names []string) (known []string, unknown []string) {
+	for _, n := range names {
+		if len(FindJobs(jobs, n)) > 0 {
+			known = append(known, n)
+		} else {
+			unknown = append(unknown, n)
+		}
+	}
+	return known, unknown
+}
+
+// JobNames returns the names and stages of the jobs which can be used by /test
+func JobNames(jobs []Job) []string {
+	names := make([]string, 0)
+	for _, j := range jobs {
+		for _, n := range []string{j.Name, j.Stage} {
+			n = strings.Replace(strings.TrimSpace(n), " ", "-", -1)
+			if n != "" && !containsName(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// containsName checks if the names contain the name case insensitively
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPullRequest returns the pull request whose jobs are run by the CI providers
+func NewPullRequest(pr *github.PullRequest) PullRequest {
+	return PullRequest{
+		Org:     pr.GetBase().GetRepo().GetOwner().GetLogin(),
+		Repo:    pr.GetBase().GetRepo().GetName(),
+		Number:  pr.GetNumber(),
+		HeadSHA: pr.GetHead().GetSHA(),
+		HeadRef: pr.GetHead().GetRef(),
+	}
+}
@@ -0,0 +1,135 @@
+package retest
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/response"
+)
+
+//TestTestNames tests the job names of the /test commands
+func TestTestNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		want    []string
+	}{
+		{name: "one job", comment: "/test build", want: []string{"build"}},
+		{name: "several jobs", comment: "/test build unit-test\n/test e2e build", want: []string{"build", "unit-test", "e2e"}},
+		{name: "all", comment: "/TEST all", want: []string{"all"}},
+		{name: "no job", comment: "/test", want: []string{}},
+		{name: "not a command", comment: "please /test build", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TestNames(tt.comment); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TestNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+//TestResolveJobNames tests that the names refer to the job names or the stage names
+func TestResolveJobNames(t *testing.T) {
+	jobs := []Job{
+		{Name: "build", Stage: "test"},
+		{Name: "Unit Test", Stage: "test"},
+		{Name: "e2e", Stage: "integration"},
+	}
+	known, unknown := ResolveJobNames(jobs, []string{"Build", "unit-test", "integration", "lint"})
+	if want := []string{"Build", "unit-test", "integration"}; !reflect.DeepEqual(known, want) {
+		t.Errorf("known = %v, want %v", known, want)
+	}
+	if want := []string{"lint"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("unknown = %v, want %v", unknown, want)
+	}
+	if got, want := len(FindJobs(jobs, "test")), 2; got != want {
+		t.Errorf("jobs of stage test = %d, want %d", got, want)
+	}
+	if got, want := JobNames(jobs), []string{"build", "test", "Unit-Test", "e2e", "integration"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("JobNames() = %v, want %v", got, want)
+	}
+}
+
+//TestTravisJobNames tests the travis job names in the repository settings
+func TestTravisJobNames(t *testing.T) {
+	travis := &Travis{Config: config.Travis{JobNames: []string{"build", "verify"}}}
+	tests := []struct {
+		number string
+		name   string
+		want   string
+	}{
+		{number: "12.1", name: "compile", want: "build"},
+		{number: "12.2", name: "", want: "verify"},
+		{number: "12.3", name: "e2e", want: "e2e"},
+		{number: "12.4", name: "", want: "12.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := travis.jobName(tt.number, tt.name); got != tt.want {
+				t.Errorf("jobName() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeResponder records the responses
+type fakeResponder struct {
+	messages []string
+}
+
+func (f *fakeResponder) Respond(t response.Target, message string) error {
+	f.messages = append(f.messages, message)
+	return nil
+}
+
+//TestHandleNeedsOkToTest tests that the trusted members can retest a pr which needs ok-to-test, but its author can not
+func TestHandleNeedsOkToTest(t *testing.T) {
+	f := &fakeCI{responses: map[string]string{
+		"/repos/test/hello/pulls/1": `{"number": 1, "user": {"login": "author"}, "head": {"sha": "head1"},
+			"labels": [{"name": "needs-ok-to-test"}]}`,
+		"/orgs/test/members/alice": "",
+	}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	responder := &fakeResponder{}
+	agent := plugins.Agent{
+		GithubClient: client,
+		Responder:    responder,
+		Config: config.RepoConfig{
+			CIProvider: config.CIProviderJenkins,
+			Jenkins:    config.Jenkins{Endpoint: server.URL, Jobs: []string{"build"}},
+		},
+	}
+
+	tests := []struct {
+		user       string
+		wantPosted int
+		wantRefuse bool
+	}{
+		{user: "author", wantRefuse: true},
+		{user: "alice", wantPosted: 1},
+	}
+	for _, tt := range tests {
+		f.posted, responder.messages = nil, nil
+		event := github.IssueCommentEvent{
+			Issue:   &github.Issue{Number: github.Int(1), PullRequestLinks: &github.PullRequestLinks{}},
+			Comment: &github.IssueComment{Body: github.String("/retest"), User: &github.User{Login: github.String(tt.user)}},
+			Repo:    &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+		}
+		if err := Handle(agent, event); err != nil {
+			t.Fatalf("Handle() of %s error = %v", tt.user, err)
+		}
+		if len(f.posted) != tt.wantPosted || (len(responder.messages) > 0) != tt.wantRefuse {
+			t.Errorf("%s: posted = %v, responses = %v", tt.user, f.posted, responder.messages)
+		}
+	}
+}
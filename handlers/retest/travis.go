@@ -0,0 +1,155 @@
+package retest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	. "github.com/huawei-cloudnative/ci-bot/handlers/types"
+)
+
+const (
+	TravisAPIVersion = "Travis-API-Version" //API version, a Mandatory header field for Travis-CI V3 APIs call
+)
+
+// Travis runs the jobs with the Travis-CI V3 APIs
+type Travis struct {
+	Config config.Travis
+}
+
+// Name of the provider
+func (t *Travis) Name() string {
+	return config.CIProviderTravis
+}
+
+// Jobs lists the jobs of the latest build of the pull request
+func (t *Travis) Jobs(pr PullRequest) ([]Job, error) {
+	href, err := t.latestBuild(pr)
+	if err != nil {
+		return nil, err
+	}
+	var TravisJobRespBody TravisJobRespStruct
+	err = t.send(http.MethodGet, href+"/jobs?include=job.config,job.stage", &TravisJobRespBody)
+	if err != nil {
+		glog.Errorf("Failed to get jobs from Travis-CI: %v", err)
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(TravisJobRespBody.Jobs))
+	for _, j := range TravisJobRespBody.Jobs {
+		jobs = append(jobs, Job{
+			Name:  t.jobName(j.Number, j.Config.Name),
+			Stage: j.Stage.Name,
+			ID:    strconv.Itoa(j.ID),
+			State: travisState(j.State),
+			URL:   j.Href,
+		})
+	}
+	return jobs, nil
+}
+
+// RetestAll restarts the latest build of the pull request
+func (t *Travis) RetestAll(pr PullRequest) error {
+	href, err := t.latestBuild(pr)
+	if err != nil {
+		return err
+	}
+	err = t.send(http.MethodPost, href+"/restart", nil)
+	if err != nil {
+		glog.Errorf("Restart Build is failed to trigger: %v", err)
+		return err
+	}
+	glog.Info("Restart Build is successfully triggered !!")
+	return nil
+}
+
+// Retest restarts the jobs whose name or stage is the name
+func (t *Travis) Retest(pr PullRequest, name string) error {
+	jobs, err := t.Jobs(pr)
+	if err != nil {
+		return err
+	}
+	found, err := findJobs(jobs, name)
+	if err != nil {
+		return err
+	}
+	for _, job := range found {
+		err = t.send(http.MethodPost, fmt.Sprintf("/job/%s/restart", job.ID), nil)
+		if err != nil {
+			glog.Errorf("Restart Job Build %s is failed to trigger: %v", job.Name, err)
+			return err
+		}
+		glog.Infof("Restart Job Build %s is successfully triggered !!", job.Name)
+	}
+	return nil
+}
+
+// Status returns the combined state of the jobs
+func (t *Travis) Status(pr PullRequest) (string, error) {
+	jobs, err := t.Jobs(pr)
+	if err != nil {
+		return "", err
+	}
+	return CombinedState(jobs), nil
+}
+
+// latestBuild returns the href of the latest build of the pull request. e.g. /build/1
+func (t *Travis) latestBuild(pr PullRequest) (string, error) {
+	var TravisBuildsRespBody TravisBuildsRespStruct
+	path := fmt.Sprintf("/repo/%s/builds?event_type=pull_request&sort_by=id:desc&limit=100", t.Config.RepoName)
+	err := t.send(http.MethodGet, path, &TravisBuildsRespBody)
+	if err != nil {
+		glog.Errorf("Failed to get builds from Travis-CI: %v", err)
+		return "", err
+	}
+	for _, b := range TravisBuildsRespBody.Builds {
+		if b.PullRequestNumber == pr.Number {
+			return b.Href, nil
+		}
+	}
+	return "", fmt.Errorf("no travis build of pr #%d", pr.Number)
+}
+
+// send sends a Travis-CI V3 API request
+func (t *Travis) send(method string, path string, out interface{}) error {
+	req, err := http.NewRequest(method, t.Config.Endpoint+path, nil)
+	if err != nil {
+		glog.Errorf("HTTP request failed: %v", err)
+		return err
+	}
+	//Mandatory Header sets to be included for Travis-CI API's
+	req.Header.Set("Authorization", "token "+t.Config.Token)
+	req.Header.Set(TravisAPIVersion, "3")
+	return sendRequest(req, out)
+}
+
+// jobName returns the name of the job in the repository settings by the job number, e.g. the first name is
+// the name of the job 12.1. Otherwise it returns the name in .travis.yml or the job number.
+func (t *Travis) jobName(number string, name string) string {
+	strs := strings.Split(number, ".")
+	if len(strs) == 2 {
+		i, err := strconv.Atoi(strs[1])
+		if err == nil && i >= 1 && i <= len(t.Config.JobNames) {
+			return t.Config.JobNames[i-1]
+		}
+	}
+	if name != "" {
+		return name
+	}
+	return number
+}
+
+// travisState converts the state of a Travis-CI job
+func travisState(state string) string {
+	switch state {
+	case "passed":
+		return JobStateSuccess
+	case "failed", "errored", "canceled":
+		return JobStateFailure
+	}
+	return JobStatePending
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/queue"
+)
+
+// handlePullRequestReviewEvent handles the submitted reviews by their states and the commands in their bodies
+func (s *Server) handlePullRequestReviewEvent(delivery *queue.Delivery, client *github.Client) error {
+	glog.Infof("Received a PullRequestReview Event")
+
+	var reviewEvent github.PullRequestReviewEvent
+	err := json.Unmarshal(delivery.Payload, &reviewEvent)
+	if err != nil {
+		// it can not be fixed by retries
+		glog.Errorf("Failed to unmarshal reviewEvent: %v", err)
+		return nil
+	}
+
+	org := reviewEvent.Repo.GetOwner().GetLogin()
+	repo := reviewEvent.Repo.GetName()
+	agent, err := s.newAgent(client, org, repo)
+	if err != nil {
+		glog.Errorf("Failed to load repository %s/%s: %v", org, repo, err)
+		return err
+	}
+	// only the allowed users can review with commands
+	reviewer := reviewEvent.GetReview().GetUser().GetLogin()
+	if !agent.Config.IsCommandAuthor(reviewer) {
+		glog.Infof("%s is not allowed to comment commands in %s/%s", reviewer, org, repo)
+		return nil
+	}
+	return dispatchReview(plugins.Enabled(agent.Config.Plugins), agent, reviewEvent, delivery)
+}
+
+// dispatchReview invokes the plugins which handle the review states, and then the plugins whose commands match the review body.
+// The plugins which handled the delivery already are skipped.
+func dispatchReview(list []plugins.Plugin, agent plugins.Agent, event github.PullRequestReviewEvent, delivery *queue.Delivery) error {
+	if event.GetAction() != plugins.ActionSubmitted {
+		glog.Infof("Skip pull request review action: %s", event.GetAction())
+		return nil
+	}
+	errs := make([]error, 0)
+	for _, p := range list {
+		// the review state and the commands in the body are separate steps of a plugin
+		step := p.Name + "/review"
+		if p.ReviewHandler == nil || delivery.IsDone(step) {
+			continue
+		}
+		err := p.ReviewHandler(agent, event)
+		if err != nil {
+			glog.Errorf("Failed to handle %s: %v", p.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %v", p.Name, err))
+			continue
+		}
+		delivery.MarkDone(step)
+	}
+	if body := event.GetReview().GetBody(); body != "" {
+		err := dispatchIssueComment(list, agent, plugins.ReviewCommentEvent(event, body), delivery)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return aggregateErrors(errs)
+}
+
+// handlePullRequestReviewCommentEvent handles the commands in the comments on the pr diffs like the issue comments
+func (s *Server) handlePullRequestReviewCommentEvent(delivery *queue.Delivery, client *github.Client) error {
+	glog.Infof("Received a PullRequestReviewComment Event")
+
+	var commentEvent github.PullRequestReviewCommentEvent
+	err := json.Unmarshal(delivery.Payload, &commentEvent)
+	if err != nil {
+		// it can not be fixed by retries
+		glog.Errorf("Failed to unmarshal review commentEvent: %v", err)
+		return nil
+	}
+
+	org := commentEvent.Repo.GetOwner().GetLogin()
+	repo := commentEvent.Repo.GetName()
+	agent, err := s.newAgent(client, org, repo)
+	if err != nil {
+		glog.Errorf("Failed to load repository %s/%s: %v", org, repo, err)
+		return err
+	}
+	commentAuthor := commentEvent.GetComment().GetUser().GetLogin()
+	if !agent.Config.IsCommandAuthor(commentAuthor) {
+		glog.Infof("%s is not allowed to comment commands in %s/%s", commentAuthor, org, repo)
+		return nil
+	}
+	event := plugins.DiffCommentEvent(commentEvent)
+	// the edited or deleted comments are skipped like the issue comments
+	event.Action = commentEvent.Action
+	return dispatchIssueComment(plugins.Enabled(agent.Config.Plugins), agent, event, delivery)
+}
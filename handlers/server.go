@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,22 +10,22 @@ import (
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
 	"github.com/spf13/pflag"
 	"golang.org/x/oauth2"
 
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/queue"
 	"github.com/huawei-cloudnative/ci-bot/handlers/repository"
+	"github.com/huawei-cloudnative/ci-bot/handlers/response"
+	"github.com/huawei-cloudnative/ci-bot/handlers/stale"
+	"github.com/huawei-cloudnative/ci-bot/handlers/tide"
 )
 
-//Syncronization Flag for IssueComment and PR event Handling
-//A Comment in PR section will lead Github to throw 2 webhook events which are
-//1.The Webhook event name is "issue_comment"
-//2.The Webhook event name is "pull_request" sequentially.
-//let the handling only be with issue_comment event not pull_request event
-var IsIssueCommentHandling = false
-
 //Github client
 var ClientRepo *github.Client
 var c = Config{}
@@ -33,18 +34,68 @@ var c = Config{}
 // then dispatches them to the handlers accordingly.
 type Server struct {
 	Config       Config
+	ConfigAgent  *config.Agent
 	GithubClient *github.Client
-	Repository   repository.Interface
+	Repositories *repository.Pool
+	Tide         *tide.Controller
+	Stale        *stale.Controller
+	Queue        *queue.Queue
+	Responder    *response.Responder
+	AdminToken   string
 	Context      context.Context
 }
 
 //config structure
 type Config struct {
-	Repo           string `json:"repo"`
-	GitHubToken    string `json:"git_hub_token"`
-	WebhookSecret  string `json:"webhook_secret"`
-	TravisCIToken  string `json:"travis_ci_token"`
-	TravisRepoName string `json:"travis_ci_repoaccount"`
+	Repos          []string `json:"repos"`
+	GitHubToken    string   `json:"git_hub_token"`
+	WebhookSecret  string   `json:"webhook_secret"`
+	TravisCIToken  string   `json:"travis_ci_token"`
+	TravisRepoName string   `json:"travis_ci_repoaccount"`
+	EnabledPlugins []string `json:"enabled_plugins"`
+}
+
+// loadConfig returns the function which loads the configuration file and applies the flags to it
+func loadConfig(file string, c Config) config.LoadFunc {
+	return func() (*config.Config, error) {
+		cfg, err := config.Load(file)
+		if err != nil {
+			return nil, err
+		}
+
+		// flags
+		cfg.ParseEnabledPlugins(c.EnabledPlugins)
+		if cfg.Travis.Token == "" {
+			cfg.Travis.Token = c.TravisCIToken
+		}
+		for i, repo := range c.Repos {
+			org, name, err := repository.ParseRepository(repo)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --repo %s: %v", repo, err)
+			}
+			if cfg.Repos == nil {
+				cfg.Repos = make(map[string]config.RepoConfig)
+			}
+			key := fmt.Sprintf("%s/%s", org, name)
+			rc := cfg.Repos[key]
+			// --repoName belongs to the first repository
+			if i == 0 && rc.Travis.RepoName == "" {
+				rc.Travis.RepoName = c.TravisRepoName
+			}
+			cfg.Repos[key] = rc
+		}
+
+		// validate
+		err = cfg.Validate()
+		if err != nil {
+			return nil, err
+		}
+		err = plugins.Validate(cfg.PluginNames())
+		if err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
 }
 
 //webhook server
@@ -52,13 +103,36 @@ type WebHookServer struct {
 	Address    string
 	Port       int64
 	ConfigFile string
+	// ConfigCheckPeriod is the period to check if the config file is changed
+	ConfigCheckPeriod time.Duration
+	// TideSyncPeriod is the period to sync the merge queues
+	TideSyncPeriod time.Duration
+	// StaleSyncPeriod is the period to mark the idle issues and pull requests as stale
+	StaleSyncPeriod time.Duration
+	// QueueDir stores the webhook events to be handled
+	QueueDir string
+	// QueueOptions are the retry settings of the webhook events
+	QueueOptions queue.Options
+	// AdminToken protects the dead letter endpoints, they are disabled if it is empty
+	AdminToken string
 }
 
 //webhook handler
 func NewWebHookServer() *WebHookServer {
 	s := WebHookServer{
-		Address: "0.0.0.0",
-		Port:    3000,
+		Address:           "0.0.0.0",
+		Port:              3000,
+		ConfigCheckPeriod: 10 * time.Second,
+		TideSyncPeriod:    time.Minute,
+		StaleSyncPeriod:   time.Hour,
+		QueueDir:          "ci-bot-queue",
+		QueueOptions: queue.Options{
+			Workers:     4,
+			MaxAttempts: 5,
+			Backoff:     10 * time.Second,
+			MaxBackoff:  10 * time.Minute,
+			Retention:   7 * 24 * time.Hour,
+		},
 	}
 	return &s
 }
@@ -67,46 +141,75 @@ func NewWebHookServer() *WebHookServer {
 func AddFlags(fs *pflag.FlagSet, s *WebHookServer) {
 	fs.StringVar(&s.Address, "address", s.Address, "IP address to serve, 0.0.0.0 by default")
 	fs.Int64Var(&s.Port, "port", s.Port, "Port to listen on, 3000 by default")
-	fs.StringVar(&c.Repo, "repo", c.Repo, "Refers to the project repo address")
+	fs.StringVar(&s.ConfigFile, "config", s.ConfigFile, "Path to the YAML or JSON config file, it is reloaded on SIGHUP or when it is changed")
+	fs.DurationVar(&s.ConfigCheckPeriod, "config-check-period", s.ConfigCheckPeriod, "Period to check if the config file is changed")
+	fs.DurationVar(&s.TideSyncPeriod, "tide-sync-period", s.TideSyncPeriod, "Period to sync the merge queues")
+	fs.DurationVar(&s.StaleSyncPeriod, "stale-sync-period", s.StaleSyncPeriod, "Period to mark the idle issues and prs as stale and rotten and close them")
+	fs.StringVar(&s.QueueDir, "queue-dir", s.QueueDir, "Directory to store the webhook events to be handled, it should be on a persistent volume")
+	fs.IntVar(&s.QueueOptions.Workers, "workers", s.QueueOptions.Workers, "Number of webhook events which are handled concurrently")
+	fs.IntVar(&s.QueueOptions.MaxAttempts, "max-attempts", s.QueueOptions.MaxAttempts, "Number of attempts before a webhook event is moved to the dead letters")
+	fs.DurationVar(&s.QueueOptions.Backoff, "retry-backoff", s.QueueOptions.Backoff, "Delay before the first retry of a failed webhook event, it is doubled for each retry")
+	fs.DurationVar(&s.QueueOptions.MaxBackoff, "max-retry-backoff", s.QueueOptions.MaxBackoff, "Maximum delay between the retries of a failed webhook event")
+	fs.DurationVar(&s.QueueOptions.Retention, "delivery-retention", s.QueueOptions.Retention, "How long the handled webhook events are remembered to drop redeliveries")
+	fs.StringVar(&s.AdminToken, "admin-token", s.AdminToken, "Token to inspect and replay the dead letters, the endpoints are disabled if it is empty")
+	fs.StringSliceVar(&c.Repos, "repo", c.Repos, "Refers to the project repo addresses which are loaded at startup, the others are loaded on their first webhook event")
 	fs.StringVar(&c.GitHubToken, "github-token", c.GitHubToken, "Contains the githubtoken info")
 	fs.StringVar(&c.WebhookSecret, "webhook-secret", c.WebhookSecret, "Contains the webhooksecret key")
 	fs.StringVar(&c.TravisCIToken, "travis-ci-token", c.TravisCIToken, "Contains Travis-CI access token to trigger the PR build")
-	fs.StringVar(&c.TravisRepoName, "repoName", c.TravisRepoName, "Contains repo name of CI build Ex: kubeedge/kubeedge")
+	fs.StringVar(&c.TravisRepoName, "repoName", c.TravisRepoName, "Contains repo name of CI build Ex: kubeedge/kubeedge. It only applies to the first --repo, the others use their own org/repo")
+	fs.StringArrayVar(&c.EnabledPlugins, "enable-plugins", c.EnabledPlugins, "Enables plugins per repository Ex: kubeedge/kubeedge=lgtm,approve. All plugins are enabled by default")
 	fs.Parse(os.Args[1:])
 }
 
-// ServeHTTP validates an incoming webhook and invoke its handler.
+// ServeHTTP validates an incoming webhook and stores it into the queue.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	payload, err := github.ValidatePayload(r, []byte(c.WebhookSecret))
 	if err != nil {
 		glog.Errorf("Invalid payload: %v", err)
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	// redeliveries have the same delivery id
+	id := github.DeliveryID(r)
+	if id == "" {
+		id = fmt.Sprintf("%x", sha256.Sum256(payload))
+	}
+	err = s.Queue.Add(id, github.WebHookType(r), payload)
+	if err == queue.ErrDuplicate {
+		fmt.Fprint(w, "Received a duplicate webhook event")
 		return
 	}
-	event, err := github.ParseWebHook(github.WebHookType(r), payload)
 	if err != nil {
-		glog.Errorf("Failed to parse webhook")
+		glog.Errorf("Failed to queue webhook %s: %v", id, err)
+		http.Error(w, "Failed to queue the webhook event", http.StatusInternalServerError)
 		return
 	}
 	fmt.Fprint(w, "Received a webhook event")
+}
 
-	var client http.Client
-	client.Do(r)
-	switch event.(type) {
-	case *github.IssueEvent:
-		go s.handleIssueEvent(payload)
-	case *github.IssueCommentEvent:
-		// Comments on PRs belong to IssueCommentEvent
-		IsIssueCommentHandling = true
-		go s.handleIssueCommentEvent(payload, s.GithubClient, s.Repository)
-	case *github.PullRequestEvent:
-		if !IsIssueCommentHandling {
-			go s.handlePullRequestEvent(payload, ClientRepo)
-		}
-		//Fall Back to original state
-		IsIssueCommentHandling = false
-	case *github.PullRequestComment:
-		go s.handlePullRequestCommentEvent(payload)
+// handleDelivery invokes the handler of a webhook event by its X-GitHub-Event header.
+// The event is retried if it returns an error, only the plugins which failed handle it again.
+func (s *Server) handleDelivery(d *queue.Delivery) error {
+	switch d.Event {
+	case plugins.EventIssues:
+		s.handleIssueEvent(d.Payload)
+	case plugins.EventIssueComment:
+		// comments on both issues and prs
+		return s.handleIssueCommentEvent(d, s.GithubClient)
+	case plugins.EventPullRequest:
+		return s.handlePullRequestEvent(d, s.GithubClient)
+	case plugins.EventPullRequestReview:
+		return s.handlePullRequestReviewEvent(d, s.GithubClient)
+	case plugins.EventPullRequestReviewComment:
+		return s.handlePullRequestReviewCommentEvent(d, s.GithubClient)
+	case plugins.EventStatus:
+		s.handleStatusEvent(d.Payload)
+	case plugins.EventPush:
+		return s.handlePushEvent(d.Payload)
+	default:
+		glog.Infof("Skip webhook event: %s", d.Event)
 	}
+	return nil
 }
 
 //function to run
@@ -120,28 +223,53 @@ func Run(s *WebHookServer) {
 	client := github.NewClient(tc)
 	ClientRepo = client
 
-	// new repository instance
-	repository, err := repository.NewRepository(client, c.Repo)
+	// load config
+	configAgent, err := config.NewAgent(loadConfig(s.ConfigFile, c))
 	if err != nil {
-		log.Println(err)
+		glog.Fatalf("Invalid config: %v", err)
 	}
-	// init repository
-	err = repository.Init()
-	if err != nil {
-		log.Println(err)
+	stop := make(chan struct{})
+	go configAgent.Watch(s.ConfigFile, s.ConfigCheckPeriod, stop)
+
+	// merge controller
+	tideController := tide.NewController(client, configAgent)
+	go tideController.Run(s.TideSyncPeriod, stop)
+	// stale controller
+	staleController := stale.NewController(client, configAgent)
+	go staleController.Run(s.StaleSyncPeriod, stop)
+
+	// load the configured repositories, the others are loaded on demand
+	repositories := repository.NewPool(client, configAgent)
+	for repo := range configAgent.Config().Repos {
+		org, name, err := repository.ParseRepository(repo)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		_, err = repositories.Get(org, name)
+		if err != nil {
+			log.Println(err)
+		}
+		tideController.AddRepository(org, name)
+		staleController.AddRepository(org, name)
 	}
 	// catch exit signal
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs)
 	go func() {
 		for sig := range sigs {
+			// reload config
+			if sig == syscall.SIGHUP {
+				configAgent.Reload()
+				continue
+			}
 			if sig == syscall.SIGKILL ||
 				sig == syscall.SIGQUIT ||
-				sig == syscall.SIGHUP ||
 				sig == syscall.SIGTERM ||
 				sig == syscall.SIGINT {
-				// clear repository
-				repository.Clear()
+				close(stop)
+				// clear repositories
+				repositories.Clear()
 				os.Exit(0)
 			}
 		}
@@ -149,12 +277,27 @@ func Run(s *WebHookServer) {
 
 	webHookHandler := Server{
 		Config:       c,
+		ConfigAgent:  configAgent,
 		GithubClient: ClientRepo,
-		Repository:   repository,
+		Repositories: repositories,
+		Tide:         tideController,
+		Stale:        staleController,
+		Responder:    response.NewResponder(ClientRepo, response.DefaultOptions),
+		AdminToken:   s.AdminToken,
 		Context:      ctx,
 	}
+	// webhook event queue
+	webHookHandler.Queue, err = queue.New(s.QueueDir, s.QueueOptions, webHookHandler.handleDelivery)
+	if err != nil {
+		glog.Fatalf("Failed to create queue: %v", err)
+	}
+	webHookHandler.Queue.Run(stop)
+
 	//setting handler
 	http.HandleFunc("/hook", webHookHandler.ServeHTTP)
+	http.HandleFunc("/plugins", webHookHandler.ServePluginHelp)
+	http.HandleFunc("/deadletters", webHookHandler.ServeDeadLetters)
+	http.HandleFunc("/deadletters/replay", webHookHandler.ServeReplay)
 
 	address := s.Address + ":" + strconv.FormatInt(s.Port, 10)
 	//starting server
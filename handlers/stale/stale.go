@@ -0,0 +1,197 @@
+package stale
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// the time format of the search qualifiers
+const searchTimeFormat = "2006-01-02T15:04:05Z"
+
+// NewController returns a controller of the idle issues and pull requests
+func NewController(client *github.Client, configAgent *config.Agent) *Controller {
+	return &Controller{
+		GithubClient: client,
+		ConfigAgent:  configAgent,
+		now:          time.Now,
+		repos:        make(map[string]bool),
+	}
+}
+
+// Controller marks the idle issues and pull requests as stale and then rotten, and closes them at last.
+// The items with the lifecycle/frozen label are skipped.
+type Controller struct {
+	GithubClient *github.Client
+	ConfigAgent  *config.Agent
+
+	// now returns the current time, it is replaced by the tests
+	now func() time.Time
+
+	lock sync.Mutex
+	// repos are the repositories to sync. e.g. test/hello
+	repos map[string]bool
+}
+
+// AddRepository adds a repository to sync
+func (c *Controller) AddRepository(org string, repo string) {
+	c.lock.Lock()
+	c.repos[fmt.Sprintf("%s/%s", org, repo)] = true
+	c.lock.Unlock()
+}
+
+// Run syncs the repositories periodically until stop is closed
+func (c *Controller) Run(period time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		c.Sync()
+	}
+}
+
+// Sync closes the rotten items, and marks the stale items as rotten and the idle items as stale.
+// Each item moves at most one step in a sync, because the labels and the comments update it.
+func (c *Controller) Sync() {
+	c.lock.Lock()
+	repos := make([]string, 0, len(c.repos))
+	for name := range c.repos {
+		repos = append(repos, name)
+	}
+	c.lock.Unlock()
+	sort.Strings(repos)
+
+	now := c.now()
+	for _, name := range repos {
+		strs := strings.Split(name, "/")
+		org, repo := strs[0], strs[1]
+		cfg := c.ConfigAgent.Config().RepoConfigFor(org, repo).Stale
+		if cfg.GetStaleDays() == 0 {
+			continue
+		}
+		glog.Infof("Sync idle issues and prs of %s", name)
+
+		// rotten to closed
+		for _, issue := range c.search(org, repo, now, cfg.CloseDays, []string{config.LabelRotten}, nil) {
+			c.close(org, repo, issue, cfg)
+		}
+		// stale to rotten
+		for _, issue := range c.search(org, repo, now, cfg.RottenDays, []string{config.LabelStale}, []string{config.LabelRotten}) {
+			c.rot(org, repo, issue, cfg)
+		}
+		// idle to stale
+		for _, issue := range c.search(org, repo, now, cfg.GetStaleDays(), nil, []string{config.LabelStale, config.LabelRotten}) {
+			c.mark(org, repo, issue, cfg)
+		}
+	}
+}
+
+// search returns the open items which have the labels and do not have the excluded labels or lifecycle/frozen,
+// and which are not updated for the days
+func (c *Controller) search(org string, repo string, now time.Time, days int, labels []string, excluded []string) []github.Issue {
+	query := fmt.Sprintf("repo:%s/%s is:open", org, repo)
+	for _, l := range labels {
+		query += fmt.Sprintf(" label:%q", l)
+	}
+	for _, l := range append(excluded, config.LabelFrozen) {
+		query += fmt.Sprintf(" -label:%q", l)
+	}
+	query += " updated:<" + now.Add(-time.Duration(days)*24*time.Hour).UTC().Format(searchTimeFormat)
+
+	issues := make([]github.Issue, 0)
+	opt := &github.SearchOptions{Sort: "updated", Order: "asc", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		result, resp, err := c.GithubClient.Search.Issues(context.Background(), query, opt)
+		if err != nil {
+			glog.Errorf("Unable to search idle issues: %s err: %v", query, err)
+			return issues
+		}
+		issues = append(issues, result.Issues...)
+		if resp.NextPage == 0 {
+			return issues
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// mark adds the lifecycle/stale label
+func (c *Controller) mark(org string, repo string, issue github.Issue, cfg config.Stale) {
+	if !c.addLabel(org, repo, issue, config.LabelStale) {
+		return
+	}
+	c.comment(org, repo, issue, fmt.Sprintf("This %s has been idle for %d days and it is marked as stale. "+
+		"It is marked as rotten after %d more idle days, and then closed after %d more idle days.\n\n"+
+		"Comment `/remove-lifecycle stale` to mark it as fresh, or `/lifecycle frozen` to keep it open.",
+		kind(issue), cfg.GetStaleDays(), cfg.RottenDays, cfg.CloseDays))
+}
+
+// rot replaces the lifecycle/stale label with lifecycle/rotten
+func (c *Controller) rot(org string, repo string, issue github.Issue, cfg config.Stale) {
+	if !c.addLabel(org, repo, issue, config.LabelRotten) {
+		return
+	}
+	_, err := c.GithubClient.Issues.RemoveLabelForIssue(context.Background(), org, repo, issue.GetNumber(), config.LabelStale)
+	if err != nil {
+		glog.Errorf("Unable to remove label: %s err: %v", config.LabelStale, err)
+	}
+	c.comment(org, repo, issue, fmt.Sprintf("This %s has been stale for %d days and it is marked as rotten. "+
+		"It is closed after %d more idle days.\n\n"+
+		"Comment `/remove-lifecycle rotten` to mark it as fresh, or `/lifecycle frozen` to keep it open.",
+		kind(issue), cfg.RottenDays, cfg.CloseDays))
+}
+
+// close closes the rotten item
+func (c *Controller) close(org string, repo string, issue github.Issue, cfg config.Stale) {
+	number := issue.GetNumber()
+	if !c.comment(org, repo, issue, fmt.Sprintf("This %s has been rotten for %d days and it is closed.\n\n"+
+		"The collaborators can comment `/reopen` to reopen it.", kind(issue), cfg.CloseDays)) {
+		return
+	}
+	_, _, err := c.GithubClient.Issues.Edit(context.Background(), org, repo, number, &github.IssueRequest{State: github.String("closed")})
+	if err != nil {
+		glog.Errorf("Unable to close #%d err: %v", number, err)
+		return
+	}
+	glog.Infof("Close rotten #%d", number)
+}
+
+// addLabel adds the label to the item, it returns false if it fails
+func (c *Controller) addLabel(org string, repo string, issue github.Issue, label string) bool {
+	_, _, err := c.GithubClient.Issues.AddLabelsToIssue(context.Background(), org, repo, issue.GetNumber(), []string{label})
+	if err != nil {
+		glog.Errorf("Unable to add label: %s err: %v", label, err)
+		return false
+	}
+	glog.Infof("Add label %s to #%d", label, issue.GetNumber())
+	return true
+}
+
+// comment explains the step to the item, it returns false if it fails
+func (c *Controller) comment(org string, repo string, issue github.Issue, body string) bool {
+	_, _, err := c.GithubClient.Issues.CreateComment(context.Background(), org, repo, issue.GetNumber(), &github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		glog.Errorf("Unable to comment on #%d err: %v", issue.GetNumber(), err)
+		return false
+	}
+	return true
+}
+
+// kind returns issue or pull request
+func kind(issue github.Issue) string {
+	if issue.IsPullRequest() {
+		return "pull request"
+	}
+	return "issue"
+}
@@ -0,0 +1,206 @@
+package stale
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// fakeIssue is an issue or a pr of the fake github
+type fakeIssue struct {
+	pr       bool
+	open     bool
+	labels   map[string]bool
+	updated  time.Time
+	comments []string
+}
+
+// fakeGithub searches the issues by the qualifiers which are used by the controller.
+// Every change updates the issue at the current time of the test clock.
+type fakeGithub struct {
+	lock   sync.Mutex
+	now    *time.Time
+	issues map[int]*fakeIssue
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var number int
+	path := r.URL.Path
+	if path == "/search/issues" {
+		result := github.IssuesSearchResult{}
+		for n, issue := range f.issues {
+			if f.match(issue, r.URL.Query().Get("q")) {
+				i := github.Issue{Number: github.Int(n)}
+				if issue.pr {
+					i.PullRequestLinks = &github.PullRequestLinks{}
+				}
+				result.Issues = append(result.Issues, i)
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	fmt.Sscanf(path, "/repos/test/hello/issues/%d", &number)
+	issue, ok := f.issues[number]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	issue.updated = *f.now
+	switch {
+	case strings.HasSuffix(path, "/comments"):
+		var ic github.IssueComment
+		json.NewDecoder(r.Body).Decode(&ic)
+		issue.comments = append(issue.comments, ic.GetBody())
+		json.NewEncoder(w).Encode(ic)
+	case strings.HasSuffix(path, "/labels"):
+		var labels []string
+		json.NewDecoder(r.Body).Decode(&labels)
+		for _, l := range labels {
+			issue.labels[l] = true
+		}
+		json.NewEncoder(w).Encode([]github.Label{})
+	case strings.Contains(path, "/labels/"):
+		delete(issue.labels, path[strings.Index(path, "/labels/")+len("/labels/"):])
+	default:
+		var request github.IssueRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		issue.open = request.GetState() != "closed"
+		json.NewEncoder(w).Encode(github.Issue{})
+	}
+}
+
+// match checks if the issue matches the search query
+func (f *fakeGithub) match(issue *fakeIssue, query string) bool {
+	for _, q := range strings.Fields(query) {
+		switch {
+		case q == "is:open":
+			if !issue.open {
+				return false
+			}
+		case strings.HasPrefix(q, "label:"):
+			if !issue.labels[strings.Trim(strings.TrimPrefix(q, "label:"), `"`)] {
+				return false
+			}
+		case strings.HasPrefix(q, "-label:"):
+			if issue.labels[strings.Trim(strings.TrimPrefix(q, "-label:"), `"`)] {
+				return false
+			}
+		case strings.HasPrefix(q, "updated:<"):
+			before, _ := time.Parse(searchTimeFormat, strings.TrimPrefix(q, "updated:<"))
+			if !issue.updated.Before(before) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// state returns the sorted labels of the issue and whether it is open
+func (f *fakeGithub) state(number int) ([]string, bool) {
+	issue := f.issues[number]
+	labels := make([]string, 0)
+	for l := range issue.labels {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	return labels, issue.open
+}
+
+// newIssue returns an open issue with the labels which is updated the days before now
+func newIssue(now time.Time, days int, labels ...string) *fakeIssue {
+	issue := &fakeIssue{open: true, labels: make(map[string]bool), updated: now.Add(-time.Duration(days) * 24 * time.Hour)}
+	for _, l := range labels {
+		issue.labels[l] = true
+	}
+	return issue
+}
+
+//TestSync tests that the idle items become stale, rotten and closed step by step, and the frozen ones are skipped
+func TestSync(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeGithub{
+		now: &now,
+		issues: map[int]*fakeIssue{
+			1: newIssue(now, 100),
+			2: newIssue(now, 100, config.LabelFrozen),
+			3: newIssue(now, 10),
+			4: newIssue(now, 40, config.LabelStale),
+			5: newIssue(now, 40, config.LabelRotten),
+		},
+	}
+	f.issues[4].pr = true
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	configAgent, err := config.NewAgent(func() (*config.Config, error) {
+		return &config.Config{RepoConfig: config.RepoConfig{Stale: config.Stale{StaleDays: config.Int(90)}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("NewAgent() error = %v", err)
+	}
+	c := NewController(client, configAgent)
+	c.now = func() time.Time { return now }
+	c.AddRepository("test", "hello")
+
+	type state struct {
+		labels []string
+		open   bool
+	}
+	check := func(step string, want map[int]state) {
+		for number, w := range want {
+			labels, open := f.state(number)
+			if !reflect.DeepEqual(labels, w.labels) || open != w.open {
+				t.Errorf("%s: #%d labels = %v open = %t, want %v open = %t", step, number, labels, open, w.labels, w.open)
+			}
+		}
+	}
+
+	c.Sync()
+	check("first sync", map[int]state{
+		1: {labels: []string{config.LabelStale}, open: true},
+		2: {labels: []string{config.LabelFrozen}, open: true},
+		3: {labels: []string{}, open: true},
+		4: {labels: []string{config.LabelRotten}, open: true},
+		5: {labels: []string{config.LabelRotten}, open: false},
+	})
+	if len(f.issues[1].comments) != 1 || !strings.Contains(f.issues[1].comments[0], "This issue has been idle for 90 days") {
+		t.Errorf("comments of #1 = %v, want the stale explanation", f.issues[1].comments)
+	}
+	if len(f.issues[4].comments) != 1 || !strings.Contains(f.issues[4].comments[0], "This pull request has been stale") {
+		t.Errorf("comments of #4 = %v, want the rotten explanation", f.issues[4].comments)
+	}
+
+	// nothing changes until the next step is due
+	c.Sync()
+	check("second sync", map[int]state{
+		1: {labels: []string{config.LabelStale}, open: true},
+		4: {labels: []string{config.LabelRotten}, open: true},
+	})
+
+	now = now.Add(31 * 24 * time.Hour)
+	c.Sync()
+	check("31 days later", map[int]state{
+		1: {labels: []string{config.LabelRotten}, open: true},
+		2: {labels: []string{config.LabelFrozen}, open: true},
+		4: {labels: []string{config.LabelRotten}, open: false},
+	})
+}
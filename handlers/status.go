@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+// handleStatusEvent syncs the merge queues when the status checks change
+func (s *Server) handleStatusEvent(body []byte) {
+	var statusEvent github.StatusEvent
+
+	// Unmarshal
+	err := json.Unmarshal(body, &statusEvent)
+	if err != nil {
+		glog.Errorf("Failed to unmarshal statusEvent: %v", err)
+		return
+	}
+
+	// skip the status which is posted by the merge queue
+	org := statusEvent.Repo.GetOwner().GetLogin()
+	repo := statusEvent.Repo.GetName()
+	if statusEvent.GetContext() == s.ConfigAgent.Config().RepoConfigFor(org, repo).Tide.StatusContext {
+		return
+	}
+	glog.Infof("Received a Status Event. context: %s state: %s", statusEvent.GetContext(), statusEvent.GetState())
+	s.Tide.Trigger()
+}
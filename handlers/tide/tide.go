@@ -0,0 +1,596 @@
+package tide
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/retest"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+const (
+	// description of the status when the pull request is ready
+	descriptionInPool = "In merge pool."
+	// github limits the length of status description
+	maxDescriptionLength = 140
+	// mediaTypeUpdateBranch is the preview media type of the api which merges the base branch into the pull request
+	mediaTypeUpdateBranch = "application/vnd.github.lydian-preview+json"
+)
+
+// NewController returns a merge controller
+func NewController(client *github.Client, configAgent *config.Agent) *Controller {
+	return &Controller{
+		GithubClient: client,
+		ConfigAgent:  configAgent,
+		repos:        make(map[string]bool),
+		incoming:     make(map[string][]int),
+		pools:        make(map[string]*pool),
+		statuses:     make(map[string]string),
+		notices:      make(map[string]string),
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Controller keeps a queue of pull requests per repository and branch,
+// and merges the ready ones at most one at a time per queue.
+type Controller struct {
+	GithubClient *github.Client
+	ConfigAgent  *config.Agent
+
+	lock sync.Mutex
+	// repos are the repositories to sync. e.g. test/hello
+	repos map[string]bool
+	// incoming are the enqueued pull requests which are not in a pool yet
+	incoming map[string][]int
+
+	// pools are only used by the sync loop
+	pools map[string]*pool
+	// statuses are the last head sha and status description per pull request
+	statuses map[string]string
+	// notices are the last unsatisfied requirements of the branch protection per pull request
+	notices map[string]string
+
+	trigger chan struct{}
+}
+
+// pool is the merge queue of a branch
+type pool struct {
+	org    string
+	repo   string
+	branch string
+	// numbers of the pull requests in order
+	numbers []int
+	// tested are the last updates of the pull requests
+	tested map[int]update
+}
+
+// update is a retest or a merge of the base branch into a pull request
+type update struct {
+	// base is the base sha which the pull request is updated to
+	base string
+	// head is the head sha which is retested, it is empty for a merge
+	head string
+	// at is the time of the retest, the statuses which are reported before it are not its results
+	at time.Time
+	// reported is set when a status is reported after the retest
+	reported bool
+	// failed explains why the update failed, it is not tried again until the base or the head moves
+	failed string
+	// contexts are the status checks which are reported on the head before a merge,
+	// they are required on the merged head before the pull request is merged
+	contexts []string
+}
+
+// Enqueue adds a pull request into the merge queue
+func (c *Controller) Enqueue(org string, repo string, number int) {
+	name := fmt.Sprintf("%s/%s", org, repo)
+	glog.Infof("Enqueue pr %s#%d", name, number)
+
+	c.lock.Lock()
+	c.repos[name] = true
+	c.incoming[name] = append(c.incoming[name], number)
+	c.lock.Unlock()
+
+	c.Trigger()
+}
+
+// AddRepository adds a repository to sync, its ready pull requests are found by search
+func (c *Controller) AddRepository(org string, repo string) {
+	c.lock.Lock()
+	c.repos[fmt.Sprintf("%s/%s", org, repo)] = true
+	c.lock.Unlock()
+}
+
+// Trigger starts a sync without waiting for the next period
+func (c *Controller) Trigger() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run syncs the merge queues periodically until stop is closed
+func (c *Controller) Run(period time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-c.trigger:
+		}
+		c.Sync()
+	}
+}
+
+// Sync updates the merge queues and merges the ready pull requests
+func (c *Controller) Sync() {
+	ctx := context.Background()
+
+	// take the incoming pull requests
+	c.lock.Lock()
+	repos := make([]string, 0, len(c.repos))
+	for name := range c.repos {
+		repos = append(repos, name)
+	}
+	incoming := c.incoming
+	c.incoming = make(map[string][]int)
+	c.lock.Unlock()
+	sort.Strings(repos)
+
+	for _, name := range repos {
+		strs := strings.Split(name, "/")
+		org, repo := strs[0], strs[1]
+		cfg := c.ConfigAgent.Config().RepoConfigFor(org, repo)
+
+		// find the pull requests which were enqueued before a restart
+		numbers := append(incoming[name], c.search(ctx, cfg, org, repo)...)
+		for _, number := range numbers {
+			c.add(ctx, org, repo, number)
+		}
+	}
+
+	keys := make([]string, 0, len(c.pools))
+	for key := range c.pools {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p := c.pools[key]
+		cfg := c.ConfigAgent.Config().RepoConfigFor(p.org, p.repo)
+		c.syncPool(ctx, cfg, p)
+		if len(p.numbers) == 0 {
+			delete(c.pools, key)
+		}
+	}
+}
+
+// search returns the open pull requests which have approved and lgtm label
+func (c *Controller) search(ctx context.Context, cfg config.RepoConfig, org string, repo string) []int {
+	query := fmt.Sprintf("repo:%s/%s is:pr is:open label:%q label:%q", org, repo, cfg.Labels.Approved, cfg.Labels.Lgtm)
+	result, _, err := c.GithubClient.Search.Issues(ctx, query, &github.SearchOptions{Sort: "created", Order: "asc"})
+	if err != nil {
+		glog.Errorf("Unable to search prs: %s err: %v", query, err)
+		return nil
+	}
+	numbers := make([]int, 0)
+	for _, issue := range result.Issues {
+		numbers = append(numbers, issue.GetNumber())
+	}
+	return numbers
+}
+
+// add puts a pull request into the pool of its base branch
+func (c *Controller) add(ctx context.Context, org string, repo string, number int) {
+	for _, p := range c.pools {
+		if p.org == org && p.repo == repo && p.contains(number) {
+			return
+		}
+	}
+
+	pr, _, err := c.GithubClient.PullRequests.Get(ctx, org, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: %s/%s#%d err: %v", org, repo, number, err)
+		return
+	}
+	branch := pr.GetBase().GetRef()
+	key := fmt.Sprintf("%s/%s/%s", org, repo, branch)
+	p, ok := c.pools[key]
+	if !ok {
+		p = &pool{
+			org:    org,
+			repo:   repo,
+			branch: branch,
+			tested: make(map[int]update),
+		}
+		c.pools[key] = p
+	}
+	glog.Infof("Add pr #%d into merge pool %s", number, key)
+	p.numbers = append(p.numbers, number)
+}
+
+// syncPool checks the pull requests in order and merges the first ready one
+func (c *Controller) syncPool(ctx context.Context, cfg config.RepoConfig, p *pool) {
+	glog.Infof("Sync merge pool %s/%s/%s: %v", p.org, p.repo, p.branch, p.numbers)
+
+	// the head of the base branch
+	branch, _, err := c.GithubClient.Repositories.GetBranch(ctx, p.org, p.repo, p.branch)
+	if err != nil {
+		glog.Errorf("Unable to get branch: %s err: %v", p.branch, err)
+		return
+	}
+	baseSHA := branch.GetCommit().GetSHA()
+	protection, err := util.BranchProtection(c.GithubClient, p.org, p.repo, p.branch)
+	if err != nil {
+		return
+	}
+	strict := util.RequiresUpToDate(protection)
+
+	// only one pull request is updated or merged at a time
+	busy := false
+	for _, number := range append([]int{}, p.numbers...) {
+		pr, _, err := c.GithubClient.PullRequests.Get(ctx, p.org, p.repo, number)
+		if err != nil {
+			glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+			continue
+		}
+		// merged or closed
+		if pr.GetState() != "open" || pr.GetBase().GetRef() != p.branch {
+			glog.Infof("Remove pr #%d from merge pool", number)
+			c.remove(p, number)
+			continue
+		}
+
+		reasons := c.blockers(cfg, p, pr, protection)
+		if len(reasons) == 0 {
+			c.checkReported(ctx, p, pr)
+		}
+		if len(reasons) == 0 && !p.upToDate(pr, baseSHA, strict) {
+			if busy {
+				reasons = append(reasons, "Waiting for the pull requests ahead in the merge pool")
+			} else if u, ok := p.updating(pr, baseSHA); ok {
+				// a failed update does not block the pull requests behind
+				busy = u.failed == ""
+				reasons = append(reasons, u.reason(p.branch))
+			} else {
+				reasons = append(reasons, c.update(ctx, cfg, p, pr, baseSHA, strict))
+				busy = p.tested[number].failed == ""
+			}
+		}
+		if len(reasons) == 0 {
+			if busy {
+				reasons = append(reasons, "Waiting for the pull requests ahead in the merge pool")
+			} else {
+				busy = true
+				c.setStatus(ctx, cfg, p, pr, nil)
+				err = util.MergePullRequest(c.GithubClient, cfg, p.org, p.repo, number)
+				if err != nil {
+					glog.Errorf("Unable to merge pr: #%d err: %v", number, err)
+					reasons = append(reasons, fmt.Sprintf("Merge failed: %v", err))
+				} else {
+					c.remove(p, number)
+					continue
+				}
+			}
+		}
+		c.setStatus(ctx, cfg, p, pr, reasons)
+	}
+}
+
+// blockers returns the reasons why the pull request can not be merged.
+// The protection is nil if the base branch is not protected.
+func (c *Controller) blockers(cfg config.RepoConfig, p *pool, pr *github.PullRequest, protection *github.Protection) []string {
+	// labels
+	reasons := util.LabelBlockers(cfg, pr.Labels)
+
+	// mergeability
+	if pr.Mergeable == nil {
+		reasons = append(reasons, "Mergeability is being computed")
+	} else if !pr.GetMergeable() {
+		reasons = append(reasons, "Merge conflicts with the base branch")
+	}
+
+	// status checks and check runs
+	states, err := util.CheckStates(c.GithubClient, c.org(pr), c.repo(pr), pr.GetHead().GetSHA())
+	if err != nil {
+		return append(reasons, "Unable to get the status checks")
+	}
+	reasons = append(reasons, statusBlockers(cfg, protection, states, p.tested[pr.GetNumber()].contexts)...)
+
+	// branch protection
+	var protected []string
+	if protection != nil {
+		protected, err = util.ProtectionBlockers(c.GithubClient, cfg, c.org(pr), c.repo(pr), pr, protection, states)
+		if err != nil {
+			return append(reasons, "Unable to check the branch protection")
+		}
+		reasons = append(reasons, protected...)
+	}
+	c.notify(p, pr, protected)
+	return reasons
+}
+
+// statusBlockers returns the status checks which are not success,
+// except the ones required by the branch protection which are reported by util.ProtectionBlockers.
+// Unless the contexts are configured, the reported ones and the expected ones are required.
+func statusBlockers(cfg config.RepoConfig, protection *github.Protection, states map[string]string, expected []string) []string {
+	protected := make(map[string]bool)
+	if protection != nil && protection.RequiredStatusChecks != nil {
+		for _, context := range protection.RequiredStatusChecks.Contexts {
+			protected[context] = true
+		}
+	}
+	// skip the status of the merge queue
+	protected[cfg.Tide.StatusContext] = true
+
+	required := cfg.Tide.RequiredContexts
+	if len(required) == 0 {
+		seen := make(map[string]bool)
+		for context := range states {
+			seen[context] = true
+		}
+		// the merged head has no statuses until the ci starts
+		for _, context := range expected {
+			seen[context] = true
+		}
+		for context := range seen {
+			required = append(required, context)
+		}
+		sort.Strings(required)
+	}
+
+	pending := make([]string, 0)
+	failed := make([]string, 0)
+	for _, context := range required {
+		if protected[context] {
+			continue
+		}
+		switch states[context] {
+		case util.StateSuccess:
+		case util.StateFailure:
+			failed = append(failed, context)
+		default:
+			pending = append(pending, context)
+		}
+	}
+
+	reasons := make([]string, 0)
+	if len(failed) > 0 {
+		reasons = append(reasons, fmt.Sprintf("Failed status checks: %s", strings.Join(failed, ", ")))
+	}
+	if len(pending) > 0 {
+		reasons = append(reasons, fmt.Sprintf("Waiting for status checks: %s", strings.Join(pending, ", ")))
+	}
+	return reasons
+}
+
+// notify explains the unsatisfied requirements of the branch protection in a comment when they are changed
+func (c *Controller) notify(p *pool, pr *github.PullRequest, reasons []string) {
+	key := fmt.Sprintf("%s/%s#%d", p.org, p.repo, pr.GetNumber())
+	notice := strings.Join(reasons, "\n")
+	if last, ok := c.notices[key]; ok && last == notice {
+		return
+	}
+	err := util.NotifyProtection(c.GithubClient, p.org, p.repo, pr.GetNumber(), p.branch, reasons)
+	if err != nil {
+		return
+	}
+	c.notices[key] = notice
+}
+
+// update retests the pull request or merges the latest base branch into it.
+// It is always merged if the branch protection requires it to be up to date, because a retest does not update it.
+func (c *Controller) update(ctx context.Context, cfg config.RepoConfig, p *pool, pr *github.PullRequest, baseSHA string, strict bool) string {
+	number := pr.GetNumber()
+	contexts := p.tested[number].contexts
+	delete(p.tested, number)
+	if cfg.Tide.UpdateMethod == config.UpdateMethodMerge || strict {
+		states, err := util.CheckStates(c.GithubClient, c.org(pr), c.repo(pr), pr.GetHead().GetSHA())
+		if err != nil {
+			return "Unable to get the status checks"
+		}
+		reason := c.mergeBase(ctx, p, pr)
+		if reason != "" {
+			p.tested[number] = update{base: baseSHA, head: pr.GetHead().GetSHA(), failed: reason, contexts: contexts}
+			return reason
+		}
+		p.tested[number] = update{base: baseSHA, contexts: mergeContexts(cfg, contexts, states)}
+		return p.tested[number].reason(p.branch)
+	}
+
+	provider, err := retest.NewCIProvider(c.GithubClient, cfg)
+	if err == nil {
+		err = provider.RetestAll(retest.NewPullRequest(pr))
+	}
+	if err != nil {
+		glog.Errorf("Unable to retest pr: #%d err: %v", number, err)
+		return fmt.Sprintf("Unable to retest against the latest %s", p.branch)
+	}
+	p.tested[number] = update{base: baseSHA, head: pr.GetHead().GetSHA(), at: time.Now(), contexts: contexts}
+	return p.tested[number].reason(p.branch)
+}
+
+// mergeContexts returns the sorted contexts and the reported ones, except the status of the merge queue
+func mergeContexts(cfg config.RepoConfig, contexts []string, states map[string]string) []string {
+	seen := make(map[string]bool)
+	for _, context := range contexts {
+		seen[context] = true
+	}
+	for context := range states {
+		seen[context] = true
+	}
+	delete(seen, cfg.Tide.StatusContext)
+	list := make([]string, 0, len(seen))
+	for context := range seen {
+		list = append(list, context)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// mergeBase merges the base branch into the head branch of the pull request, like the update branch button.
+// It returns why the head branch can not be updated, or empty if the update is started.
+func (c *Controller) mergeBase(ctx context.Context, p *pool, pr *github.PullRequest) string {
+	number := pr.GetNumber()
+	manually := fmt.Sprintf("Needs to be updated with the latest %s manually", p.branch)
+	// ci-bot can only push to the forks which allow the edits from the maintainers
+	if pr.GetHead().GetRepo().GetFullName() != pr.GetBase().GetRepo().GetFullName() && !pr.GetMaintainerCanModify() {
+		glog.Infof("Pr #%d does not allow the edits from the maintainers", number)
+		return manually
+	}
+
+	u := fmt.Sprintf("repos/%s/%s/pulls/%d/update-branch", p.org, p.repo, number)
+	body := map[string]string{"expected_head_sha": pr.GetHead().GetSHA()}
+	req, err := c.GithubClient.NewRequest("PUT", u, body)
+	if err != nil {
+		return fmt.Sprintf("Unable to update with the latest %s", p.branch)
+	}
+	req.Header.Set("Accept", mediaTypeUpdateBranch)
+	resp, err := c.GithubClient.Do(ctx, req, nil)
+	// the update is accepted and done in the background
+	if _, ok := err.(*github.AcceptedError); ok {
+		err = nil
+	}
+	if err != nil {
+		glog.Errorf("Unable to update pr: #%d err: %v", number, err)
+		// e.g. the bot can not push to the head branch, or the merge conflicts
+		if resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnprocessableEntity) {
+			return manually
+		}
+		return fmt.Sprintf("Unable to update with the latest %s", p.branch)
+	}
+	glog.Infof("Merge %s into pr #%d", p.branch, number)
+	return ""
+}
+
+// checkReported marks the retest of the pull request as reported when a status is reported after it
+func (c *Controller) checkReported(ctx context.Context, p *pool, pr *github.PullRequest) {
+	u, ok := p.tested[pr.GetNumber()]
+	if !ok || u.reported || u.head == "" || u.head != pr.GetHead().GetSHA() {
+		return
+	}
+	last, err := util.LastReported(c.GithubClient, c.org(pr), c.repo(pr), u.head)
+	if err != nil {
+		return
+	}
+	if last.After(u.at) {
+		u.reported = true
+		p.tested[pr.GetNumber()] = u
+	}
+}
+
+// setStatus posts the status which explains why the pull request is not merging yet
+func (c *Controller) setStatus(ctx context.Context, cfg config.RepoConfig, p *pool, pr *github.PullRequest, reasons []string) {
+	state := "success"
+	description := descriptionInPool
+	if len(reasons) > 0 {
+		state = "pending"
+		description = fmt.Sprintf("Not mergeable. %s.", strings.Join(reasons, ". "))
+	}
+	if len(description) > maxDescriptionLength {
+		description = description[:maxDescriptionLength-3] + "..."
+	}
+
+	// skip if the status is not changed
+	sha := pr.GetHead().GetSHA()
+	key := fmt.Sprintf("%s/%s#%d", p.org, p.repo, pr.GetNumber())
+	if c.statuses[key] == sha+description {
+		return
+	}
+	status := &github.RepoStatus{
+		State:       github.String(state),
+		Description: github.String(description),
+		Context:     github.String(cfg.Tide.StatusContext),
+	}
+	_, _, err := c.GithubClient.Repositories.CreateStatus(ctx, p.org, p.repo, sha, status)
+	if err != nil {
+		glog.Errorf("Unable to create status: #%d err: %v", pr.GetNumber(), err)
+		return
+	}
+	c.statuses[key] = sha + description
+}
+
+// remove removes the pull request from the pool, and deletes its branch protection comment which is out of date
+func (c *Controller) remove(p *pool, number int) {
+	p.remove(number)
+	delete(c.statuses, fmt.Sprintf("%s/%s#%d", p.org, p.repo, number))
+	delete(c.notices, fmt.Sprintf("%s/%s#%d", p.org, p.repo, number))
+	err := util.NotifyProtection(c.GithubClient, p.org, p.repo, number, p.branch, nil)
+	if err != nil {
+		glog.Errorf("Unable to delete the branch protection comment of pr #%d. err: %v", number, err)
+	}
+}
+
+// org returns the org of the base repository
+func (c *Controller) org(pr *github.PullRequest) string {
+	return pr.GetBase().GetRepo().GetOwner().GetLogin()
+}
+
+// repo returns the name of the base repository
+func (c *Controller) repo(pr *github.PullRequest) string {
+	return pr.GetBase().GetRepo().GetName()
+}
+
+// contains checks if the pull request is in the pool
+func (p *pool) contains(number int) bool {
+	for _, n := range p.numbers {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes the pull request from the pool
+func (p *pool) remove(number int) {
+	numbers := make([]int, 0, len(p.numbers))
+	for _, n := range p.numbers {
+		if n != number {
+			numbers = append(numbers, n)
+		}
+	}
+	p.numbers = numbers
+	delete(p.tested, number)
+}
+
+// upToDate checks if the pull request is tested against the latest base branch.
+// A retest counts when the statuses are reported after it, and it is not enough
+// if the branch protection requires the pull request to be up to date.
+func (p *pool) upToDate(pr *github.PullRequest, baseSHA string, strict bool) bool {
+	u, ok := p.tested[pr.GetNumber()]
+	if ok && !strict && u.failed == "" && u.base == baseSHA && u.head == pr.GetHead().GetSHA() && u.reported {
+		return true
+	}
+	return pr.GetMergeableState() != "behind" && pr.GetBase().GetSHA() == baseSHA
+}
+
+// updating returns the update of the pull request to the base sha which is in progress or failed
+func (p *pool) updating(pr *github.PullRequest, baseSHA string) (update, bool) {
+	u, ok := p.tested[pr.GetNumber()]
+	if !ok || u.base != baseSHA {
+		return u, false
+	}
+	// a merge is in progress until github updates the pull request
+	return u, u.head == "" || u.head == pr.GetHead().GetSHA()
+}
+
+// reason explains the update in the status of the pull request
+func (u update) reason(branch string) string {
+	switch {
+	case u.failed != "":
+		return u.failed
+	case u.head == "":
+		return fmt.Sprintf("Updating with the latest %s", branch)
+	}
+	return fmt.Sprintf("Retesting against the latest %s", branch)
+}
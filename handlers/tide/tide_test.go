@@ -0,0 +1,429 @@
+package tide
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+// fakeGithub serves the github APIs which are used by the merge controller
+type fakeGithub struct {
+	lock     sync.Mutex
+	baseSHA  string
+	prs      map[int]*github.PullRequest
+	statuses map[string][]github.RepoStatus
+	posted   map[string]string
+	merged   []int
+	// requests are the merge requests by the pr numbers
+	requests map[int]map[string]string
+	// protection of the master branch, it is not protected if it is nil
+	protection *github.Protection
+	checkRuns  map[string][]*github.CheckRun
+	reviews    map[int][]*github.PullRequestReview
+	comments   map[int][]*github.IssueComment
+	// updated are the prs whose head branches are updated with the base branch
+	updated []int
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var number int
+	var id int64
+	var sha string
+	path := r.URL.Path
+	switch {
+	case path == "/user":
+		json.NewEncoder(w).Encode(github.User{Login: github.String("ci-bot")})
+	case path == "/search/issues":
+		result := github.IssuesSearchResult{}
+		for n := range f.prs {
+			result.Issues = append(result.Issues, github.Issue{Number: github.Int(n)})
+		}
+		json.NewEncoder(w).Encode(result)
+	case path == "/repos/test/hello/branches/master":
+		json.NewEncoder(w).Encode(github.Branch{Commit: &github.RepositoryCommit{SHA: github.String(f.baseSHA)}})
+	case path == "/repos/test/hello/branches/master/protection":
+		if f.protection == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(f.protection)
+	case strings.HasSuffix(path, "/update-branch"):
+		fmt.Sscanf(path, "/repos/test/hello/pulls/%d/update-branch", &number)
+		f.updated = append(f.updated, number)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{}`))
+	case strings.HasSuffix(path, "/merge"):
+		fmt.Sscanf(path, "/repos/test/hello/pulls/%d/merge", &number)
+		request := make(map[string]string)
+		json.NewDecoder(r.Body).Decode(&request)
+		if f.requests == nil {
+			f.requests = make(map[int]map[string]string)
+		}
+		f.requests[number] = request
+		f.merged = append(f.merged, number)
+		f.prs[number].State = github.String("closed")
+		json.NewEncoder(w).Encode(github.PullRequestMergeResult{Merged: github.Bool(true)})
+	case strings.HasSuffix(path, "/comments"):
+		fmt.Sscanf(path, "/repos/test/hello/issues/%d/comments", &number)
+		if r.Method == http.MethodPost {
+			var ic github.IssueComment
+			json.NewDecoder(r.Body).Decode(&ic)
+			ic.ID = github.Int64(int64(len(f.comments[number]) + 1))
+			ic.User = &github.User{Login: github.String("ci-bot")}
+			f.comments[number] = append(f.comments[number], &ic)
+			json.NewEncoder(w).Encode(ic)
+			return
+		}
+		json.NewEncoder(w).Encode(f.comments[number])
+	case strings.HasPrefix(path, "/repos/test/hello/issues/comments/"):
+		fmt.Sscanf(path, "/repos/test/hello/issues/comments/%d", &id)
+		for n, list := range f.comments {
+			for i, ic := range list {
+				if ic.GetID() == id {
+					f.comments[n] = append(list[:i], list[i+1:]...)
+					break
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(path, "/reviews"):
+		fmt.Sscanf(path, "/repos/test/hello/pulls/%d/reviews", &number)
+		json.NewEncoder(w).Encode(f.reviews[number])
+	case strings.HasSuffix(path, "/check-runs"):
+		fmt.Sscanf(path, "/repos/test/hello/commits/%s", &sha)
+		sha = strings.TrimSuffix(sha, "/check-runs")
+		// one check run per page
+		page := 1
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		runs := f.checkRuns[sha]
+		if page < len(runs) {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`, r.Host, path, page+1))
+		}
+		if page <= len(runs) {
+			runs = runs[page-1 : page]
+		}
+		json.NewEncoder(w).Encode(github.ListCheckRunsResults{CheckRuns: runs})
+	case strings.HasSuffix(path, "/labels"):
+		fmt.Sscanf(path, "/repos/test/hello/issues/%d/labels", &number)
+		json.NewEncoder(w).Encode(f.prs[number].Labels)
+	case strings.HasPrefix(path, "/repos/test/hello/pulls/"):
+		fmt.Sscanf(path, "/repos/test/hello/pulls/%d", &number)
+		json.NewEncoder(w).Encode(f.prs[number])
+	case strings.HasSuffix(path, "/status"):
+		fmt.Sscanf(path, "/repos/test/hello/commits/%s", &sha)
+		sha = strings.TrimSuffix(sha, "/status")
+		json.NewEncoder(w).Encode(github.CombinedStatus{Statuses: f.statuses[sha]})
+	case strings.HasPrefix(path, "/repos/test/hello/statuses/"):
+		var status github.RepoStatus
+		json.NewDecoder(r.Body).Decode(&status)
+		f.posted[strings.TrimPrefix(path, "/repos/test/hello/statuses/")] = status.GetDescription()
+		json.NewEncoder(w).Encode(status)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newPR returns an open pull request
+func newPR(number int, baseSHA string, labels ...string) *github.PullRequest {
+	pr := &github.PullRequest{
+		Number:    github.Int(number),
+		State:     github.String("open"),
+		Title:     github.String(fmt.Sprintf("pr %d", number)),
+		Mergeable: github.Bool(true),
+		Head:      &github.PullRequestBranch{SHA: github.String(fmt.Sprintf("head%d", number))},
+		Base: &github.PullRequestBranch{
+			Ref: github.String("master"),
+			SHA: github.String(baseSHA),
+			Repo: &github.Repository{
+				Name:  github.String("hello"),
+				Owner: &github.User{Login: github.String("test")},
+			},
+		},
+	}
+	for _, l := range labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l)})
+	}
+	return pr
+}
+
+// success returns a success status
+func success(context string) github.RepoStatus {
+	return github.RepoStatus{Context: github.String(context), State: github.String("success")}
+}
+
+//TestSync tests that the merge controller merges one ready pr at a time
+func TestSync(t *testing.T) {
+	f := &fakeGithub{
+		baseSHA: "base1",
+		prs: map[int]*github.PullRequest{
+			1: newPR(1, "base1", "approved", "lgtm"),
+			2: newPR(2, "base1", "approved", "lgtm"),
+			3: newPR(3, "base1", "approved"),
+			4: newPR(4, "base1", "approved", "lgtm"),
+		},
+		statuses: map[string][]github.RepoStatus{
+			"head1": {success("build")},
+			"head2": {success("build")},
+			"head3": {success("build")},
+			"head4": {{Context: github.String("build"), State: github.String("pending")}},
+		},
+		posted:   make(map[string]string),
+		comments: make(map[int][]*github.IssueComment),
+	}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	c := newController(t, server)
+	c.Enqueue("test", "hello", 1)
+	c.Sync()
+
+	// only one pr is merged at a time
+	if len(f.merged) != 1 || f.merged[0] != 1 {
+		t.Fatalf("merged = %v, want [1]", f.merged)
+	}
+	if got := f.requests[1]; got["commit_title"] != "pr 1 (#1)" || got["merge_method"] != config.MergeMethodMerge {
+		t.Errorf("merge request = %v, want the default commit title and merge method", got)
+	}
+	if got := f.posted["head2"]; !strings.Contains(got, "Waiting for the pull requests ahead") {
+		t.Errorf("status of #2 = %q, want waiting", got)
+	}
+	if got := f.posted["head3"]; !strings.Contains(got, "Needs lgtm label") {
+		t.Errorf("status of #3 = %q, want needs lgtm", got)
+	}
+	if got := f.posted["head4"]; !strings.Contains(got, "Waiting for status checks: build") {
+		t.Errorf("status of #4 = %q, want waiting for status checks", got)
+	}
+
+	// the base branch moved after the merge so #2 is retested before merging
+	f.baseSHA = "base2"
+	f.prs[2].Base.SHA = github.String("base1")
+	retested := time.Now()
+	before, after := retested.Add(-time.Minute), retested.Add(time.Minute)
+	f.statuses["head2"][0].UpdatedAt = &before
+	c.pools["test/hello/master"].tested[2] = update{base: "base2", head: "head2", at: retested}
+	c.Sync()
+	if len(f.merged) != 1 {
+		t.Fatalf("merged = %v, want #2 waiting for the statuses of the retest", f.merged)
+	}
+	if got := f.posted["head2"]; !strings.Contains(got, "Retesting against the latest master") {
+		t.Errorf("status of #2 = %q, want retesting", got)
+	}
+	f.statuses["head2"][0].UpdatedAt = &after
+	c.Sync()
+	if len(f.merged) != 2 || f.merged[1] != 2 {
+		t.Fatalf("merged = %v, want [1 2]", f.merged)
+	}
+}
+
+//TestUpdateBranch tests that the base branch is merged into the prs which allow it
+func TestUpdateBranch(t *testing.T) {
+	f := &fakeGithub{
+		baseSHA: "base2",
+		prs: map[int]*github.PullRequest{
+			1: newPR(1, "base1", "approved", "lgtm"),
+			2: newPR(2, "base1", "approved", "lgtm"),
+		},
+		statuses: map[string][]github.RepoStatus{
+			"head1": {success("build")},
+			"head2": {success("build")},
+		},
+		posted:   make(map[string]string),
+		comments: make(map[int][]*github.IssueComment),
+	}
+	// #1 is from a fork which does not allow the edits from the maintainers
+	f.prs[1].Head.Repo = &github.Repository{FullName: github.String("someone/hello")}
+	f.prs[2].Head.Repo = &github.Repository{FullName: github.String("test/hello")}
+	f.prs[2].Base.Repo.FullName = github.String("test/hello")
+	server := httptest.NewServer(f)
+	defer server.Close()
+	c := newController(t, server)
+	c.ConfigAgent, _ = config.NewAgent(func() (*config.Config, error) {
+		return &config.Config{RepoConfig: config.RepoConfig{Tide: config.Tide{UpdateMethod: config.UpdateMethodMerge}}}, nil
+	})
+	c.Enqueue("test", "hello", 1)
+	c.Enqueue("test", "hello", 2)
+	c.Sync()
+
+	if len(f.merged) != 0 || len(f.updated) != 1 || f.updated[0] != 2 {
+		t.Fatalf("merged = %v, updated = %v, want #2 updated", f.merged, f.updated)
+	}
+	if got := f.posted["head1"]; !strings.Contains(got, "Needs to be updated with the latest master manually") {
+		t.Errorf("status of #1 = %q, want updated manually", got)
+	}
+	if got := f.posted["head2"]; !strings.Contains(got, "Updating with the latest master") {
+		t.Errorf("status of #2 = %q, want updating", got)
+	}
+
+	// the update is not repeated until github updates the pr
+	c.Sync()
+	if len(f.updated) != 1 {
+		t.Errorf("updated = %v, want #2 updated once", f.updated)
+	}
+
+	// the merged head is not merged until the statuses of the previous head are reported on it
+	f.prs[2].Head.SHA = github.String("merged2")
+	f.prs[2].Base.SHA = github.String("base2")
+	c.Sync()
+	if len(f.merged) != 0 {
+		t.Fatalf("merged = %v, want #2 waiting for the statuses of the merged head", f.merged)
+	}
+	if got := f.posted["merged2"]; !strings.Contains(got, "Waiting for status checks: build") {
+		t.Errorf("status of #2 = %q, want waiting for status checks", got)
+	}
+	f.statuses["merged2"] = []github.RepoStatus{success("build")}
+	c.Sync()
+	if len(f.merged) != 1 || f.merged[0] != 2 {
+		t.Errorf("merged = %v, want [2]", f.merged)
+	}
+}
+
+//TestSyncProtection tests that the merge controller holds the merge until the branch protection is satisfied
+func TestSyncProtection(t *testing.T) {
+	f := &fakeGithub{
+		baseSHA: "base1",
+		prs: map[int]*github.PullRequest{
+			1: newPR(1, "base1", "approved", "lgtm"),
+		},
+		statuses: map[string][]github.RepoStatus{
+			"head1": {success("build")},
+		},
+		protection: &github.Protection{
+			RequiredStatusChecks:       &github.RequiredStatusChecks{Contexts: []string{"build", "e2e", "lint"}},
+			RequiredPullRequestReviews: &github.PullRequestReviewsEnforcement{RequiredApprovingReviewCount: 1},
+		},
+		checkRuns: map[string][]*github.CheckRun{
+			"head1": {
+				{Name: github.String("e2e"), Status: github.String("in_progress")},
+				{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("success")},
+			},
+		},
+		posted:   make(map[string]string),
+		comments: make(map[int][]*github.IssueComment),
+	}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	c := newController(t, server)
+	c.Enqueue("test", "hello", 1)
+	c.Sync()
+
+	if len(f.merged) != 0 {
+		t.Fatalf("merged = %v, want none", f.merged)
+	}
+	// the check runs of all the pages are counted
+	if got := f.posted["head1"]; !strings.Contains(got, "Waiting for required status checks: e2e") || strings.Contains(got, "lint") {
+		t.Errorf("status of #1 = %q, want waiting for required status checks", got)
+	}
+	if len(f.comments[1]) != 1 {
+		t.Fatalf("comments = %d, want 1", len(f.comments[1]))
+	}
+	body := f.comments[1][0].GetBody()
+	for _, want := range []string{"Waiting for required status checks: e2e", "Needs 1 approving reviews, has 0"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("comment = %q, want %q", body, want)
+		}
+	}
+
+	// the check run completed and the pr got an approving review
+	f.checkRuns["head1"][0].Status = github.String("completed")
+	f.checkRuns["head1"][0].Conclusion = github.String("success")
+	f.reviews = map[int][]*github.PullRequestReview{
+		1: {{User: &github.User{Login: github.String("alice")}, State: github.String("APPROVED")}},
+	}
+	c.Sync()
+	if len(f.merged) != 1 || f.merged[0] != 1 {
+		t.Fatalf("merged = %v, want [1]", f.merged)
+	}
+	if len(f.comments[1]) != 0 {
+		t.Errorf("comments = %d, want the branch protection comment deleted", len(f.comments[1]))
+	}
+}
+
+//TestRemoveProtection tests that the branch protection comment of ci-bot is deleted when the pr leaves the pool
+func TestRemoveProtection(t *testing.T) {
+	forged := util.ProtectionMarker + "\nforged"
+	f := &fakeGithub{
+		baseSHA: "base1",
+		prs: map[int]*github.PullRequest{
+			1: newPR(1, "base1", "approved", "lgtm"),
+		},
+		statuses: map[string][]github.RepoStatus{},
+		protection: &github.Protection{
+			RequiredStatusChecks: &github.RequiredStatusChecks{Contexts: []string{"build"}},
+		},
+		posted: make(map[string]string),
+		comments: map[int][]*github.IssueComment{
+			1: {{ID: github.Int64(100), Body: github.String(forged), User: &github.User{Login: github.String("mallory")}}},
+		},
+	}
+	server := httptest.NewServer(f)
+	defer server.Close()
+	c := newController(t, server)
+	c.Enqueue("test", "hello", 1)
+	c.Sync()
+
+	// the comment of another user is not edited
+	if len(f.comments[1]) != 2 || f.comments[1][0].GetBody() != forged ||
+		!strings.Contains(f.comments[1][1].GetBody(), "Waiting for required status checks: build") {
+		t.Fatalf("comments = %v, want the forged comment and the branch protection comment", f.comments[1])
+	}
+
+	f.prs[1].State = github.String("closed")
+	c.Sync()
+	if len(f.comments[1]) != 1 || f.comments[1][0].GetBody() != forged {
+		t.Errorf("comments = %v, want only the forged comment", f.comments[1])
+	}
+}
+
+// newController returns a merge controller of the fake github
+func newController(t *testing.T, server *httptest.Server) *Controller {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	configAgent, err := config.NewAgent(func() (*config.Config, error) {
+		return &config.Config{}, nil
+	})
+	if err != nil {
+		t.Fatalf("NewAgent() error = %v", err)
+	}
+	return NewController(client, configAgent)
+}
+
+//TestUpToDate tests if a pr is tested against the latest base branch
+func TestUpToDate(t *testing.T) {
+	p := &pool{tested: map[int]update{
+		2: {base: "base2", head: "head2", reported: true},
+		3: {base: "base2", head: "head3"},
+	}}
+
+	tests := []struct {
+		name   string
+		pr     *github.PullRequest
+		strict bool
+		want   bool
+	}{
+		{name: "same base", pr: newPR(1, "base2"), want: true},
+		{name: "base moved", pr: newPR(1, "base1"), want: false},
+		{name: "retested", pr: newPR(2, "base1"), want: true},
+		{name: "retested but not reported", pr: newPR(3, "base1"), want: false},
+		{name: "retested but protection requires up to date", pr: newPR(2, "base1"), strict: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.upToDate(tt.pr, "base2", tt.strict); got != tt.want {
+				t.Errorf("upToDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
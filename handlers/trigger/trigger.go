@@ -0,0 +1,128 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/retest"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+var (
+	// RegOkToTest is the regular expression of /ok-to-test
+	RegOkToTest = regexp.MustCompile(`(?mi)^/ok-to-test\s*$`)
+)
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "trigger",
+		Help: "The prs of the authors who are not org members or collaborators get the " + config.LabelNeedsOkToTest +
+			" label, and their CI jobs are not run until a trusted member comments /ok-to-test.",
+		Commands: []*regexp.Regexp{RegOkToTest},
+		IssueCommentHandler: func(agent plugins.Agent, event github.IssueCommentEvent) error {
+			return HandleOkToTest(agent, event)
+		},
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePullRequest(agent, event)
+		},
+		PullRequestActions: []string{plugins.ActionOpened, plugins.ActionReopened, plugins.ActionSynchronize},
+	})
+}
+
+// HandlePullRequest adds the needs-ok-to-test label if the author is not trusted
+func HandlePullRequest(agent plugins.Agent, event github.PullRequestEvent) error {
+	client := agent.GithubClient
+	pr := event.GetPullRequest()
+	org := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := pr.GetNumber()
+	author := pr.GetUser().GetLogin()
+
+	// the new commits need /ok-to-test again only if it is configured
+	if event.GetAction() == plugins.ActionSynchronize && !agent.Config.Trigger.GetReapproveOnPush() {
+		return nil
+	}
+	if util.HasLabel(pr.Labels, config.LabelNeedsOkToTest) {
+		return nil
+	}
+	trusted, err := util.IsTrusted(client, agent.Config, org, repo, author)
+	if err != nil {
+		return err
+	}
+	if trusted {
+		return nil
+	}
+
+	glog.Infof("Pr #%d of the untrusted author %s needs ok-to-test", number, author)
+	ctx := context.Background()
+	_, _, err = client.Issues.AddLabelsToIssue(ctx, org, repo, number, []string{config.LabelNeedsOkToTest})
+	if err != nil {
+		glog.Errorf("Unable to add label: %s err: %v", config.LabelNeedsOkToTest, err)
+		return err
+	}
+	reply := fmt.Sprintf("Hi @%s. Thanks for your PR.\n\n"+
+		"I'm waiting for a member of %s or a collaborator of %s to verify that this patch is reasonable to test. "+
+		"Once it is verified, the member will comment `/ok-to-test` and the CI jobs will run.", author, org, repo)
+	if event.GetAction() == plugins.ActionSynchronize {
+		reply = fmt.Sprintf("@%s pushed new commits. A member of %s or a collaborator of %s needs to comment `/ok-to-test` again to run the CI jobs.", author, org, repo)
+	}
+	_, _, err = client.Issues.CreateComment(ctx, org, repo, number, &github.IssueComment{Body: github.String(reply)})
+	if err != nil {
+		glog.Errorf("Unable to comment on pr #%d err: %v", number, err)
+		return err
+	}
+	return nil
+}
+
+// HandleOkToTest removes the needs-ok-to-test label and runs the CI jobs if the commenter is trusted
+func HandleOkToTest(agent plugins.Agent, event github.IssueCommentEvent) error {
+	if !event.Issue.IsPullRequest() || event.Issue.GetState() != "open" {
+		return nil
+	}
+	client := agent.GithubClient
+	org := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := event.Issue.GetNumber()
+	commenter := event.Comment.GetUser().GetLogin()
+	ctx := context.Background()
+
+	trusted, err := util.IsTrusted(client, agent.Config, org, repo, commenter)
+	if err != nil {
+		return err
+	}
+	if !trusted {
+		glog.Infof("%s is not trusted to ok-to-test pr #%d", commenter, number)
+		return agent.Respond(event, fmt.Sprintf("only the members of %s and the collaborators of %s can comment `/ok-to-test`.", org, repo))
+	}
+
+	resp, err := client.Issues.RemoveLabelForIssue(ctx, org, repo, number, config.LabelNeedsOkToTest)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		glog.Errorf("Unable to remove label: %s err: %v", config.LabelNeedsOkToTest, err)
+		return err
+	}
+
+	// run the jobs which were skipped
+	pr, _, err := client.PullRequests.Get(ctx, org, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return err
+	}
+	provider, err := retest.NewCIProvider(client, agent.Config)
+	if err != nil {
+		glog.Errorf("Unable to create CI provider: %v", err)
+		return err
+	}
+	err = provider.RetestAll(retest.NewPullRequest(pr))
+	if err != nil {
+		// the CI provider may not have run the jobs yet, they can be run by /retest later
+		glog.Errorf("Unable to run the CI jobs of pr #%d: %v", number, err)
+	}
+	return nil
+}
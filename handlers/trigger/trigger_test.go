@@ -0,0 +1,156 @@
+package trigger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/fakegithub"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+)
+
+// fakeGithub serves the membership APIs and records the changes of the pr
+type fakeGithub struct {
+	fakegithub.Labels
+	lock     sync.Mutex
+	members  map[string]bool
+	comments []string
+	reruns   int
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.Serve(w, r) {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/orgs/test/members/"), strings.HasPrefix(path, "/repos/test/hello/collaborators/"):
+		if f.members[path[strings.LastIndex(path, "/")+1:]] {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case path == "/repos/test/hello/issues/1/comments":
+		var comment github.IssueComment
+		json.NewDecoder(r.Body).Decode(&comment)
+		f.comments = append(f.comments, comment.GetBody())
+		json.NewEncoder(w).Encode(comment)
+	case path == "/repos/test/hello/pulls/1":
+		json.NewEncoder(w).Encode(github.PullRequest{
+			Number: github.Int(1),
+			Head:   &github.PullRequestBranch{SHA: github.String("head1")},
+			Base:   &github.PullRequestBranch{Repo: testRepo},
+		})
+	case path == "/repos/test/hello/actions/runs":
+		w.Write([]byte(`{"workflow_runs": [{"id": 1, "name": "build", "head_sha": "head1", "status": "completed"}]}`))
+	case path == "/repos/test/hello/actions/runs/1/rerun":
+		f.reruns++
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newAgent returns an agent whose github client sends the requests to the fake server
+func newAgent(server *httptest.Server, reapprove bool) plugins.Agent {
+	return plugins.Agent{
+		GithubClient: fakegithub.NewClient(server),
+		Config: config.RepoConfig{
+			CIProvider: config.CIProviderGitHubActions,
+			Trigger:    config.Trigger{ReapproveOnPush: config.Bool(reapprove)},
+		},
+	}
+}
+
+var testRepo = &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}}
+
+//TestHandlePullRequest tests that the prs of the untrusted authors need ok-to-test
+func TestHandlePullRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    string
+		author    string
+		labels    []string
+		reapprove bool
+		want      []string
+	}{
+		{name: "member", action: plugins.ActionOpened, author: "alice", want: nil},
+		{name: "untrusted", action: plugins.ActionOpened, author: "mallory", want: []string{config.LabelNeedsOkToTest}},
+		{name: "already labeled", action: plugins.ActionReopened, author: "mallory", labels: []string{config.LabelNeedsOkToTest}, want: nil},
+		{name: "push", action: plugins.ActionSynchronize, author: "mallory", want: nil},
+		{name: "push needs reapproval", action: plugins.ActionSynchronize, author: "mallory", reapprove: true, want: []string{config.LabelNeedsOkToTest}},
+		{name: "push by member", action: plugins.ActionSynchronize, author: "alice", reapprove: true, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeGithub{members: map[string]bool{"alice": true}}
+			server := httptest.NewServer(f)
+			defer server.Close()
+
+			pr := &github.PullRequest{Number: github.Int(1), User: &github.User{Login: github.String(tt.author)}}
+			for _, l := range tt.labels {
+				pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l)})
+			}
+			event := github.PullRequestEvent{Action: github.String(tt.action), PullRequest: pr, Repo: testRepo}
+			if err := HandlePullRequest(newAgent(server, tt.reapprove), event); err != nil {
+				t.Fatalf("HandlePullRequest() error = %v", err)
+			}
+			if !reflect.DeepEqual(f.Added, tt.want) {
+				t.Errorf("added = %v, want %v", f.Added, tt.want)
+			}
+			if (len(f.comments) > 0) != (len(tt.want) > 0) {
+				t.Errorf("comments = %v", f.comments)
+			}
+		})
+	}
+}
+
+//TestHandleOkToTest tests that only the trusted users can ok-to-test
+func TestHandleOkToTest(t *testing.T) {
+	tests := []struct {
+		name        string
+		commenter   string
+		wantRemoved []string
+		wantReruns  int
+	}{
+		{name: "member", commenter: "alice", wantRemoved: []string{config.LabelNeedsOkToTest}, wantReruns: 1},
+		{name: "untrusted", commenter: "mallory", wantRemoved: nil, wantReruns: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeGithub{members: map[string]bool{"alice": true}}
+			server := httptest.NewServer(f)
+			defer server.Close()
+
+			event := github.IssueCommentEvent{
+				Action: github.String(plugins.ActionCreated),
+				Issue: &github.Issue{
+					Number:           github.Int(1),
+					State:            github.String("open"),
+					PullRequestLinks: &github.PullRequestLinks{URL: github.String("pr")},
+				},
+				Comment: &github.IssueComment{Body: github.String("/ok-to-test"), User: &github.User{Login: github.String(tt.commenter)}},
+				Repo:    testRepo,
+			}
+			if err := HandleOkToTest(newAgent(server, false), event); err != nil {
+				t.Fatalf("HandleOkToTest() error = %v", err)
+			}
+			if !reflect.DeepEqual(f.Removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", f.Removed, tt.wantRemoved)
+			}
+			if f.reruns != tt.wantReruns {
+				t.Errorf("reruns = %d, want %d", f.reruns, tt.wantReruns)
+			}
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package types
 
-//TravisCI `/requests` API structure response
-type TravisRespStruct struct {
-	Requests []struct {
-		Builds []struct {
-			Href              string `json:"@href"`
-			PullRequestNumber int    `json:"pull_request_number"`
-		} `json:"builds"`
-	} `json:"requests"`
+//TravisCI `/repo/{slug}/builds` API structure response
+type TravisBuildsRespStruct struct {
+	Builds []struct {
+		ID                int    `json:"id"`
+		Href              string `json:"@href"`
+		State             string `json:"state"`
+		PullRequestNumber int    `json:"pull_request_number"`
+		Commit            struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	} `json:"builds"`
 }
 
 //TravisCI `/jobs` API structure response
@@ -15,7 +18,61 @@ type TravisJobRespStruct struct {
 	Jobs []struct {
 		Type   string `json:"@type"`
 		Href   string `json:"@href"`
+		ID     int    `json:"id"`
 		Number string `json:"number"`
+		State  string `json:"state"`
+		// Config is included by include=job.config
+		Config struct {
+			Name string `json:"name"`
+		} `json:"config"`
+		// Stage is included by include=job.stage
+		Stage struct {
+			Name string `json:"name"`
+		} `json:"stage"`
 	} `json:"jobs"`
 }
 
+//GitHub Actions `/actions/runs` API structure response
+type ActionsRunsRespStruct struct {
+	WorkflowRuns []struct {
+		ID         int64  `json:"id"`
+		Name       string `json:"name"`
+		HeadSHA    string `json:"head_sha"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+		HTMLURL    string `json:"html_url"`
+	} `json:"workflow_runs"`
+}
+
+//Jenkins `/job/{name}/api/json` API structure response
+type JenkinsJobRespStruct struct {
+	Builds []struct {
+		Number   int    `json:"number"`
+		Result   string `json:"result"`
+		Building bool   `json:"building"`
+		URL      string `json:"url"`
+		Actions  []struct {
+			Parameters []struct {
+				Name  string      `json:"name"`
+				Value interface{} `json:"value"`
+			} `json:"parameters"`
+		} `json:"actions"`
+	} `json:"builds"`
+}
+
+//GitLab `/pipelines` API structure response
+type GitLabPipelineStruct struct {
+	ID     int    `json:"id"`
+	SHA    string `json:"sha"`
+	Status string `json:"status"`
+	WebURL string `json:"web_url"`
+}
+
+//GitLab `/pipelines/{id}/jobs` API structure response
+type GitLabJobStruct struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Stage  string `json:"stage"`
+	Status string `json:"status"`
+	WebURL string `json:"web_url"`
+}
@@ -1,29 +1,18 @@
 package handlers
 
 import (
-	"regexp"
+	"errors"
+	"strings"
 )
 
-var (
-	// label
-	LabelReg       = regexp.MustCompile("^/[Ll][Aa][Bb][Ee][Ll]")
-	LabelCancelReg = regexp.MustCompile("^/[Rr][Ee][Mm][Oo][Vv][Ee]-[Ll][Aa][Bb][Ee][Ll]")
-
-	// test
-	OkToTestReg = regexp.MustCompile("^/[Oo][Kk]-[Tt][Oo]-[Tt][Ee][Ss][Tt]")
-	RetestReg   = regexp.MustCompile("^/[Rr][Ee][Tt][Ee][Ss][Tt]")
-	TestReg     = regexp.MustCompile("^/[Tt][Ee][Ss][Tt]")
-
-	// review and approve
-	LgtmReg          = regexp.MustCompile("^/[Ll][Gg][Tt][Mm]")
-	LgtmCancelReg    = regexp.MustCompile("^/[Ll][Gg][Tt][Mm] [Cc][Aa][Nn][Cc][Ee][Ll]")
-	ApproveReg       = regexp.MustCompile("^/[Aa][Pp][Pp][Rr][Oo][Vv][Ee]")
-	ApproveCancelReg = regexp.MustCompile("^/[Aa][Pp][Pp][Rr][Oo][Vv][Ee] [Cc][Aa][Nn][Cc][Ee][Ll]")
-
-	//assign/unassign
-	AssignOrUnassing = regexp.MustCompile("(?mi)^/(un)?assign(( @?[-\\w]+?)*)\\s*$")
-)
-
-const (
-	needsOKtoTest = "needs-ok-to-test"
-)
+// aggregateErrors combines the errors of the plugins into one error. It returns nil if errs is empty.
+func aggregateErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+var (
+	botLock sync.Mutex
+	// botLogins are the logins of the authenticated users by the clients
+	botLogins = map[*github.Client]string{}
+)
+
+// BotLogin returns the login of the authenticated user of the client, which is ci-bot itself. It is cached per client.
+func BotLogin(client *github.Client) (string, error) {
+	botLock.Lock()
+	defer botLock.Unlock()
+	if login, ok := botLogins[client]; ok {
+		return login, nil
+	}
+	user, _, err := client.Users.Get(context.Background(), "")
+	if err != nil {
+		glog.Errorf("Unable to get the authenticated user. err: %v", err)
+		return "", err
+	}
+	botLogins[client] = user.GetLogin()
+	return user.GetLogin(), nil
+}
+
+// IsBotComment checks if the comment is written by ci-bot and starts with the marker
+func IsBotComment(ic *github.IssueComment, bot string, marker string) bool {
+	return ic.GetUser().GetLogin() == bot && strings.HasPrefix(ic.GetBody(), marker)
+}
@@ -0,0 +1,142 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+// GiversMarker identifies the comment of ci-bot which records who gave lgtm and approve
+const GiversMarker = "<!-- ci-bot givers"
+
+var (
+	// e.g. <!-- ci-bot givers: {"lgtm":["alice"]} -->
+	regGivers = regexp.MustCompile(`^<!-- ci-bot givers: (.*?) -->`)
+
+	// giversLock serializes the updates of the records, so that lgtm and approve do not overwrite each other
+	giversLock sync.Mutex
+)
+
+// Givers are the users who gave lgtm and approve. They are recorded by the lgtm and approve plugins
+// when the commands are accepted, so the refused commands are not counted.
+type Givers struct {
+	Lgtm     []string `json:"lgtm,omitempty"`
+	Approved []string `json:"approved,omitempty"`
+}
+
+// AddUsers returns the sorted list with the users added
+func AddUsers(list []string, users ...string) []string {
+	set := make(map[string]bool)
+	for _, u := range append(list, users...) {
+		set[u] = true
+	}
+	return sortedKeys(set)
+}
+
+// RemoveUsers returns the sorted list without the users
+func RemoveUsers(list []string, users ...string) []string {
+	set := make(map[string]bool)
+	for _, u := range list {
+		set[u] = true
+	}
+	for _, u := range users {
+		delete(set, u)
+	}
+	return sortedKeys(set)
+}
+
+// LoadGivers returns the recorded givers of the pr and the comment of the record, the comment is nil if there is no record
+func LoadGivers(client *github.Client, owner string, repo string, number int) (Givers, *github.IssueComment, error) {
+	givers := Givers{}
+	bot, err := BotLogin(client)
+	if err != nil {
+		return givers, nil, err
+	}
+	issueComments, err := ListComments(client, owner, repo, number)
+	if err != nil {
+		return givers, nil, err
+	}
+	for _, ic := range issueComments {
+		if !IsBotComment(ic, bot, GiversMarker) {
+			continue
+		}
+		m := regGivers.FindStringSubmatch(ic.GetBody())
+		if m == nil {
+			continue
+		}
+		err = json.Unmarshal([]byte(m[1]), &givers)
+		if err != nil {
+			glog.Errorf("Invalid givers record of pr #%d: %v", number, err)
+			return Givers{}, ic, nil
+		}
+		return givers, ic, nil
+	}
+	return givers, nil, nil
+}
+
+// UpdateGivers changes the recorded givers of the pr by update, the record is edited in place
+func UpdateGivers(client *github.Client, owner string, repo string, number int, update func(g *Givers)) error {
+	giversLock.Lock()
+	defer giversLock.Unlock()
+
+	givers, comment, err := LoadGivers(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	update(&givers)
+	body, err := formatGivers(givers)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if comment != nil {
+		if comment.GetBody() == body {
+			return nil
+		}
+		_, _, err = client.Issues.EditComment(ctx, owner, repo, comment.GetID(), &github.IssueComment{Body: github.String(body)})
+	} else {
+		if len(givers.Lgtm) == 0 && len(givers.Approved) == 0 {
+			return nil
+		}
+		_, _, err = client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(body)})
+	}
+	if err != nil {
+		glog.Errorf("Unable to record the givers of pr #%d. err: %v", number, err)
+		return err
+	}
+	glog.Infof("Record the givers of pr #%d: %+v", number, givers)
+	return nil
+}
+
+// formatGivers returns the body of the givers record
+func formatGivers(givers Givers) (string, error) {
+	b, err := json.Marshal(givers)
+	if err != nil {
+		return "", err
+	}
+	names := func(list []string) string {
+		if len(list) == 0 {
+			return "-"
+		}
+		return strings.Join(list, ", ")
+	}
+	return fmt.Sprintf("%s: %s -->\nLGTM by: %s\nApproved by: %s", GiversMarker, b, names(givers.Lgtm), names(givers.Approved)), nil
+}
+
+// sortedKeys returns the sorted users
+func sortedKeys(users map[string]bool) []string {
+	list := make([]string, 0, len(users))
+	for u := range users {
+		list = append(list, u)
+	}
+	sort.Strings(list)
+	return list
+}
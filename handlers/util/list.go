@@ -0,0 +1,78 @@
+package util
+
+import (
+	"context"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+// listPerPage is the page size of the list APIs, it is the maximum of github
+const listPerPage = 100
+
+// ListComments returns all the comments of the issue or pr, the oldest first
+func ListComments(client *github.Client, owner string, repo string, number int) ([]*github.IssueComment, error) {
+	list := make([]*github.IssueComment, 0)
+	opt := &github.IssueListCommentsOptions{Sort: "created", Direction: "asc", ListOptions: github.ListOptions{PerPage: listPerPage}}
+	for {
+		page, resp, err := client.Issues.ListComments(context.Background(), owner, repo, number, opt)
+		if err != nil {
+			glog.Errorf("Unable to list issue comments. err: %v", err)
+			return nil, err
+		}
+		list = append(list, page...)
+		if resp.NextPage == 0 {
+			return list, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListReviews returns all the reviews of the pr, the oldest first
+func ListReviews(client *github.Client, owner string, repo string, number int) ([]*github.PullRequestReview, error) {
+	list := make([]*github.PullRequestReview, 0)
+	opt := &github.ListOptions{PerPage: listPerPage}
+	for {
+		page, resp, err := client.PullRequests.ListReviews(context.Background(), owner, repo, number, opt)
+		if err != nil {
+			glog.Errorf("Unable to list pr reviews. err: %v", err)
+			return nil, err
+		}
+		list = append(list, page...)
+		if resp.NextPage == 0 {
+			return list, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListFiles returns all the changed files of the pr
+func ListFiles(client *github.Client, owner string, repo string, number int) ([]*github.CommitFile, error) {
+	list := make([]*github.CommitFile, 0)
+	opt := &github.ListOptions{PerPage: listPerPage}
+	for {
+		page, resp, err := client.PullRequests.ListFiles(context.Background(), owner, repo, number, opt)
+		if err != nil {
+			glog.Errorf("Unable to list pr changed files. err: %v", err)
+			return nil, err
+		}
+		list = append(list, page...)
+		if resp.NextPage == 0 {
+			return list, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// ListFileNames returns the names of all the changed files of the pr. e.g. test/hello.go
+func ListFileNames(client *github.Client, owner string, repo string, number int) ([]string, error) {
+	files, err := ListFiles(client, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.GetFilename())
+	}
+	return names, nil
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/fakegithub"
+)
+
+// pagedGithub serves the comments, reviews and files of the pr #1 one item per page
+type pagedGithub struct {
+	items map[string][]string
+}
+
+func (f *pagedGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	items, ok := f.items[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	if page < len(items) {
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`, r.Host, r.URL.Path, page+1))
+	}
+	item := items[page-1]
+	switch r.URL.Path {
+	case "/repos/test/hello/issues/1/comments":
+		json.NewEncoder(w).Encode([]github.IssueComment{{Body: github.String(item)}})
+	case "/repos/test/hello/pulls/1/reviews":
+		json.NewEncoder(w).Encode([]github.PullRequestReview{{Body: github.String(item)}})
+	case "/repos/test/hello/pulls/1/files":
+		json.NewEncoder(w).Encode([]github.CommitFile{{Filename: github.String(item)}})
+	}
+}
+
+//TestList tests that the comments, reviews and files of all the pages are listed
+func TestList(t *testing.T) {
+	items := []string{"first", "second", "third"}
+	server := httptest.NewServer(&pagedGithub{items: map[string][]string{
+		"/repos/test/hello/issues/1/comments": items,
+		"/repos/test/hello/pulls/1/reviews":   items,
+		"/repos/test/hello/pulls/1/files":     items,
+	}})
+	defer server.Close()
+	client := fakegithub.NewClient(server)
+
+	comments, err := ListComments(client, "test", "hello", 1)
+	if err != nil {
+		t.Fatalf("ListComments() error = %v", err)
+	}
+	got := make([]string, 0)
+	for _, c := range comments {
+		got = append(got, c.GetBody())
+	}
+	if !reflect.DeepEqual(got, items) {
+		t.Errorf("ListComments() = %v, want %v", got, items)
+	}
+
+	reviews, err := ListReviews(client, "test", "hello", 1)
+	if err != nil {
+		t.Fatalf("ListReviews() error = %v", err)
+	}
+	got = make([]string, 0)
+	for _, r := range reviews {
+		got = append(got, r.GetBody())
+	}
+	if !reflect.DeepEqual(got, items) {
+		t.Errorf("ListReviews() = %v, want %v", got, items)
+	}
+
+	files, err := ListFileNames(client, "test", "hello", 1)
+	if err != nil {
+		t.Fatalf("ListFileNames() error = %v", err)
+	}
+	if !reflect.DeepEqual(files, items) {
+		t.Errorf("ListFileNames() = %v, want %v", files, items)
+	}
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Users returns the set of the users, it can be mentioned by Mentions
+func Users(list []string) map[string]string {
+	users := make(map[string]string)
+	for _, u := range list {
+		users[u] = u
+	}
+	return users
+}
+
+// Mentions returns the sorted users mentioned with @. At most limit users are returned if limit is positive.
+func Mentions(users map[string]string, limit int) string {
+	list := make([]string, 0, len(users))
+	for u := range users {
+		list = append(list, "@"+u)
+	}
+	sort.Strings(list)
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return strings.Join(list, " ")
+}
+
+// Paths returns the paths separated by commas. At most limit paths are returned and the others are counted.
+func Paths(paths []string, limit int) string {
+	if limit <= 0 || len(paths) <= limit {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(paths[:limit], ", "), len(paths)-limit)
+}
@@ -0,0 +1,36 @@
+package util
+
+import "testing"
+
+//TestMentions tests that the users are mentioned in order and limited
+func TestMentions(t *testing.T) {
+	users := Users([]string{"carol", "alice", "bob"})
+	tests := []struct {
+		name  string
+		users map[string]string
+		limit int
+		want  string
+	}{
+		{name: "no users", users: Users(nil), want: ""},
+		{name: "all users", users: users, want: "@alice @bob @carol"},
+		{name: "limited", users: users, limit: 2, want: "@alice @bob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.users, tt.limit); got != tt.want {
+				t.Errorf("Mentions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+//TestPaths tests that the paths over the limit are counted
+func TestPaths(t *testing.T) {
+	paths := []string{"a", "b", "c"}
+	if got, want := Paths(paths, 0), "a, b, c"; got != want {
+		t.Errorf("Paths() = %q, want %q", got, want)
+	}
+	if got, want := Paths(paths, 2), "a, b and 1 more"; got != want {
+		t.Errorf("Paths() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// CommitData is passed to the templates of the merge commit
+type CommitData struct {
+	Title  string
+	Body   string
+	Number int
+	Author string
+	// LgtmBy are the users who gave lgtm
+	LgtmBy []string
+	// ApprovedBy are the users who approved
+	ApprovedBy []string
+	// ReviewedBy are the users who gave lgtm or approved except the author
+	ReviewedBy []string
+}
+
+// MergeMethod returns the merge method of the pr. The tide/merge-method-* label overrides the method of the base branch.
+func MergeMethod(cfg config.RepoConfig, pr *github.PullRequest, labels []*github.Label) (string, error) {
+	methods := make([]string, 0)
+	for _, l := range labels {
+		if strings.HasPrefix(l.GetName(), config.LabelPrefixMergeMethod) {
+			methods = append(methods, l.GetName())
+		}
+	}
+	switch len(methods) {
+	case 0:
+		return cfg.MergeMethodOf(pr.GetBase().GetRef()), nil
+	case 1:
+		method := strings.TrimPrefix(methods[0], config.LabelPrefixMergeMethod)
+		if !config.IsMergeMethod(method) {
+			return "", fmt.Errorf("unknown merge method label %s", methods[0])
+		}
+		return method, nil
+	}
+	return "", fmt.Errorf("conflicting merge method labels %s", strings.Join(methods, ", "))
+}
+
+// CommitMessage returns the title and the message of the merge commit by the templates
+func CommitMessage(cfg config.RepoConfig, data CommitData) (string, string, error) {
+	title, err := execute("title", cfg.CommitTemplate.Title, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := execute("body", cfg.CommitTemplate.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(title), strings.TrimSpace(body), nil
+}
+
+// execute executes the template with the data
+func execute(name string, text string, data CommitData) (string, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid commit template %s: %v", name, err)
+	}
+	var b bytes.Buffer
+	err = t.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute commit template %s: %v", name, err)
+	}
+	return b.String(), nil
+}
+
+// NewCommitData returns the data of the merge commit of the pr, who gave lgtm and approve
+// are read from the record of the lgtm and approve plugins, see Givers
+func NewCommitData(client *github.Client, owner string, repo string, pr *github.PullRequest) (CommitData, error) {
+	data := CommitData{
+		Title:  pr.GetTitle(),
+		Body:   pr.GetBody(),
+		Number: pr.GetNumber(),
+		Author: pr.GetUser().GetLogin(),
+	}
+	givers, _, err := LoadGivers(client, owner, repo, pr.GetNumber())
+	if err != nil {
+		return data, err
+	}
+
+	reviewed := make(map[string]bool)
+	for _, u := range append(givers.Lgtm, givers.Approved...) {
+		if u != data.Author {
+			reviewed[u] = true
+		}
+	}
+	data.LgtmBy = AddUsers(givers.Lgtm)
+	data.ApprovedBy = AddUsers(givers.Approved)
+	data.ReviewedBy = sortedKeys(reviewed)
+	return data, nil
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// labels returns the labels with the names
+func labels(names ...string) []*github.Label {
+	list := make([]*github.Label, 0)
+	for _, name := range names {
+		list = append(list, &github.Label{Name: github.String(name)})
+	}
+	return list
+}
+
+//TestMergeMethod tests that the label overrides the merge method of the base branch
+func TestMergeMethod(t *testing.T) {
+	cfg := config.RepoConfig{
+		MergeMethod:        config.MergeMethodMerge,
+		BranchMergeMethods: map[string]string{"release-1.0": config.MergeMethodRebase},
+	}
+	tests := []struct {
+		name    string
+		branch  string
+		labels  []*github.Label
+		want    string
+		wantErr bool
+	}{
+		{name: "repository", branch: "master", want: config.MergeMethodMerge},
+		{name: "branch", branch: "release-1.0", want: config.MergeMethodRebase},
+		{name: "label", branch: "release-1.0", labels: labels("lgtm", "tide/merge-method-squash"), want: config.MergeMethodSquash},
+		{name: "unknown label", branch: "master", labels: labels("tide/merge-method-octopus"), wantErr: true},
+		{name: "conflicting labels", branch: "master", labels: labels("tide/merge-method-squash", "tide/merge-method-rebase"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &github.PullRequest{Base: &github.PullRequestBranch{Ref: github.String(tt.branch)}}
+			got, err := MergeMethod(cfg, pr, tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MergeMethod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MergeMethod() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+//TestCommitMessage tests the commit message with the Reviewed-by trailers of the recorded lgtm and approve givers
+func TestCommitMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			w.Write([]byte(`{"login": "ci-bot"}`))
+		case "/repos/test/hello/issues/1/comments":
+			// the /lgtm comments are not counted unless the lgtm plugin accepted them, and a forged record is ignored
+			w.Write([]byte(`[
+				{"user": {"login": "carol"}, "body": "/lgtm"},
+				{"user": {"login": "carol"}, "body": "<!-- ci-bot givers: {\"lgtm\":[\"carol\"]} -->"},
+				{"user": {"login": "ci-bot"}, "body": "<!-- ci-bot givers: {\"lgtm\":[\"alice\",\"bob\"],\"approved\":[\"alice\",\"author\"]} -->"}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	pr := &github.PullRequest{
+		Number: github.Int(1),
+		Title:  github.String("Fix the bug"),
+		Body:   github.String("It fixes the bug."),
+		User:   &github.User{Login: github.String("author")},
+	}
+	data, err := NewCommitData(client, "test", "hello", pr)
+	if err != nil {
+		t.Fatalf("NewCommitData() error = %v", err)
+	}
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(data.LgtmBy, want) {
+		t.Errorf("LgtmBy = %v, want %v", data.LgtmBy, want)
+	}
+	if want := []string{"alice", "author"}; !reflect.DeepEqual(data.ApprovedBy, want) {
+		t.Errorf("ApprovedBy = %v, want %v", data.ApprovedBy, want)
+	}
+
+	cfg := config.RepoConfig{CommitTemplate: config.CommitTemplate{Title: config.DefaultCommitTitle, Body: config.DefaultCommitBody}}
+	title, body, err := CommitMessage(cfg, data)
+	if err != nil {
+		t.Fatalf("CommitMessage() error = %v", err)
+	}
+	if title != "Fix the bug (#1)" {
+		t.Errorf("title = %q", title)
+	}
+	if want := "It fixes the bug.\n\nReviewed-by: alice\nReviewed-by: bob"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
@@ -0,0 +1,287 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// ProtectionMarker identifies the branch protection comment of ci-bot
+const ProtectionMarker = "<!-- ci-bot branch protection -->"
+
+// the states of the status checks
+const (
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StatePending = "pending"
+)
+
+// BranchProtection returns the protection of the branch.
+// It is nil if the branch is not protected or the bot is not allowed to read the protection.
+func BranchProtection(client *github.Client, owner string, repo string, branch string) (*github.Protection, error) {
+	protection, resp, err := client.Repositories.GetBranchProtection(context.Background(), owner, repo, branch)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
+			glog.Infof("Branch %s/%s/%s is not protected or its protection is not readable: %v", owner, repo, branch, err)
+			return nil, nil
+		}
+		glog.Errorf("Unable to get the protection of branch: %s err: %v", branch, err)
+		return nil, err
+	}
+	return protection, nil
+}
+
+// CheckStates returns the states of the status contexts and the check runs of the commit by their names.
+// The states are success, failure or pending.
+func CheckStates(client *github.Client, owner string, repo string, sha string) (map[string]string, error) {
+	statuses, runs, err := listChecks(client, owner, repo, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]string)
+	for _, s := range statuses {
+		switch s.GetState() {
+		case "success":
+			states[s.GetContext()] = StateSuccess
+		case "failure", "error":
+			states[s.GetContext()] = StateFailure
+		default:
+			states[s.GetContext()] = StatePending
+		}
+	}
+	for _, r := range runs {
+		state := StatePending
+		if r.GetStatus() == "completed" {
+			switch r.GetConclusion() {
+			case "success", "neutral", "skipped":
+				state = StateSuccess
+			default:
+				state = StateFailure
+			}
+		}
+		states[r.GetName()] = state
+	}
+	return states, nil
+}
+
+// LastReported returns the last time when a status context or a check run of the commit was reported
+func LastReported(client *github.Client, owner string, repo string, sha string) (time.Time, error) {
+	var last time.Time
+	statuses, runs, err := listChecks(client, owner, repo, sha)
+	if err != nil {
+		return last, err
+	}
+	for _, s := range statuses {
+		if s.GetUpdatedAt().After(last) {
+			last = s.GetUpdatedAt()
+		}
+	}
+	for _, r := range runs {
+		for _, t := range []time.Time{r.GetStartedAt().Time, r.GetCompletedAt().Time} {
+			if t.After(last) {
+				last = t
+			}
+		}
+	}
+	return last, nil
+}
+
+// listChecks returns all the status contexts and the check runs of the commit
+func listChecks(client *github.Client, owner string, repo string, sha string) ([]github.RepoStatus, []*github.CheckRun, error) {
+	ctx := context.Background()
+	statuses := make([]github.RepoStatus, 0)
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		combined, resp, err := client.Repositories.GetCombinedStatus(ctx, owner, repo, sha, opt)
+		if err != nil {
+			glog.Errorf("Unable to get combined status: %s err: %v", sha, err)
+			return nil, nil, err
+		}
+		statuses = append(statuses, combined.Statuses...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	runs := make([]*github.CheckRun, 0)
+	runOpt := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		result, resp, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, runOpt)
+		if err != nil {
+			glog.Errorf("Unable to list check runs: %s err: %v", sha, err)
+			return nil, nil, err
+		}
+		runs = append(runs, result.CheckRuns...)
+		if resp.NextPage == 0 {
+			break
+		}
+		runOpt.Page = resp.NextPage
+	}
+	return statuses, runs, nil
+}
+
+// RequiresUpToDate checks if the branch protection requires the pull requests to be up to date with the branch before merging
+func RequiresUpToDate(protection *github.Protection) bool {
+	return protection != nil && protection.RequiredStatusChecks != nil && protection.RequiredStatusChecks.Strict
+}
+
+// ProtectionBlockers returns the requirements of the branch protection which the pull request does not satisfy yet.
+// The states are the check states of the head commit, see CheckStates. The up to date requirement is not checked, see RequiresUpToDate.
+func ProtectionBlockers(client *github.Client, cfg config.RepoConfig, owner string, repo string, pr *github.PullRequest,
+	protection *github.Protection, states map[string]string) ([]string, error) {
+	branch := pr.GetBase().GetRef()
+	reasons := make([]string, 0)
+
+	// required status checks, except the status of the merge queue which is set right before merging
+	requiresTide := false
+	if checks := protection.RequiredStatusChecks; checks != nil {
+		failed := make([]string, 0)
+		pending := make([]string, 0)
+		for _, c := range checks.Contexts {
+			if c == cfg.Tide.StatusContext {
+				requiresTide = true
+				continue
+			}
+			switch states[c] {
+			case StateSuccess:
+			case StateFailure:
+				failed = append(failed, c)
+			default:
+				pending = append(pending, c)
+			}
+		}
+		if len(failed) > 0 {
+			reasons = append(reasons, fmt.Sprintf("Failed required status checks: %s", strings.Join(failed, ", ")))
+		}
+		if len(pending) > 0 {
+			reasons = append(reasons, fmt.Sprintf("Waiting for required status checks: %s", strings.Join(pending, ", ")))
+		}
+	}
+
+	// required approving reviews
+	if enforcement := protection.RequiredPullRequestReviews; enforcement != nil {
+		reviews, err := ListReviews(client, owner, repo, pr.GetNumber())
+		if err != nil {
+			return nil, err
+		}
+		// the stale approvals are dismissed when new commits are pushed
+		headSHA := ""
+		if enforcement.DismissStaleReviews {
+			headSHA = pr.GetHead().GetSHA()
+		}
+		approved, changesRequested := reviewStates(reviews, headSHA)
+		if len(changesRequested) > 0 {
+			reasons = append(reasons, fmt.Sprintf("Changes requested by %s", strings.Join(changesRequested, ", ")))
+		}
+		count := enforcement.RequiredApprovingReviewCount
+		if count == 0 {
+			count = 1
+		}
+		if len(approved) < count {
+			reasons = append(reasons, fmt.Sprintf("Needs %d approving reviews, has %d", count, len(approved)))
+		}
+	}
+
+	// e.g. the code owner reviews which ci-bot does not check
+	if len(reasons) == 0 && !requiresTide && pr.GetMergeableState() == "blocked" {
+		reasons = append(reasons, fmt.Sprintf("Blocked by the branch protection of %s", branch))
+	}
+	return reasons, nil
+}
+
+// reviewStates returns the users whose latest reviews approved or requested changes.
+// The approvals of other commits than the head sha are not counted if the head sha is not empty.
+func reviewStates(reviews []*github.PullRequestReview, headSHA string) ([]string, []string) {
+	latest := make(map[string]*github.PullRequestReview)
+	for _, r := range reviews {
+		switch strings.ToLower(r.GetState()) {
+		case "approved", "changes_requested", "dismissed":
+			latest[r.GetUser().GetLogin()] = r
+		}
+	}
+	approved := make(map[string]bool)
+	changesRequested := make(map[string]bool)
+	for login, r := range latest {
+		switch strings.ToLower(r.GetState()) {
+		case "approved":
+			if headSHA == "" || r.GetCommitID() == headSHA {
+				approved[login] = true
+			}
+		case "changes_requested":
+			changesRequested[login] = true
+		}
+	}
+	return sortedKeys(approved), sortedKeys(changesRequested)
+}
+
+// NotifyProtection creates or edits the comment which explains the unsatisfied requirements of the branch protection.
+// The comment is deleted when the requirements are satisfied.
+func NotifyProtection(client *github.Client, owner string, repo string, number int, branch string, reasons []string) error {
+	ctx := context.Background()
+	bot, err := BotLogin(client)
+	if err != nil {
+		return err
+	}
+	issueComments, err := ListComments(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	var existing *github.IssueComment
+	for _, ic := range issueComments {
+		if IsBotComment(ic, bot, ProtectionMarker) {
+			existing = ic
+			break
+		}
+	}
+
+	if len(reasons) == 0 {
+		if existing == nil {
+			return nil
+		}
+		_, err = client.Issues.DeleteComment(ctx, owner, repo, existing.GetID())
+		if err != nil {
+			glog.Errorf("Unable to delete the branch protection comment of pr #%d. err: %v", number, err)
+		}
+		return err
+	}
+
+	body := FormatProtection(branch, reasons)
+	if existing != nil {
+		if existing.GetBody() == body {
+			return nil
+		}
+		_, _, err = client.Issues.EditComment(ctx, owner, repo, existing.GetID(), &github.IssueComment{Body: github.String(body)})
+		if err != nil {
+			glog.Errorf("Unable to edit the branch protection comment of pr #%d. err: %v", number, err)
+		}
+		return err
+	}
+	_, _, err = client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		glog.Errorf("Unable to create the branch protection comment of pr #%d. err: %v", number, err)
+	}
+	return err
+}
+
+// FormatProtection returns the body of the branch protection comment
+func FormatProtection(branch string, reasons []string) string {
+	var b strings.Builder
+	b.WriteString(ProtectionMarker + "\n")
+	fmt.Fprintf(&b, "This pull request is not merged until it satisfies the branch protection of `%s`:\n\n", branch)
+	list := append([]string{}, reasons...)
+	sort.Strings(list)
+	for _, r := range list {
+		fmt.Fprintf(&b, "- %s\n", r)
+	}
+	return b.String()
+}
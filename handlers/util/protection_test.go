@@ -0,0 +1,51 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// review returns a review of the user on the commit
+func review(login string, state string, commit string) *github.PullRequestReview {
+	return &github.PullRequestReview{
+		User:     &github.User{Login: github.String(login)},
+		State:    github.String(state),
+		CommitID: github.String(commit),
+	}
+}
+
+//TestReviewStates tests that the latest reviews of the users are counted
+func TestReviewStates(t *testing.T) {
+	reviews := []*github.PullRequestReview{
+		review("alice", "CHANGES_REQUESTED", "head1"),
+		review("alice", "APPROVED", "head1"),
+		review("bob", "APPROVED", "head1"),
+		review("bob", "COMMENTED", "head2"),
+		review("carol", "APPROVED", "head2"),
+		review("dave", "APPROVED", "head1"),
+		review("dave", "DISMISSED", "head1"),
+		review("erin", "CHANGES_REQUESTED", "head1"),
+	}
+	tests := []struct {
+		name             string
+		headSHA          string
+		approved         []string
+		changesRequested []string
+	}{
+		{name: "all commits", approved: []string{"alice", "bob", "carol"}, changesRequested: []string{"erin"}},
+		{name: "head commit", headSHA: "head2", approved: []string{"carol"}, changesRequested: []string{"erin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approved, changesRequested := reviewStates(reviews, tt.headSHA)
+			if !reflect.DeepEqual(approved, tt.approved) {
+				t.Errorf("approved = %v, want %v", approved, tt.approved)
+			}
+			if !reflect.DeepEqual(changesRequested, tt.changesRequested) {
+				t.Errorf("changes requested = %v, want %v", changesRequested, tt.changesRequested)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"context"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+)
+
+// IsTrusted checks if the user is a member of the org or the trusted orgs, or a collaborator of the repository
+func IsTrusted(client *github.Client, cfg config.RepoConfig, org string, repo string, user string) (bool, error) {
+	ctx := context.Background()
+	for _, o := range append([]string{org}, cfg.Trigger.TrustedOrgs...) {
+		isMember, _, err := client.Organizations.IsMember(ctx, o, user)
+		if err != nil {
+			glog.Errorf("Unable to check if %s is a member of %s. err: %v", user, o, err)
+			return false, err
+		}
+		if isMember {
+			return true, nil
+		}
+	}
+
+	isCollaborator, _, err := client.Repositories.IsCollaborator(ctx, org, repo, user)
+	if err != nil {
+		glog.Errorf("Unable to check if %s is a collaborator. err: %v", user, err)
+		return false, err
+	}
+	return isCollaborator, nil
+}
+
+// HasLabel checks if the labels contain the label name
+func HasLabel(labels []*github.Label, name string) bool {
+	for _, l := range labels {
+		if l.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
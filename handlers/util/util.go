@@ -2,70 +2,146 @@ package util
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/google/go-github/github"
-)
 
-var (
-	// approve label name
-	LabelNameApproved = "approved"
-	// lgtm label name
-	LabelNameLgtm = "lgtm"
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
 )
 
-// MergePullRequest with approved and lgtm label
-func MergePullRequest(client *github.Client, owner string, repo string, number int) error {
+// LabelBlockers returns the reasons why the labels block merging. It is empty if the labels allow merging.
+func LabelBlockers(cfg config.RepoConfig, labels []*github.Label) []string {
+	// check if it has both approved and lgtm label
+	hasApproved := false
+	hasLgtm := false
+	blockingLabels := make([]string, 0)
+	for _, l := range labels {
+		name := l.GetName()
+		if name == cfg.Labels.Approved {
+			hasApproved = true
+		} else if name == cfg.Labels.Lgtm {
+			hasLgtm = true
+		}
+		// e.g. do-not-merge/hold
+		if strings.HasPrefix(name, config.LabelPrefixDoNotMerge) {
+			blockingLabels = append(blockingLabels, name)
+			continue
+		}
+		for _, b := range cfg.BlockingLabels {
+			if name == b {
+				blockingLabels = append(blockingLabels, name)
+				break
+			}
+		}
+	}
+	glog.Infof("Pr labels have approved: %t lgtm: %t blocking: %v", hasApproved, hasLgtm, blockingLabels)
+
+	reasons := make([]string, 0)
+	missingLabels := make([]string, 0)
+	if !hasApproved {
+		missingLabels = append(missingLabels, cfg.Labels.Approved)
+	}
+	if !hasLgtm {
+		missingLabels = append(missingLabels, cfg.Labels.Lgtm)
+	}
+	if len(missingLabels) > 0 {
+		reasons = append(reasons, fmt.Sprintf("Needs %s label", strings.Join(missingLabels, ", ")))
+	}
+	if len(blockingLabels) > 0 {
+		reasons = append(reasons, fmt.Sprintf("Should not have %s label", strings.Join(blockingLabels, ", ")))
+	}
+	return reasons
+}
+
+// MergePullRequest with approved and lgtm label by the merge method and the commit templates of the repository.
+// It is refused if the pull request does not satisfy the protection of its base branch.
+func MergePullRequest(client *github.Client, cfg config.RepoConfig, owner string, repo string, number int) error {
 	glog.Infof("Merge pr started. owner: %s repo: %s number: %d", owner, repo, number)
 
 	// list labels in current pr
 	ctx := context.Background()
 	listofPrLabels, _, err := client.Issues.ListLabelsByIssue(ctx, owner, repo, number, nil)
 	if err != nil {
-		glog.Fatalf("Unable to list pr labels. err: %v", err)
+		glog.Errorf("Unable to list pr labels. err: %v", err)
 		return err
 	}
 	glog.Infof("List of pr labels: %v", listofPrLabels)
 
-	// check if it has both approved and lgtm label
-	hasApproved := false
-	hasLgtm := false
-	for _, l := range listofPrLabels {
-		if *l.Name == LabelNameApproved {
-			hasApproved = true
-		} else if *l.Name == LabelNameLgtm {
-			hasLgtm = true
-		}
+	// refuse to merge if the labels are not ready
+	reasons := LabelBlockers(cfg, listofPrLabels)
+	if len(reasons) > 0 {
+		glog.Infof("Pr #%d can not be merged: %v", number, reasons)
+		return fmt.Errorf("pr #%d can not be merged: %s", number, strings.Join(reasons, ". "))
 	}
-	glog.Infof("Pr labels have approved: %t lgtm: %t", hasApproved, hasLgtm)
 
-	// ready to merge
-	if hasApproved && hasLgtm {
-		// get commit message
-		pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
-		commitMessage := *pr.Title
-		glog.Infof("Commit message: %s", commitMessage)
+	// get commit message
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return err
+	}
 
-		// merge pr
-		result, _, err := client.PullRequests.Merge(ctx, owner, repo, number, commitMessage, nil)
+	// refuse to merge if the branch protection is not satisfied, instead of an opaque error of the merge API
+	protection, err := BranchProtection(client, owner, repo, pr.GetBase().GetRef())
+	if err != nil {
+		return err
+	}
+	if protection != nil {
+		states, err := CheckStates(client, owner, repo, pr.GetHead().GetSHA())
 		if err != nil {
-			glog.Errorf("Unable to merge pr: #%d err: %v", number, err)
 			return err
 		}
-
-		// skip nil
-		if result == nil {
-			glog.Errorf("The merge result of pr #%d is nil", number)
+		reasons, err = ProtectionBlockers(client, cfg, owner, repo, pr, protection, states)
+		if err != nil {
 			return err
 		}
-
-		// check merge result
-		if !*result.Merged {
-			glog.Errorf("Failed to merge pr #%d. Message: %s", number, *result.Message)
-		} else {
-			glog.Infof("Merge pr #%d successfully", number)
+		if RequiresUpToDate(protection) && pr.GetMergeableState() == "behind" {
+			reasons = append(reasons, fmt.Sprintf("Needs to be up to date with %s", pr.GetBase().GetRef()))
+		}
+		if len(reasons) > 0 {
+			glog.Infof("Pr #%d does not satisfy the branch protection: %v", number, reasons)
+			return fmt.Errorf("pr #%d can not be merged: %s", number, strings.Join(reasons, ". "))
 		}
 	}
 
+	method, err := MergeMethod(cfg, pr, listofPrLabels)
+	if err != nil {
+		glog.Errorf("Unable to get the merge method of pr #%d: %v", number, err)
+		return err
+	}
+	data, err := NewCommitData(client, owner, repo, pr)
+	if err != nil {
+		return err
+	}
+	commitTitle, commitMessage, err := CommitMessage(cfg, data)
+	if err != nil {
+		glog.Errorf("Unable to build the commit message of pr #%d: %v", number, err)
+		return err
+	}
+	glog.Infof("Merge method: %s commit title: %s", method, commitTitle)
+
+	// merge pr
+	options := &github.PullRequestOptions{CommitTitle: commitTitle, MergeMethod: method}
+	result, _, err := client.PullRequests.Merge(ctx, owner, repo, number, commitMessage, options)
+	if err != nil {
+		glog.Errorf("Unable to merge pr: #%d err: %v", number, err)
+		return err
+	}
+
+	// skip nil
+	if result == nil {
+		glog.Errorf("The merge result of pr #%d is nil", number)
+		return fmt.Errorf("the merge result of pr #%d is nil", number)
+	}
+
+	// check merge result
+	if !result.GetMerged() {
+		glog.Errorf("Failed to merge pr #%d. Message: %s", number, result.GetMessage())
+		return fmt.Errorf("failed to merge pr #%d: %s", number, result.GetMessage())
+	}
+	glog.Infof("Merge pr #%d successfully", number)
+
 	return nil
 }
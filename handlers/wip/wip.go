@@ -0,0 +1,90 @@
+package wip
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+	"github.com/huawei-cloudnative/ci-bot/handlers/util"
+)
+
+// RegTitle is the regular expression of the work in progress titles. e.g. WIP: fix, [WIP] fix
+var RegTitle = regexp.MustCompile(`(?i)^\W?WIP\b`)
+
+// mediaTypeDraft is the preview media type of the draft pull requests
+const mediaTypeDraft = "application/vnd.github.shadow-cat-preview+json"
+
+func init() {
+	plugins.Register(plugins.Plugin{
+		Name: "wip",
+		Help: "The " + config.LabelWorkInProgress + " label is added to the prs whose title starts with WIP or which are drafts, " +
+			"and it is removed when they are ready for review.",
+		PullRequestHandler: func(agent plugins.Agent, event github.PullRequestEvent) error {
+			return HandlePullRequest(agent, event)
+		},
+		PullRequestActions: []string{plugins.ActionOpened, plugins.ActionReopened, plugins.ActionEdited,
+			plugins.ActionReadyForReview, plugins.ActionConvertedToDraft},
+	})
+}
+
+// HandlePullRequest adds or removes the work in progress label by the title and the draft state of the pr
+func HandlePullRequest(agent plugins.Agent, event github.PullRequestEvent) error {
+	ctx := context.Background()
+	client := agent.GithubClient
+	pr := event.GetPullRequest()
+	owner := event.Repo.GetOwner().GetLogin()
+	repo := event.Repo.GetName()
+	number := pr.GetNumber()
+	if pr.GetState() != "open" {
+		return nil
+	}
+
+	isDraft, err := draft(client, owner, repo, number)
+	if err != nil {
+		return err
+	}
+	wip := isDraft || RegTitle.MatchString(pr.GetTitle())
+	labeled := util.HasLabel(pr.Labels, config.LabelWorkInProgress)
+	glog.Infof("Pr #%d is work in progress: %t draft: %t labeled: %t", number, wip, isDraft, labeled)
+
+	if wip && !labeled {
+		_, _, err = client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{config.LabelWorkInProgress})
+		if err != nil {
+			glog.Errorf("Unable to add label: %s err: %v", config.LabelWorkInProgress, err)
+			return err
+		}
+		glog.Infof("Add label %s to pr #%d", config.LabelWorkInProgress, number)
+	}
+	if !wip && labeled {
+		_, err = client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, config.LabelWorkInProgress)
+		if err != nil {
+			glog.Errorf("Unable to remove label: %s err: %v", config.LabelWorkInProgress, err)
+			return err
+		}
+		glog.Infof("Remove label %s from pr #%d", config.LabelWorkInProgress, number)
+	}
+	return nil
+}
+
+// draft checks if the pr is a draft. The vendored client does not decode the draft state so it is read from the API.
+func draft(client *github.Client, owner string, repo string, number int) (bool, error) {
+	req, err := client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/pulls/%d", owner, repo, number), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", mediaTypeDraft)
+	var pr struct {
+		Draft bool `json:"draft"`
+	}
+	_, err = client.Do(context.Background(), req, &pr)
+	if err != nil {
+		glog.Errorf("Unable to get pr: #%d err: %v", number, err)
+		return false, err
+	}
+	return pr.Draft, nil
+}
@@ -0,0 +1,82 @@
+package wip
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/huawei-cloudnative/ci-bot/handlers/config"
+	"github.com/huawei-cloudnative/ci-bot/handlers/fakegithub"
+	"github.com/huawei-cloudnative/ci-bot/handlers/plugins"
+)
+
+// fakeGithub serves the draft state and records the labels which are added and removed
+type fakeGithub struct {
+	fakegithub.Labels
+	draft bool
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.Serve(w, r) {
+		return
+	}
+	if r.URL.Path == "/repos/test/hello/pulls/1" {
+		fmt.Fprintf(w, `{"number": 1, "draft": %t}`, f.draft)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+//TestHandlePullRequest tests that the work in progress label follows the title and the draft state
+func TestHandlePullRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		draft   bool
+		labels  []string
+		added   []string
+		removed []string
+	}{
+		{name: "wip title", title: "WIP: fix the bug", added: []string{config.LabelWorkInProgress}},
+		{name: "bracketed wip title", title: "[wip] fix the bug", added: []string{config.LabelWorkInProgress}},
+		{name: "draft", title: "fix the bug", draft: true, added: []string{config.LabelWorkInProgress}},
+		{name: "already labeled", title: "WIP fix the bug", labels: []string{config.LabelWorkInProgress}},
+		{name: "ready", title: "fix the bug", labels: []string{config.LabelWorkInProgress}, removed: []string{config.LabelWorkInProgress}},
+		{name: "wipe is not wip", title: "wipe the cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeGithub{draft: tt.draft}
+			server := httptest.NewServer(f)
+			defer server.Close()
+			client := fakegithub.NewClient(server)
+
+			pr := &github.PullRequest{
+				Number: github.Int(1),
+				State:  github.String("open"),
+				Title:  github.String(tt.title),
+			}
+			for _, l := range tt.labels {
+				pr.Labels = append(pr.Labels, &github.Label{Name: github.String(l)})
+			}
+			event := github.PullRequestEvent{
+				Action:      github.String(plugins.ActionEdited),
+				PullRequest: pr,
+				Repo:        &github.Repository{Name: github.String("hello"), Owner: &github.User{Login: github.String("test")}},
+			}
+			if err := HandlePullRequest(plugins.Agent{GithubClient: client}, event); err != nil {
+				t.Fatalf("HandlePullRequest() error = %v", err)
+			}
+			if !reflect.DeepEqual(f.Added, tt.added) {
+				t.Errorf("added = %v, want %v", f.Added, tt.added)
+			}
+			if !reflect.DeepEqual(f.Removed, tt.removed) {
+				t.Errorf("removed = %v, want %v", f.Removed, tt.removed)
+			}
+		})
+	}
+}